@@ -10,6 +10,7 @@ require (
 	github.com/klauspost/compress v1.18.0
 	github.com/muhammadmuzzammil1998/jsonc v1.0.0
 	github.com/pelletier/go-toml v1.9.5
+	github.com/pierrec/lz4/v4 v4.1.22
 	github.com/sandertv/go-raknet v1.14.2
 	golang.org/x/net v0.41.0
 	golang.org/x/oauth2 v0.30.0
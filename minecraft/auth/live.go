@@ -50,7 +50,8 @@ func (src *tokenSource) Token() (*oauth2.Token, error) {
 
 // RefreshTokenSource returns a new oauth2.TokenSource using the oauth2.Token passed that automatically
 // refreshes the token everytime it expires. Note that this function must be used over oauth2.ReuseTokenSource
-// due to that function not refreshing with the correct scopes.
+// due to that function not refreshing with the correct scopes. To reuse tokens after a restart, use
+// StoreTokenSource.
 func RefreshTokenSource(t *oauth2.Token) oauth2.TokenSource {
 	return RefreshTokenSourceWriter(t, os.Stdout)
 }
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// TokenStore persists Live Connect tokens, so that they may be reused after a restart instead of requiring
+// the user to authenticate again.
+type TokenStore interface {
+	// Load loads the token last saved. If no token was saved yet, Load returns a nil token and a nil error.
+	Load() (*oauth2.Token, error)
+	// Save saves the token passed, replacing the token previously saved.
+	Save(t *oauth2.Token) error
+}
+
+// FileTokenStore is a TokenStore that stores a token as JSON in the file at Path. The file is created with
+// permissions that only allow the current user to read it, as the token grants access to the account.
+type FileTokenStore struct {
+	Path string
+}
+
+// Load reads the token from the file at Path. If the file does not exist, Load returns a nil token and a nil
+// error.
+func (s FileTokenStore) Load() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read token file: %w", err)
+	}
+	t := new(oauth2.Token)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("decode token file: %w", err)
+	}
+	return t, nil
+}
+
+// Save writes the token passed to the file at Path. The token is first written to a temporary file which then
+// replaces the file at Path, so that the file is never left partially written.
+func (s FileTokenStore) Save(t *oauth2.Token) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("encode token: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create token file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write token file: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.Path); err != nil {
+		return fmt.Errorf("replace token file: %w", err)
+	}
+	return nil
+}
+
+// StoreTokenSource returns a new oauth2.TokenSource that loads its token from the TokenStore passed and saves
+// every token it obtains to it, so that tokens survive restarts. If the TokenStore holds no token, the token
+// is requested using device auth, printing the auth URL and code to the io.Writer passed.
+// The token is refreshed once it expires within the window passed, so that it does not expire while it is
+// in use. If refreshing fails while the token has not yet expired, the current token is returned instead.
+func StoreTokenSource(store TokenStore, w io.Writer, window time.Duration) oauth2.TokenSource {
+	return &storeTokenSource{store: store, w: w, window: window}
+}
+
+// storeTokenSource implements the oauth2.TokenSource returned by StoreTokenSource.
+type storeTokenSource struct {
+	store  TokenStore
+	w      io.Writer
+	window time.Duration
+
+	mu sync.Mutex
+	t  *oauth2.Token
+}
+
+// Token returns the current token, loading, requesting or refreshing it if necessary.
+func (src *storeTokenSource) Token() (*oauth2.Token, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.t == nil {
+		t, err := src.store.Load()
+		if err != nil {
+			return nil, fmt.Errorf("load token: %w", err)
+		}
+		if t == nil {
+			if t, err = RequestLiveTokenWriter(src.w); err != nil {
+				return nil, err
+			}
+			if err := src.store.Save(t); err != nil {
+				return nil, fmt.Errorf("save token: %w", err)
+			}
+		}
+		src.t = t
+	}
+	if src.t.Expiry.IsZero() || time.Until(src.t.Expiry) > src.window {
+		return src.copy(), nil
+	}
+	t, err := refreshToken(src.t)
+	if err != nil {
+		if src.t.Valid() {
+			return src.copy(), nil
+		}
+		return nil, err
+	}
+	src.t = t
+	if err := src.store.Save(t); err != nil {
+		return nil, fmt.Errorf("save token: %w", err)
+	}
+	return src.copy(), nil
+}
+
+// copy returns a copy of the current token, so that callers cannot modify it.
+func (src *storeTokenSource) copy() *oauth2.Token {
+	t := *src.t
+	return &t
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	store := FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	if tok, err := store.Load(); tok != nil || err != nil {
+		t.Fatalf("expected no token and no error before saving, got %v (%v)", tok, err)
+	}
+
+	want := &oauth2.Token{AccessToken: "access", TokenType: "bearer", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour).Round(time.Second)}
+	if err := store.Save(want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.AccessToken != want.AccessToken || got.TokenType != want.TokenType || got.RefreshToken != want.RefreshToken || !got.Expiry.Equal(want.Expiry) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if info, err := os.Stat(store.Path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected token file readable by the current user only, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// Saving again replaces the token without leaving temporary files behind.
+	if err := store.Save(&oauth2.Token{AccessToken: "new"}); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+	if got, _ := store.Load(); got.AccessToken != "new" {
+		t.Errorf("expected token to be replaced, got %+v", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(store.Path)); len(entries) != 1 {
+		t.Errorf("expected only the token file to be left, got %v entries", len(entries))
+	}
+
+	if err := os.WriteFile(store.Path, []byte("{"), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := store.Load(); err == nil {
+		t.Error("expected loading a malformed token file to fail")
+	}
+}
+
+// refreshTransport is an http.RoundTripper that answers token refresh requests with a new token, counting
+// the refreshes.
+type refreshTransport struct {
+	refreshes atomic.Int32
+}
+
+// RoundTrip ...
+func (tr *refreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.refreshes.Add(1)
+	body := `{"token_type": "bearer", "access_token": "refreshed", "refresh_token": "refresh2", "expires_in": 3600}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestStoreTokenSourceRefresh(t *testing.T) {
+	tr := &refreshTransport{}
+	transport := http.DefaultTransport
+	http.DefaultTransport = tr
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	store := FileTokenStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	if err := store.Save(&oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// The token expires within the window, so it must be refreshed and the new token saved.
+	tok, err := StoreTokenSource(store, io.Discard, 5*time.Minute).Token()
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	if tok.AccessToken != "refreshed" || tr.refreshes.Load() != 1 {
+		t.Fatalf("expected refreshed token after 1 refresh, got %+v after %v", tok, tr.refreshes.Load())
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if saved.AccessToken != "refreshed" || saved.RefreshToken != "refresh2" {
+		t.Errorf("expected refreshed token to be saved, got %+v", saved)
+	}
+
+	// A new token source, as created after a restart, uses the saved token without refreshing it.
+	tok, err = StoreTokenSource(store, io.Discard, 5*time.Minute).Token()
+	if err != nil {
+		t.Fatalf("token after restart: %v", err)
+	}
+	if tok.AccessToken != "refreshed" || tr.refreshes.Load() != 1 {
+		t.Errorf("expected saved token to be used without refreshing, got %+v after %v refreshes", tok, tr.refreshes.Load())
+	}
+}
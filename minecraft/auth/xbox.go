@@ -41,6 +41,23 @@ func (t XBLToken) SetAuthHeader(r *http.Request) {
 
 // RequestXBLToken requests an XBOX Live auth token using the passed Live token pair.
 func RequestXBLToken(ctx context.Context, liveToken *oauth2.Token, relyingParty string) (*XBLToken, error) {
+	// We first generate an ECDSA private key which will be used to provide a 'ProofKey' to each of the
+	// requests, and to sign these requests.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating ECDSA key: %w", err)
+	}
+	return RequestXBLTokenWithKey(ctx, liveToken, relyingParty, key)
+}
+
+// RequestXBLTokenWithKey requests an XBOX Live auth token like RequestXBLToken, but uses the P-256 private
+// key passed as 'ProofKey' and to sign the requests, rather than generating a new key. It exists so that
+// tests may produce reproducible requests and should not be used otherwise: RequestXBLToken generates a new
+// key for every request, which is what production code should rely on.
+func RequestXBLTokenWithKey(ctx context.Context, liveToken *oauth2.Token, relyingParty string, key *ecdsa.PrivateKey) (*XBLToken, error) {
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("private key must use the P-256 curve")
+	}
 	if !liveToken.Valid() {
 		return nil, fmt.Errorf("live token is no longer valid")
 	}
@@ -54,12 +71,6 @@ func RequestXBLToken(ctx context.Context, liveToken *oauth2.Token, relyingParty
 	}
 	defer c.CloseIdleConnections()
 
-	// We first generate an ECDSA private key which will be used to provide a 'ProofKey' to each of the
-	// requests, and to sign these requests.
-	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("generating ECDSA key: %w", err)
-	}
 	deviceToken, err := obtainDeviceToken(ctx, c, key)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,32 @@
+package minecraft
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+func TestSameCompression(t *testing.T) {
+	prefixedFlate := packet.NewOnTheFlyCompression(packet.FlateCompression)
+	prefixedNop := packet.NewOnTheFlyCompression(packet.NopCompression)
+	tests := []struct {
+		name string
+		a, b packet.Compression
+		want bool
+	}{
+		{"nil", nil, nil, true},
+		{"nil and flate", nil, packet.FlateCompression, false},
+		{"flate", packet.FlateCompression, packet.FlateCompression, true},
+		{"flate and snappy", packet.FlateCompression, packet.SnappyCompression, false},
+		{"nop", packet.NopCompression, packet.NopCompression, true},
+		{"prefixed", prefixedFlate, prefixedNop, true},
+		{"prefixed and nop", prefixedFlate, packet.NopCompression, false},
+		{"nop and prefixed", packet.NopCompression, prefixedNop, false},
+		{"prefixed and flate", prefixedFlate, packet.FlateCompression, false},
+	}
+	for _, test := range tests {
+		if got := sameCompression(test.a, test.b); got != test.want {
+			t.Errorf("%v: sameCompression() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package minecraft
+
+// ClientThrottle holds the client throttling settings sent in the NetworkSettings packet. When enabled,
+// clients stop ticking players beyond a threshold, which improves performance on low-end devices when many
+// players are nearby.
+type ClientThrottle struct {
+	// Enabled specifies if the client should throttle players once the number of players exceeds Threshold.
+	Enabled bool
+	// Threshold is the number of players above which the client starts throttling players.
+	Threshold uint8
+	// Scalar is the scalar applied to the amount of players that are ticked while throttling.
+	Scalar float32
+}
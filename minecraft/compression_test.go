@@ -0,0 +1,127 @@
+package minecraft
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// recordingConn is a pipeConn that records the data of every Write call.
+type recordingConn struct {
+	*pipeConn
+	mu      sync.Mutex
+	written [][]byte
+}
+
+// Write ...
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.written = append(c.written, append([]byte(nil), b...))
+	c.mu.Unlock()
+	return c.pipeConn.Write(b)
+}
+
+// recordingNetwork is a pipeNetwork that records the data written by the client end of the connection dialed.
+type recordingNetwork struct {
+	*pipeNetwork
+	client *recordingConn
+}
+
+// DialContext ...
+func (n *recordingNetwork) DialContext(ctx context.Context, address string) (net.Conn, error) {
+	c, err := n.pipeNetwork.DialContext(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	n.client = &recordingConn{pipeConn: c.(*pipeConn)}
+	return n.client, nil
+}
+
+// TestNopCompression checks that a client connecting to a Listener configured to use packet.NopCompression
+// sends its batches uncompressed, and that the connection is established using those batches.
+func TestNopCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression packet.Compression
+		// prefix is the compression algorithm ID that the Login batch of the client should be prefixed with.
+		prefix byte
+	}{
+		{"nop", packet.NopCompression, 0xff},
+		{"flate", packet.FlateCompression, byte(packet.CompressionAlgorithmFlate)},
+	}
+	for _, test := range tests {
+		n := &recordingNetwork{pipeNetwork: &pipeNetwork{l: newPipeListener()}}
+		client, server := dialRecording(t, n, test.compression)
+		if !sameCompression(client.Compression(), test.compression) || !sameCompression(server.Compression(), test.compression) {
+			t.Errorf("%v: expected compression %v on both ends, got %v and %v", test.name, test.compression.EncodeCompression(), client.Compression().EncodeCompression(), server.Compression().EncodeCompression())
+		}
+		_ = client.Close()
+		_ = server.Close()
+
+		n.client.mu.Lock()
+		written := n.client.written
+		n.client.mu.Unlock()
+		// The first batch holds the RequestNetworkSettings packet, which is never compressed. The second
+		// holds the Login packet, which exceeds the compression threshold.
+		if len(written) < 2 {
+			t.Fatalf("%v: expected at least 2 batches written, got %v", test.name, len(written))
+		}
+		batch := written[1]
+		if len(batch) < 2 || batch[0] != 0xfe || batch[1] != test.prefix {
+			t.Fatalf("%v: expected Login batch prefixed with fe%02x, got %x", test.name, test.prefix, batch[:min(len(batch), 2)])
+		}
+		if test.prefix != 0xff {
+			continue
+		}
+		// The batch is not compressed, so it holds the Login packet prefixed with its length as is.
+		buf := bytes.NewBuffer(batch[2:])
+		length, err := binary.ReadUvarint(buf)
+		if err != nil || length != uint64(buf.Len()) {
+			t.Fatalf("%v: expected uncompressed batch holding one packet of %v bytes, got length %v (%v)", test.name, buf.Len(), length, err)
+		}
+		if header, err := binary.ReadUvarint(buf); err != nil || header&0x3ff != packet.IDLogin {
+			t.Errorf("%v: expected uncompressed Login packet, got header %v (%v)", test.name, header, err)
+		}
+	}
+}
+
+// dialRecording connects a client and a server over the recordingNetwork passed, with the server using the
+// Compression passed.
+func dialRecording(t *testing.T, n *recordingNetwork, compression packet.Compression) (client, server *Conn) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l, err := ListenConfig{AuthenticationDisabled: true, Compression: compression, network: n}.Listen("pipe", pipeAddr.String())
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	accepted := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			server = c.(*Conn)
+			err = server.StartGameContext(ctx, GameData{})
+		}
+		accepted <- err
+	}()
+	client, err = Dialer{network: n}.DialContext(ctx, "pipe", pipeAddr.String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if err := client.DoSpawnContext(ctx); err != nil {
+		t.Fatalf("spawn: %v", err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatalf("start game: %v", err)
+	}
+	return client, server
+}
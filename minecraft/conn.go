@@ -11,8 +11,11 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
+	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -58,22 +61,68 @@ type Conn struct {
 	conn        net.Conn
 	log         *slog.Logger
 	authEnabled bool
-
-	proto              Protocol
-	acceptedProto      []Protocol
-	pool               packet.Pool
-	enc                *packet.Encoder
-	dec                *packet.Decoder
-	compression        packet.Compression
-	maxDecompressedLen int
-	readerLimits       bool
+	// offlineIdentity specifies if the identity of players not logged into XBOX Live should be replaced with
+	// a UUID derived from their display name.
+	offlineIdentity bool
+
+	proto                Protocol
+	acceptedProto        []Protocol
+	pool                 packet.Pool
+	enc                  *packet.Encoder
+	dec                  *packet.Decoder
+	compression          packet.Compression
+	compressionThreshold int
+	// activeCompression holds the compression currently used for batches sent over the connection. It is
+	// nil until compression is negotiated through the NetworkSettings packet.
+	activeCompression atomic.Pointer[packet.Compression]
+	// encryptionKeyLen is the length in bytes of the key used to encrypt the connection. It is 0 as long as
+	// encryption is not enabled.
+	encryptionKeyLen atomic.Int32
+	// securityInfo holds the parameters of the encryption of the connection, once it is enabled.
+	securityInfo atomic.Pointer[SecurityInfo]
+	// clientThrottle holds the client throttling settings sent in the NetworkSettings packet. For connections
+	// obtained using Dial, it holds the settings received from the server.
+	clientThrottle ClientThrottle
+
+	// minProtocol and maxProtocol limit the protocols accepted from clients connecting to a Listener, which
+	// are disconnected with outdatedClientMessage or outdatedServerMessage if outside of this range.
+	minProtocol, maxProtocol                     int32
+	outdatedClientMessage, outdatedServerMessage string
+	// onTheFlyCompression specifies if each batch sent over the connection is prefixed with the compression
+	// algorithm used, which is the case from 1.20.60 onwards.
+	onTheFlyCompression bool
+	maxDecompressedLen  int
+	readerLimits        bool
+	// decompressLimiter limits the rate of decompressed bytes read, if non-nil. It is only used by the
+	// goroutine reading batches.
+	decompressLimiter *rateLimiter
 
 	disconnectOnUnknownPacket bool
 	disconnectOnInvalidPacket bool
+	// strictLogin specifies if packets other than those expected in the current phase of the login sequence
+	// lead to the connection being closed until it is logged in. It is set for connections accepted by a
+	// Listener.
+	strictLogin bool
+	// zeroCopy specifies if the data of packets read is returned without copying it. See Dialer.ZeroCopy.
+	zeroCopy bool
+	// compressionFallback specifies if batches that cannot be decoded using the compression algorithm they are
+	// prefixed with are decoded as if they lacked the prefix. See Dialer.CompressionFallback.
+	compressionFallback bool
+	// mtu is the MTU negotiated by RakNet for connections obtained using a Dialer. It is 0 if unknown.
+	mtu int
+	// decodeFilter holds the IDs of the packets decoded when read, as set using SetDecodeFilter. If nil, all
+	// packets are decoded.
+	decodeFilter atomic.Pointer[map[uint32]struct{}]
 
 	identityData login.IdentityData
 	clientData   login.ClientData
+	// rawChainData and rawClientData hold the certificate chain JSON and the client data JWT of the login
+	// request sent by the client, exactly as they were found in the request.
+	rawChainData, rawClientData []byte
 
+	// gameDataMu guards gameData, which may be changed using SetGameData while the connection is handling
+	// packets.
+	gameDataMu       sync.Mutex
 	gameData         GameData
 	gameDataReceived atomic.Bool
 
@@ -93,13 +142,26 @@ type Conn struct {
 	// were not used by the connection yet. These packets are read the first when calling to Read or
 	// ReadPacket after being connected.
 	deferredPackets []*packetData
-	readDeadline    <-chan time.Time
+	readDeadline    deadline
+	// writeDeadline holds the time.Time set using SetWriteDeadline. It is restored on the underlying net.Conn
+	// after a flush using a context with an earlier deadline.
+	writeDeadline atomic.Value
+
+	// pauseMu guards resumeReads, which is non-nil while reads are paused using PauseReads. It is closed by
+	// ResumeReads.
+	pauseMu     sync.Mutex
+	resumeReads chan struct{}
 
 	sendMu sync.Mutex
 	// bufferedSend is a slice of byte slices containing packets that are 'written'. They are buffered until
 	// they are sent each 20th of a second.
 	bufferedSend [][]byte
-	hdr          *packet.Header
+	// bufferedLen is the total length of the packets in bufferedSend.
+	bufferedLen int
+	// batchTarget is the size in bytes of the packets buffered at which they are flushed, as set using
+	// SetBatchTarget. If 0, packets are only flushed at the flush interval.
+	batchTarget atomic.Int64
+	hdr         *packet.Header
 
 	// readyToLogin is a bool indicating if the connection is ready to login. This is used to ensure that the client
 	// has received the relevant network settings before the login sequence starts.
@@ -111,11 +173,22 @@ type Conn struct {
 	// the world: It is completing a sequence that will result in the spawning.
 	spawn           chan struct{}
 	waitingForSpawn atomic.Bool
+	// spawned is set to true right before spawn is closed, once the spawn sequence of the connection is
+	// completed. Packets are only passed to the packet filter after that, so that the packets written by
+	// the Conn itself during the login and spawn sequence are never filtered.
+	spawned atomic.Bool
 
 	// expectedIDs is a slice of packet identifiers that are next expected to arrive, until the connection is
 	// logged in.
 	expectedIDs atomic.Value
 
+	// flushRates is used to pass a new flush rate set using SetFlushInterval to the goroutine flushing
+	// packets.
+	flushRates chan time.Duration
+	// phase is the phase of the login sequence a client sided connection is currently in. It is one of the
+	// phase constants and is used to identify the phase in which a handshake timed out.
+	phase atomic.Value
+
 	packMu sync.Mutex
 	// resourcePacks is a slice of resource packs that the listener may hold. Each client will be asked to
 	// download these resource packs upon joining.
@@ -127,9 +200,18 @@ type Conn struct {
 	// downloadResourcePack is an optional function passed to a Dial() call. If set, each resource pack received
 	// from the server will call this function to see if it should be downloaded or not.
 	downloadResourcePack func(id uuid.UUID, version string, currentPack, totalPacks int) bool
+	// acceptResourcePack is an optional function passed to a Dial() call. If set, resource packs offered by
+	// the server for which it returns false are refused.
+	acceptResourcePack func(pack protocol.TexturePackInfo) bool
+	// resourcePackWriter is an optional function passed to a Dial() call. If set, the data of resource packs
+	// downloaded is written to the io.WriteCloser it returns, rather than being read into a resource.Pack.
+	resourcePackWriter func(id uuid.UUID, version string, size uint64) (io.WriteCloser, error)
 	// fetchResourcePacks is an optional function passed to a Listener. If set, the returned resource packs from the function
 	// will determine which resource packs to send to the client based on its identity and client data.
 	fetchResourcePacks func(identityData login.IdentityData, clientData login.ClientData, current []*resource.Pack) []*resource.Pack
+	// onLogin is an optional function passed to a Listener. If set, it is called once the login request of the
+	// client is verified, and the client is disconnected if it returns an error.
+	onLogin func(identityData login.IdentityData, clientData login.ClientData) error
 	// ignoredResourcePacks is a slice of resource packs that are not being downloaded due to the downloadResourcePack
 	// func returning false for the specific pack.
 	ignoredResourcePacks []exemptedResourcePack
@@ -139,10 +221,38 @@ type Conn struct {
 	// packetFunc is an optional function passed to a Dial() call. If set, each packet read from and written
 	// to this connection will call this function.
 	packetFunc func(header packet.Header, payload []byte, src, dst net.Addr)
+	// packetFilter is an optional function passed to a Dial() call or Listener. If set, each packet read
+	// using ReadPacket and each packet written after the connection has spawned is passed to it, so that it
+	// may be modified or dropped.
+	packetFilter func(pk packet.Packet, incoming bool) (packet.Packet, bool, error)
+	// transferFunc is an optional function passed to a Dial() call. If set, it is called for each Transfer
+	// packet read using ReadPacket, so that the transfer may be redirected or vetoed.
+	transferFunc func(address string, port uint16) (string, uint16, bool)
 
 	shieldID atomic.Int32
 
+	latencyMu sync.Mutex
+	// latencyProbes holds the times at which NetworkStackLatency packets that need a response were sent,
+	// indexed by their timestamp. It is used to measure the latency if the underlying connection does not
+	// measure it itself.
+	latencyProbes map[int64]time.Time
+	// latency is the latency last measured using NetworkStackLatency packets.
+	latency atomic.Int64
+
+	// customPackets holds custom packets passed to a Dialer or ListenConfig. They are added to the pool
+	// whenever it is set.
+	customPackets packet.Pool
+
 	additional chan packet.Packet
+	// additionalData holds the data of the packet that the packets in additional were converted from.
+	additionalData []byte
+
+	// batchForwarding specifies if batches read from the connection are passed to ReadBatch as-is, rather
+	// than being decoded into packets. batches holds the batches read while batchForwarding is true.
+	batchForwarding atomic.Bool
+	batches         chan []byte
+
+	stats connStats
 }
 
 // newConn creates a new Minecraft connection for the net.Conn passed, reading and writing compressed
@@ -151,11 +261,12 @@ type Conn struct {
 // key is generated.
 func newConn(netConn net.Conn, key *ecdsa.PrivateKey, log *slog.Logger, proto Protocol, flushRate time.Duration, limits bool) *Conn {
 	conn := &Conn{
-		enc:          packet.NewEncoder(netConn),
 		dec:          packet.NewDecoder(netConn),
 		salt:         make([]byte, 16),
 		packets:      make(chan *packetData, 8),
 		additional:   make(chan packet.Packet, 16),
+		batches:      make(chan []byte, 8),
+		flushRates:   make(chan time.Duration),
 		spawn:        make(chan struct{}),
 		conn:         netConn,
 		privateKey:   key,
@@ -164,6 +275,9 @@ func newConn(netConn net.Conn, key *ecdsa.PrivateKey, log *slog.Logger, proto Pr
 		proto:        proto,
 		readerLimits: limits,
 	}
+	// Bytes written are counted as they are written to the net.Conn, so that the size of the batches after
+	// compression and encryption is counted.
+	conn.enc = packet.NewEncoder(statsWriter{w: netConn, n: &conn.stats.bytesWritten})
 
 	if c, ok := netConn.(interface{ Context() context.Context }); ok {
 		conn.ctx, conn.cancelFunc = context.WithCancelCause(c.Context())
@@ -179,20 +293,134 @@ func newConn(netConn net.Conn, key *ecdsa.PrivateKey, log *slog.Logger, proto Pr
 
 	conn.expectedIDs.Store([]uint32{packet.IDLogin, packet.IDRequestNetworkSettings})
 
-	if flushRate <= 0 {
-		return conn
+	go conn.flushLoop(flushRate)
+	return conn
+}
+
+// flushLoop flushes the packets buffered by the connection every flushRate until the connection is closed.
+// If flushRate is 0 or lower, packets are not flushed automatically. The rate may be changed using
+// SetFlushInterval.
+func (conn *Conn) flushLoop(flushRate time.Duration) {
+	var ticker *time.Ticker
+	stop := func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
 	}
-	go func() {
-		ticker := time.NewTicker(flushRate)
-		defer ticker.Stop()
-		for range ticker.C {
+	defer stop()
+	for {
+		var tick <-chan time.Time
+		if flushRate > 0 {
+			if ticker == nil {
+				ticker = time.NewTicker(flushRate)
+			}
+			tick = ticker.C
+		}
+		select {
+		case <-conn.ctx.Done():
+			return
+		case flushRate = <-conn.flushRates:
+			stop()
+			ticker = nil
+		case <-tick:
 			if err := conn.Flush(); err != nil {
-				_ = conn.close(err)
+				// Flush closes the connection if flushing fails.
 				return
 			}
 		}
-	}()
-	return conn
+	}
+}
+
+// SetBatchTarget sets the size in bytes that the packets written to the Conn are buffered up to before they
+// are flushed in a single batch. Packets are flushed as soon as they reach the target, and packets already
+// buffered are flushed first if writing a packet would make them exceed it, so that batches are close to
+// the target in size. Larger batches compress better, which benefits traffic such as chunks, at the cost of
+// latency. Packets are still flushed at the flush interval, so the target is typically combined with a
+// longer interval set using SetFlushInterval, which bounds the latency added. Packets implementing
+// packet.Immediate are still sent immediately in a batch of their own. If n is 0 or lower, the batch target
+// is removed and packets are only flushed at the flush interval or by calling Flush.
+func (conn *Conn) SetBatchTarget(n int) {
+	conn.batchTarget.Store(int64(max(n, 0)))
+}
+
+// SetFlushInterval changes the interval at which packets written to the Conn are flushed automatically,
+// which is initially the FlushRate of the Dialer or ListenConfig. Packets written within the same interval
+// are sent together in a single batch, which reduces the amount of writes to the network and improves the
+// compression ratio, at the cost of latency. If d is 0 or lower, packets are no longer flushed
+// automatically and Flush must be called to send them.
+func (conn *Conn) SetFlushInterval(d time.Duration) {
+	select {
+	case <-conn.ctx.Done():
+	case conn.flushRates <- d:
+	}
+}
+
+// PauseReads pauses reading from the underlying net.Conn until ResumeReads is called. At most one more batch
+// of packets is read from the net.Conn, which is held without being handled until reads are resumed. No
+// further data is read, so that the flow control of the net.Conn, if any, slows down the other end of the
+// connection instead of packets accumulating in memory.
+// Packets already received are not dropped: They are still returned by ReadPacket, ReadBytes and ReadBatch,
+// which block once no packets are left until reads are resumed, the read deadline is reached or the Conn is
+// closed. Pausing reads of a Conn that is not yet logged in or spawned pauses its login sequence. Calling
+// PauseReads while reads are already paused has no effect.
+func (conn *Conn) PauseReads() {
+	conn.pauseMu.Lock()
+	defer conn.pauseMu.Unlock()
+	if conn.resumeReads == nil {
+		conn.resumeReads = make(chan struct{})
+	}
+}
+
+// ResumeReads resumes reading from the underlying net.Conn after it was paused using PauseReads. Calling
+// ResumeReads while reads are not paused has no effect.
+func (conn *Conn) ResumeReads() {
+	conn.pauseMu.Lock()
+	defer conn.pauseMu.Unlock()
+	if conn.resumeReads != nil {
+		close(conn.resumeReads)
+		conn.resumeReads = nil
+	}
+}
+
+// waitForResume blocks until reads are resumed if they were paused using PauseReads. An error is returned if
+// the Conn is closed while waiting.
+func (conn *Conn) waitForResume() error {
+	conn.pauseMu.Lock()
+	resume := conn.resumeReads
+	conn.pauseMu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-conn.ctx.Done():
+		return conn.wrap(net.ErrClosed, "read batch")
+	}
+}
+
+// SetDecodeFilter limits the packets decoded by ReadPacket to those with one of the packet IDs passed.
+// Packets with any other ID are returned as a *packet.Unknown holding the raw payload of the packet, without
+// decoding its fields, which saves the cost of decoding packets the caller is not interested in. These
+// packets are not converted by the Protocol of the Conn, so the IDs passed are those of the protocol version
+// of the Conn. Packets handled by the Conn itself, such as those of the login sequence, are always decoded.
+// Calling SetDecodeFilter without IDs removes the filter, so that all packets are decoded again.
+func (conn *Conn) SetDecodeFilter(ids ...uint32) {
+	if len(ids) == 0 {
+		conn.decodeFilter.Store(nil)
+		return
+	}
+	filter := make(map[uint32]struct{}, len(ids))
+	for _, id := range ids {
+		filter[id] = struct{}{}
+	}
+	conn.decodeFilter.Store(&filter)
+}
+
+// Stats returns a snapshot of the statistics of the Conn, such as the amount of packets and bytes read and
+// written. Stats is safe to call concurrently with reading and writing packets.
+func (conn *Conn) Stats() ConnStats {
+	return conn.stats.snapshot()
 }
 
 // IdentityData returns the identity data of the connection. It holds the UUID, XUID and username of the
@@ -208,6 +436,21 @@ func (conn *Conn) ClientData() login.ClientData {
 	return conn.clientData
 }
 
+// RawChainData returns the raw JSON holding the certificate chain of the login request of the connection,
+// exactly as it was sent by the client. A proxy may use it, together with RawClientData, to pass on the
+// authentication of the client to another server. RawChainData returns nil if the login request has not
+// yet been sent or received. The byte slice returned is a copy and may be modified freely.
+func (conn *Conn) RawChainData() []byte {
+	return bytes.Clone(conn.rawChainData)
+}
+
+// RawClientData returns the raw JWT holding the ClientData of the login request of the connection, exactly
+// as it was sent by the client. RawClientData returns nil if the login request has not yet been sent or
+// received. The byte slice returned is a copy and may be modified freely.
+func (conn *Conn) RawClientData() []byte {
+	return bytes.Clone(conn.rawClientData)
+}
+
 // Authenticated returns true if the connection was authenticated through XBOX Live services.
 func (conn *Conn) Authenticated() bool {
 	return conn.IdentityData().XUID != ""
@@ -216,8 +459,34 @@ func (conn *Conn) Authenticated() bool {
 // GameData returns specific game data set to the connection for the player to be initialised with. If the
 // Conn is obtained using Listen, this game data may be set to the Listener. If obtained using Dial, the data
 // is obtained from the server.
+// The GameData returned is a copy: Changing it does not affect the Conn until it is passed to SetGameData.
 func (conn *Conn) GameData() GameData {
-	return conn.gameData
+	conn.gameDataMu.Lock()
+	defer conn.gameDataMu.Unlock()
+
+	data := conn.gameData
+	data.GameRules = slices.Clone(data.GameRules)
+	data.CustomBlocks = slices.Clone(data.CustomBlocks)
+	data.Items = slices.Clone(data.Items)
+	data.Experiments = slices.Clone(data.Experiments)
+	return data
+}
+
+// SetGameData replaces the game data of the connection. It may be used to change the game data obtained
+// from the server, such as the entity runtime ID or world seed, before the player is spawned. For a Conn
+// obtained using a Listener, a StartGame packet sent using StartGame will hold the data passed.
+// SetGameData returns an error if the connection has already finished its spawn sequence.
+func (conn *Conn) SetGameData(data GameData) error {
+	conn.gameDataMu.Lock()
+	defer conn.gameDataMu.Unlock()
+
+	select {
+	case <-conn.spawn:
+		return conn.wrap(fmt.Errorf("game data cannot be changed after spawning"), "set game data")
+	default:
+	}
+	conn.gameData = data
+	return nil
 }
 
 // Proto returns the protocol of the connection.
@@ -250,15 +519,19 @@ func (conn *Conn) StartGameTimeout(data GameData, timeout time.Duration) error {
 // StartGameContext should be called for a Conn obtained using a minecraft.Listener. The game data passed will
 // be used to spawn the player in the world of the server. To spawn a Conn obtained from a call to
 // minecraft.Dial(), use Conn.DoSpawn().
+// StartGameContext returns once the client has sent the SetLocalPlayerAsInitialised packet. If ctx is done
+// first, a *SpawnError identifying the step of the spawn sequence that was not completed is returned.
 func (conn *Conn) StartGameContext(ctx context.Context, data GameData) error {
 	if conn.gameDataReceived.Load() {
 		panic("(*Conn).StartGame must only be called on Listener connections")
 	}
+	conn.gameDataMu.Lock()
 	if data.WorldName == "" {
 		data.WorldName = conn.gameData.WorldName
 	}
-
 	conn.gameData = data
+	conn.gameDataMu.Unlock()
+
 	for _, item := range data.Items {
 		if item.Name == "minecraft:shield" {
 			conn.shieldID.Store(int32(item.RuntimeID))
@@ -271,7 +544,7 @@ func (conn *Conn) StartGameContext(ctx context.Context, data GameData) error {
 	case <-conn.ctx.Done():
 		return conn.closeErr("start game")
 	case <-ctx.Done():
-		return conn.wrap(ctx.Err(), "start game")
+		return conn.wrap(conn.spawnError(ctx.Err()), "start game")
 	case <-conn.spawn:
 		// Conn was spawned successfully.
 		return nil
@@ -305,13 +578,15 @@ func (conn *Conn) DoSpawnTimeout(timeout time.Duration) error {
 // DoSpawnContext should be called for a Conn obtained using minecraft.Dial(). Use Conn.StartGame to spawn a
 // Conn obtained using a minecraft.Listener.
 // DoSpawnContext will start the spawning sequence using the game data found in conn.GameData(), which was
-// sent earlier by the server.
+// sent earlier by the server. DoSpawnContext returns once the player is spawned and the client has sent the
+// SetLocalPlayerAsInitialised packet, after which packets such as PlayerAuthInput may be sent. If ctx is done
+// first, a *SpawnError identifying the step of the spawn sequence that was not completed is returned.
 func (conn *Conn) DoSpawnContext(ctx context.Context) error {
 	select {
 	case <-conn.ctx.Done():
 		return conn.closeErr("do spawn")
 	case <-ctx.Done():
-		return conn.wrap(ctx.Err(), "do spawn")
+		return conn.wrap(conn.spawnError(ctx.Err()), "do spawn")
 	case <-conn.spawn:
 		// Conn was spawned successfully.
 		return nil
@@ -320,35 +595,159 @@ func (conn *Conn) DoSpawnContext(ctx context.Context) error {
 
 // WritePacket encodes the packet passed and writes it to the Conn. The encoded data is buffered until the
 // next 20th of a second, after which the data is flushed and sent over the connection.
+// If the packet implements packet.Immediate and its Immediate method returns true, the packets buffered are
+// flushed first, after which the packet is sent immediately in an uncompressed batch of its own. If sending
+// either batch fails, the Conn is closed and a *FlushError is returned, like for Flush.
 func (conn *Conn) WritePacket(pk packet.Packet) error {
+	return conn.writePacket(pk, 0, 0)
+}
+
+// WritePacketSubClient writes a packet to the Conn like WritePacket, but sets the sender and target sub
+// client IDs in the header of the packet. These IDs are used for split screen: The main client has sub
+// client ID 0, while the split screen players have IDs 1 to 3. An error is returned if either ID is higher
+// than 3.
+func (conn *Conn) WritePacketSubClient(pk packet.Packet, senderSubClient, targetSubClient byte) error {
+	if senderSubClient > 3 || targetSubClient > 3 {
+		return conn.wrap(fmt.Errorf("sub client IDs must be between 0 and 3, got sender %v and target %v", senderSubClient, targetSubClient), "write packet")
+	}
+	return conn.writePacket(pk, senderSubClient, targetSubClient)
+}
+
+// WritePackets writes all packets passed to the Conn, as if WritePacket was called for each of them in
+// order, but acquires the lock guarding the packets buffered only once. This reduces lock contention when
+// writing many packets at once. Packets implementing packet.Immediate are handled as they are by
+// WritePacket, and packets dropped by the packet filter are not written. The first error encountered is
+// returned, after which the remaining packets are not written.
+func (conn *Conn) WritePackets(pks ...packet.Packet) error {
+	return conn.writePackets(pks, 0, 0)
+}
+
+// writePacket writes a packet to the Conn with the sender and target sub client IDs passed.
+func (conn *Conn) writePacket(pk packet.Packet, senderSubClient, targetSubClient byte) error {
+	return conn.writePackets([]packet.Packet{pk}, senderSubClient, targetSubClient)
+}
+
+// writePackets writes packets to the Conn with the sender and target sub client IDs passed, holding the
+// send lock while writing all of them.
+func (conn *Conn) writePackets(pks []packet.Packet, senderSubClient, targetSubClient byte) error {
 	select {
 	case <-conn.ctx.Done():
 		return conn.closeErr("write packet")
 	default:
 	}
+	var filterErr error
+	if conn.packetFilter != nil && conn.spawned.Load() {
+		filtered := make([]packet.Packet, 0, len(pks))
+		for _, pk := range pks {
+			modified, drop, err := conn.packetFilter(pk, false)
+			if err != nil {
+				// The packets before the one that failed are still written, like they would be when
+				// calling WritePacket for each packet.
+				filterErr = conn.wrap(fmt.Errorf("filter packet: %w", err), "write packet")
+				break
+			}
+			if drop {
+				continue
+			}
+			if modified != nil {
+				pk = modified
+			}
+			filtered = append(filtered, pk)
+		}
+		pks = filtered
+	}
+	if err := conn.sendPackets(pks, senderSubClient, targetSubClient); err != nil {
+		return err
+	}
+	return filterErr
+}
+
+// sendPackets buffers the packets passed to be sent, without passing them to the packet filter. Packets
+// implementing packet.Immediate are sent immediately.
+func (conn *Conn) sendPackets(pks []packet.Packet, senderSubClient, targetSubClient byte) error {
+	var err error
 	conn.sendMu.Lock()
-	defer conn.sendMu.Unlock()
+	for _, pk := range pks {
+		if i, ok := pk.(packet.Immediate); !ok || !i.Immediate() {
+			if err = conn.bufferPacket(pk, senderSubClient, targetSubClient); err != nil {
+				break
+			}
+			continue
+		}
+		// Packets buffered before pk are flushed in a batch of their own first, so that pk is never sent
+		// ahead of them. pk is sent in a batch of its own regardless of the batch target.
+		if err = conn.flush(); err != nil {
+			break
+		}
+		conn.bufferedSend = append(conn.bufferedSend, conn.encodePacket(pk, senderSubClient, targetSubClient)...)
+		if err = conn.flushUsing(conn.enc.EncodeUncompressed); err != nil {
+			break
+		}
+	}
+	conn.sendMu.Unlock()
+	if err != nil {
+		return conn.flushFailed(err, "write packet")
+	}
+	return nil
+}
 
+// bufferPacket encodes the packet passed and adds it to the packets buffered to be sent in the next batch.
+// sendMu must be held while calling bufferPacket.
+func (conn *Conn) bufferPacket(pk packet.Packet, senderSubClient, targetSubClient byte) error {
+	for _, b := range conn.encodePacket(pk, senderSubClient, targetSubClient) {
+		if err := conn.buffer(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buffer adds the encoded packet passed to the packets buffered. If a batch target is set, the packets
+// already buffered are flushed first if adding the packet would make them exceed the target, and the packets
+// buffered are flushed after adding the packet if they reach the target. sendMu must be held while calling
+// buffer.
+func (conn *Conn) buffer(b []byte) error {
+	target := int(conn.batchTarget.Load())
+	if target > 0 && conn.bufferedLen > 0 && conn.bufferedLen+len(b) > target {
+		if err := conn.flush(); err != nil {
+			return err
+		}
+	}
+	conn.bufferedSend = append(conn.bufferedSend, b)
+	conn.bufferedLen += len(b)
+	if target > 0 && conn.bufferedLen >= target {
+		return conn.flush()
+	}
+	return nil
+}
+
+// encodePacket encodes the packet passed, converted for the protocol of the Conn, and returns the encoded
+// packets. sendMu must be held while calling encodePacket.
+func (conn *Conn) encodePacket(pk packet.Packet, senderSubClient, targetSubClient byte) [][]byte {
 	buf := internal.BufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		// Reset the buffer, so we can return it to the buffer pool safely.
 		buf.Reset()
 		internal.BufferPool.Put(buf)
 	}()
+	conn.observeLatency(pk, true)
 
-	conn.hdr.PacketID = pk.ID()
-	_ = conn.hdr.Write(buf)
-	l := buf.Len()
-
+	conn.hdr.SenderSubClient, conn.hdr.TargetSubClient = senderSubClient, targetSubClient
+	var encoded [][]byte
 	for _, converted := range conn.proto.ConvertFromLatest(pk, conn) {
+		// Each converted packet is encoded with its own header, as its ID may differ from that of pk.
+		buf.Reset()
+		conn.hdr.PacketID = converted.ID()
+		_ = conn.hdr.Write(buf)
+		l := buf.Len()
 		converted.Marshal(conn.proto.NewWriter(buf, conn.shieldID.Load()))
 
 		if conn.packetFunc != nil {
 			conn.packetFunc(*conn.hdr, buf.Bytes()[l:], conn.LocalAddr(), conn.RemoteAddr())
 		}
-		conn.bufferedSend = append(conn.bufferedSend, append([]byte(nil), buf.Bytes()...))
+		encoded = append(encoded, append([]byte(nil), buf.Bytes()...))
 	}
-	return nil
+	return encoded
 }
 
 // ReadPacket reads a packet from the Conn, depending on the packet ID that is found in front of the packet
@@ -356,45 +755,150 @@ func (conn *Conn) WritePacket(pk packet.Packet) error {
 // received. ReadPacket must not be called on multiple goroutines simultaneously.
 //
 // If the packet read was not implemented, a *packet.Unknown is returned, containing the raw payload of the
-// packet read.
+// packet read. Packets that fail to decode are logged as a *DecodeError and skipped, unless the Conn is
+// configured to disconnect on invalid packets. In that case, the Conn is closed and ReadPacket returns an
+// error wrapping the *DecodeError, which may be obtained using errors.As. Errors returned by the Conn after
+// that wrap the *DecodeError too.
 func (conn *Conn) ReadPacket() (pk packet.Packet, err error) {
-	if len(conn.additional) > 0 {
-		return <-conn.additional, nil
-	}
-	if data, ok := conn.takeDeferredPacket(); ok {
-		pk, err := data.decode(conn)
-		if err != nil {
-			conn.log.Error("read packet: " + err.Error())
-			return conn.ReadPacket()
+	pk, _, err = conn.ReadPacketAndBytes()
+	return pk, err
+}
+
+// ReadPacketAndBytes reads a packet from the Conn like ReadPacket, but additionally returns the exact data
+// the packet was decoded from: The decompressed and decrypted packet, including its header. The byte slice
+// returned is a copy and may be retained by the caller, unless zero-copy reads were enabled using
+// Dialer.ZeroCopy or ListenConfig.ZeroCopy, in which case it is only valid until the next call to
+// ReadPacket.
+// If a single packet read was converted into multiple packets for the protocol version of the Conn, each of
+// these packets is returned with the same data. The data remains that of the packet as read, even if the
+// packet was modified by a packet filter.
+func (conn *Conn) ReadPacketAndBytes() (pk packet.Packet, data []byte, err error) {
+	for {
+		if pk, data, err = conn.readPacket(); err != nil {
+			return pk, data, err
 		}
-		if len(pk) == 0 {
-			return conn.ReadPacket()
+		if conn.packetFilter != nil {
+			modified, drop, err := conn.packetFilter(pk, true)
+			if err != nil {
+				return nil, nil, conn.wrap(fmt.Errorf("filter packet: %w", err), "read packet")
+			}
+			if drop {
+				continue
+			}
+			if modified != nil {
+				pk = modified
+			}
 		}
-		for _, additional := range pk[1:] {
-			conn.additional <- additional
+		if transfer, ok := pk.(*packet.Transfer); ok && conn.transferFunc != nil {
+			address, port, follow := conn.transferFunc(transfer.Address, transfer.Port)
+			if !follow {
+				continue
+			}
+			// The packet is copied so that a packet returned by the packet filter is not modified.
+			pk = &packet.Transfer{Address: address, Port: port, ReloadWorld: transfer.ReloadWorld}
 		}
-		return pk[0], nil
+		return pk, data, nil
+	}
+}
+
+// ReadPacketWithHeader reads a packet from the Conn like ReadPacket, but additionally returns the header
+// that the packet was sent with, which holds the sender and target sub client IDs used for split screen.
+// The PacketID of the header is the ID of the packet as it was sent, which may differ from the ID of the
+// packet returned if it was converted for the protocol version of the Conn.
+func (conn *Conn) ReadPacketWithHeader() (pk packet.Packet, h packet.Header, err error) {
+	pk, data, err := conn.ReadPacketAndBytes()
+	if err != nil {
+		return nil, h, err
+	}
+	// The header was already read successfully when the packet was decoded, so it cannot fail here.
+	_ = h.Read(bytes.NewBuffer(data))
+	return pk, h, nil
+}
+
+// readPacket reads the next packet from the Conn, without passing it to the packet filter. It returns a copy
+// of the data that the packet was decoded from.
+func (conn *Conn) readPacket() (pk packet.Packet, data []byte, err error) {
+	if len(conn.additional) > 0 {
+		return <-conn.additional, conn.additionalData, nil
+	}
+	if pd, ok := conn.takeDeferredPacket(); ok {
+		return conn.decodePacket(pd)
 	}
 
 	select {
 	case <-conn.ctx.Done():
-		return nil, conn.closeErr("read packet")
-	case <-conn.readDeadline:
-		return nil, conn.wrap(context.DeadlineExceeded, "read packet")
-	case data := <-conn.packets:
-		pk, err := data.decode(conn)
-		if err != nil {
-			conn.log.Error("read packet: " + err.Error())
-			return conn.ReadPacket()
+		return nil, nil, conn.closeErr("read packet")
+	case <-conn.readDeadline.wait():
+		return nil, nil, conn.wrap(context.DeadlineExceeded, "read packet")
+	case pd := <-conn.packets:
+		return conn.decodePacket(pd)
+	}
+}
+
+// setPool sets the packet.Pool used to decode packets read to the pool passed, with the custom packets of
+// the Conn added to it.
+func (conn *Conn) setPool(pool packet.Pool) {
+	if len(conn.customPackets) != 0 {
+		// The pool may be shared by the Protocol, so we make sure not to modify it.
+		pool = maps.Clone(pool)
+		maps.Copy(pool, conn.customPackets)
+	}
+	conn.pool = pool
+}
+
+// checkPackets checks if the custom packets passed may be added to the pool passed, returning an error if
+// one of the packets has an ID already present in the pool and override is false. The pool passed is not
+// modified, as it may be shared by the Protocol that returned it.
+func checkPackets(pool, custom packet.Pool, override bool) error {
+	pool = maps.Clone(pool)
+	for id, pk := range custom {
+		if err := pool.Register(id, pk, override); err != nil {
+			return err
 		}
-		if len(pk) == 0 {
-			return conn.ReadPacket()
+	}
+	return nil
+}
+
+// decodePacket decodes the packetData passed and returns the first packet decoded. Any further packets that
+// resulted from converting the packet are returned by subsequent calls to readPacket. If the packet could
+// not be decoded, the next packet is read instead.
+func (conn *Conn) decodePacket(pd *packetData) (packet.Packet, []byte, error) {
+	if filter := conn.decodeFilter.Load(); filter != nil {
+		if _, ok := (*filter)[pd.h.PacketID]; !ok {
+			// The packet is not decoded, but returned with its raw payload as if it was not implemented.
+			data := conn.packetBytes(pd)
+			conn.additionalData = data
+			return &packet.Unknown{PacketID: pd.h.PacketID, Payload: data[len(data)-pd.payload.Len():]}, data, nil
 		}
-		for _, additional := range pk[1:] {
-			conn.additional <- additional
+	}
+	pks, err := pd.decode(conn)
+	if err != nil {
+		var unknown unknownPacketError
+		if conn.disconnectOnInvalidPacket || errors.As(err, &unknown) {
+			// The Conn was closed because of the error, so it is returned rather than reading the next packet.
+			return nil, nil, conn.wrap(err, "read packet")
 		}
-		return pk[0], nil
+		conn.log.Error("read packet: " + err.Error())
+		return conn.readPacket()
+	}
+	if len(pks) == 0 {
+		return conn.readPacket()
+	}
+	data := conn.packetBytes(pd)
+	conn.additionalData = data
+	for _, additional := range pks[1:] {
+		conn.additional <- additional
 	}
+	return pks[0], data, nil
+}
+
+// packetBytes returns the full data of the packetData passed, which is copied unless zero-copy reads are
+// enabled.
+func (conn *Conn) packetBytes(pd *packetData) []byte {
+	if conn.zeroCopy {
+		return pd.full
+	}
+	return slices.Clone(pd.full)
 }
 
 // ResourcePacks returns a slice of all resource packs the connection holds. For a Conn obtained using a
@@ -408,12 +912,99 @@ func (conn *Conn) ResourcePacks() []*resource.Pack {
 // tick, after which it is flushed to the connection. Write returns the amount of bytes written n.
 func (conn *Conn) Write(b []byte) (n int, err error) {
 	conn.sendMu.Lock()
-	defer conn.sendMu.Unlock()
-
-	conn.bufferedSend = append(conn.bufferedSend, b)
+	err = conn.buffer(b)
+	conn.sendMu.Unlock()
+	if err != nil {
+		return 0, conn.flushFailed(err, "write")
+	}
 	return len(b), nil
 }
 
+// WriteRawPacket writes a packet with the ID and payload passed to the Conn, without encoding a
+// packet.Packet. A header holding the ID passed and sub client IDs of 0 is written in front of the payload.
+// Like packets written using WritePacket, the packet is buffered until the next flush and is compressed
+// and encrypted with the rest of the batch. The payload is neither converted for the protocol of the Conn
+// nor passed to the packet filter, so WriteRawPacket may be used to forward a *packet.Unknown exactly as it
+// was read, or to write deliberately malformed packets.
+func (conn *Conn) WriteRawPacket(id uint32, payload []byte) error {
+	select {
+	case <-conn.ctx.Done():
+		return conn.closeErr("write packet")
+	default:
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(payload)+5))
+	hdr := packet.Header{PacketID: id}
+	_ = hdr.Write(buf)
+	buf.Write(payload)
+
+	conn.sendMu.Lock()
+	if conn.packetFunc != nil {
+		conn.packetFunc(hdr, payload, conn.LocalAddr(), conn.RemoteAddr())
+	}
+	err := conn.buffer(buf.Bytes())
+	// flushFailed closes the Conn, which locks sendMu, so it must be released first.
+	conn.sendMu.Unlock()
+	if err != nil {
+		return conn.flushFailed(err, "write packet")
+	}
+	return nil
+}
+
+// ForwardPacket writes a packet read from another Conn to the Conn like WritePacket, but reuses the data it
+// was decoded from, as returned by ReadPacketAndBytes, instead of encoding the packet again. This saves the
+// cost of encoding packets that a proxy forwards without changing them. The data is copied, so it may be
+// reused after ForwardPacket returns, and is compressed and encrypted with the rest of the batch like any
+// other packet written.
+// The data is only reused if it is non-nil and holds a packet with the ID of the packet passed. It must be
+// data that the packet passed was decoded from, and the packet must not have been modified after it was
+// read, as the changes would otherwise be lost. As the data is not converted, it must also have been read
+// from a Conn with the same protocol version as the Conn. If the data may not be reused, for example because
+// the packet filter of the Conn replaced the packet, or if the packet implements packet.Immediate, the
+// packet is encoded and written as it would be by WritePacket.
+func (conn *Conn) ForwardPacket(pk packet.Packet, raw []byte) error {
+	if raw == nil {
+		return conn.WritePacket(pk)
+	}
+	if i, ok := pk.(packet.Immediate); ok && i.Immediate() {
+		return conn.WritePacket(pk)
+	}
+	buf := bytes.NewReader(raw)
+	var hdr packet.Header
+	if err := hdr.Read(buf); err != nil || hdr.PacketID != pk.ID() {
+		return conn.WritePacket(pk)
+	}
+	if conn.packetFilter != nil && conn.spawned.Load() {
+		modified, drop, err := conn.packetFilter(pk, false)
+		if err != nil {
+			return conn.wrap(fmt.Errorf("filter packet: %w", err), "write packet")
+		}
+		if drop {
+			return nil
+		}
+		if modified != nil && modified != pk {
+			// The packet passed was replaced, so the data can no longer be reused. The filter was already
+			// called, so the new packet is not passed to it again.
+			return conn.sendPackets([]packet.Packet{modified}, 0, 0)
+		}
+	}
+	select {
+	case <-conn.ctx.Done():
+		return conn.closeErr("write packet")
+	default:
+	}
+	conn.sendMu.Lock()
+	conn.observeLatency(pk, true)
+	if conn.packetFunc != nil {
+		conn.packetFunc(hdr, raw[len(raw)-buf.Len():], conn.LocalAddr(), conn.RemoteAddr())
+	}
+	err := conn.buffer(slices.Clone(raw))
+	conn.sendMu.Unlock()
+	if err != nil {
+		return conn.flushFailed(err, "write packet")
+	}
+	return nil
+}
+
 // ReadBytes reads a packet from the connection without decoding it directly.
 // For direct reading, consider using ReadPacket() which decodes the packet.
 func (conn *Conn) ReadBytes() ([]byte, error) {
@@ -423,7 +1014,7 @@ func (conn *Conn) ReadBytes() ([]byte, error) {
 	select {
 	case <-conn.ctx.Done():
 		return nil, conn.closeErr("read")
-	case <-conn.readDeadline:
+	case <-conn.readDeadline.wait():
 		return nil, conn.wrap(context.DeadlineExceeded, "read")
 	case data := <-conn.packets:
 		return data.full, nil
@@ -443,7 +1034,7 @@ func (conn *Conn) Read(b []byte) (n int, err error) {
 	select {
 	case <-conn.ctx.Done():
 		return 0, conn.closeErr("read")
-	case <-conn.readDeadline:
+	case <-conn.readDeadline.wait():
 		return 0, conn.wrap(context.DeadlineExceeded, "read")
 	case data := <-conn.packets:
 		if len(b) < len(data.full) {
@@ -453,40 +1044,283 @@ func (conn *Conn) Read(b []byte) (n int, err error) {
 	}
 }
 
+// EnableBatchForwarding makes the Conn pass every batch read after the call to ReadBatch, rather than decoding
+// the batch into packets returned by ReadPacket. EnableBatchForwarding allows a proxy that negotiated the same
+// compression on two connections to forward batches between them using ReadBatch and WriteBatch, without
+// decompressing and compressing them again. It should only be called after the connection is spawned, as
+// packets of the login sequence are otherwise never handled. Batch forwarding cannot be disabled after it is
+// enabled.
+//
+// Note that batches forwarded are opaque: Packets in them are not decoded, validated or passed to the
+// PacketFunc of the Conn, and the limits usually applied to decompressed packets do not apply. A proxy
+// forwarding batches therefore cannot filter or inspect any of the packets sent by the other end.
+func (conn *Conn) EnableBatchForwarding() {
+	conn.batchForwarding.Store(true)
+}
+
+// ReadBatch reads a batch from the Conn, which is decrypted but not decompressed. The Compression of the
+// Conn, which was used to compress the batch, is returned with it. ReadBatch only returns batches after
+// EnableBatchForwarding is called. If a read deadline is set, an error is returned if the deadline is reached
+// before any batch is received.
+func (conn *Conn) ReadBatch() ([]byte, packet.Compression, error) {
+	select {
+	case <-conn.ctx.Done():
+		return nil, nil, conn.closeErr("read batch")
+	case <-conn.readDeadline.wait():
+		return nil, nil, conn.wrap(context.DeadlineExceeded, "read batch")
+	case batch := <-conn.batches:
+		return batch, conn.dec.Compression(), nil
+	}
+}
+
+// WriteBatch writes a batch obtained using ReadBatch to the Conn. Packets currently buffered are flushed
+// first, after which the batch is encrypted and sent over the connection directly, without compressing it
+// again. The Compression passed must be the one returned by ReadBatch. WriteBatch returns an error if it does
+// not match the Compression used by the Conn, as the other end would otherwise be unable to decompress the
+// batch. The security implications noted for EnableBatchForwarding apply to WriteBatch too.
+// If flushing the buffered packets or writing the batch fails, the other end can no longer decode the data
+// sent after it, so the Conn is closed and a *FlushError is returned, like for FlushContext.
+func (conn *Conn) WriteBatch(batch []byte, compression packet.Compression) error {
+	select {
+	case <-conn.ctx.Done():
+		return conn.closeErr("write batch")
+	default:
+	}
+	conn.sendMu.Lock()
+	if !sameCompression(compression, conn.enc.Compression()) {
+		conn.sendMu.Unlock()
+		return conn.wrap(fmt.Errorf("batch compression does not match compression of connection"), "write batch")
+	}
+	err := conn.flush()
+	if err == nil {
+		if err = conn.enc.EncodeBatch(batch); errors.Is(err, net.ErrClosed) {
+			err = nil
+		}
+	}
+	// flushFailed closes the Conn, which locks sendMu, so it must be released first.
+	conn.sendMu.Unlock()
+	if err != nil {
+		return conn.flushFailed(err, "write batch")
+	}
+	return nil
+}
+
+// sameCompression checks if two Compressions compress data in the same way, so that a batch compressed by
+// one may be decompressed by the other. Compressions returned by packet.NewOnTheFlyCompression prefix every
+// batch with the algorithm used, so they are compatible with each other regardless of their underlying
+// Compression, but never with a Compression that does not prefix batches.
+func sameCompression(a, b packet.Compression) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	_, aPrefixed := a.(packet.AlgorithmDecompressor)
+	_, bPrefixed := b.(packet.AlgorithmDecompressor)
+	if aPrefixed || bPrefixed {
+		return aPrefixed && bPrefixed
+	}
+	return a.EncodeCompression() == b.EncodeCompression()
+}
+
 // Flush flushes the packets currently buffered by the connections to the underlying net.Conn, so that they
-// are directly sent.
+// are directly sent. Flush is equivalent to calling FlushContext with context.Background().
 func (conn *Conn) Flush() error {
+	return conn.FlushContext(context.Background())
+}
+
+// FlushContext flushes the packets currently buffered by the connection to the underlying net.Conn, like
+// Flush, but stops writing once ctx is done. If the deadline of ctx passes or ctx is cancelled before the
+// buffered packets are written, or if writing them fails otherwise, the other end of the connection can no
+// longer decode the data sent after it. The Conn is therefore closed and a *FlushError is returned, wrapped
+// in a net.OpError, holding the number of bytes that were written.
+func (conn *Conn) FlushContext(ctx context.Context) error {
 	select {
 	case <-conn.ctx.Done():
 		return conn.closeErr("flush")
 	default:
 	}
 	conn.sendMu.Lock()
+	err := conn.flushContext(ctx)
+	conn.sendMu.Unlock()
+
+	if err != nil {
+		return conn.flushFailed(err, "flush")
+	}
+	return nil
+}
+
+// flushFailed closes the connection after flushing failed with the error passed and returns a *FlushError
+// wrapped for the op passed. sendMu must not be held while calling flushFailed.
+func (conn *Conn) flushFailed(err error, op string) error {
+	flushErr := &FlushError{Err: err}
+	if e := (*packet.CompressionError)(nil); errors.As(err, &e) {
+		flushErr.Written, flushErr.Len = e.Written, e.InputLen
+	}
+	_ = conn.close(flushErr)
+	return conn.wrap(flushErr, op)
+}
+
+// flushContext flushes the packets currently buffered by the connection, setting a write deadline on the
+// underlying net.Conn so that writing stops once ctx is done. sendMu must be held while calling
+// flushContext.
+func (conn *Conn) flushContext(ctx context.Context) error {
+	if len(conn.bufferedSend) == 0 || ctx.Done() == nil {
+		return conn.flush()
+	}
+	writeDeadline := conn.writeDeadlineTime()
+	if d, ok := ctx.Deadline(); ok && (writeDeadline.IsZero() || d.Before(writeDeadline)) {
+		_ = conn.conn.SetWriteDeadline(d)
+	}
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		// Setting a deadline in the past makes a write in progress return immediately.
+		_ = conn.conn.SetWriteDeadline(time.Unix(1, 0))
+		close(done)
+	})
+	err := conn.flush()
+	if !stop() {
+		<-done
+	}
+	_ = conn.conn.SetWriteDeadline(writeDeadline)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	return err
+}
+
+// flush flushes the packets currently buffered by the connection. sendMu must be held while calling flush.
+func (conn *Conn) flush() error {
+	return conn.flushUsing(conn.enc.Encode)
+}
+
+// flushUsing flushes the packets currently buffered by the connection by passing them to the encode function
+// passed. sendMu must be held while calling flushUsing.
+func (conn *Conn) flushUsing(encode func(packets [][]byte) error) error {
+	if len(conn.bufferedSend) == 0 {
+		return nil
+	}
+	var err error
+	if d := conn.writeDeadlineTime(); !d.IsZero() && !time.Now().Before(d) {
+		err = os.ErrDeadlineExceeded
+	} else {
+		err = encode(conn.bufferedSend)
+	}
+	if err == nil {
+		countPackets(conn.bufferedSend, &conn.stats.packetsWritten, &conn.stats.bytesUncompressed)
+	}
+
+	// First manually clear out conn.bufferedSend so that re-using the slice after resetting its length to
+	// 0 doesn't result in an 'invisible' memory leak.
+	for i := range conn.bufferedSend {
+		conn.bufferedSend[i] = nil
+	}
+	// Slice the conn.bufferedSend to a length of 0 so we don't have to re-allocate space in this slice
+	// every time.
+	conn.bufferedSend = conn.bufferedSend[:0]
+	conn.bufferedLen = 0
+
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("error encoding packet batch: %w", err)
+	}
+	return nil
+}
+
+// SetCompression changes the compression used for batches sent over the Conn. Packets buffered at the time of
+// calling are flushed using the previous compression first, so that the compression changes between two
+// batches. Because the other end of the connection must be able to tell which compression a batch was
+// compressed with, SetCompression returns an error if the connection does not prefix batches with the
+// compression algorithm used, which is the case for connections below 1.20.60 and connections that have not
+// yet negotiated compression. The other end only accepts batches compressed using the compression negotiated
+// or packet.NopCompression, so SetCompression is only useful to stop compressing batches, or to start
+// compressing them again using the negotiated compression.
+func (conn *Conn) SetCompression(compression packet.Compression) error {
+	select {
+	case <-conn.ctx.Done():
+		return conn.closeErr("set compression")
+	default:
+	}
+	conn.sendMu.Lock()
 	defer conn.sendMu.Unlock()
 
-	if len(conn.bufferedSend) > 0 {
-		if err := conn.enc.Encode(conn.bufferedSend); err != nil && !errors.Is(err, net.ErrClosed) {
-			// Should never happen.
-			return fmt.Errorf("error encoding packet batch: %v", err)
-		}
-		// First manually clear out conn.bufferedSend so that re-using the slice after resetting its length to
-		// 0 doesn't result in an 'invisible' memory leak.
-		for i := range conn.bufferedSend {
-			conn.bufferedSend[i] = nil
-		}
-		// Slice the conn.bufferedSend to a length of 0 so we don't have to re-allocate space in this slice
-		// every time.
-		conn.bufferedSend = conn.bufferedSend[:0]
+	if !conn.onTheFlyCompression {
+		return conn.wrap(fmt.Errorf("compression cannot be changed for connections that do not prefix batches with the compression algorithm"), "set compression")
 	}
+	if err := conn.flush(); err != nil {
+		return err
+	}
+	conn.compression = compression
+	conn.activeCompression.Store(&compression)
+	conn.enc.EnableCompression(packet.NewOnTheFlyCompression(compression))
 	return nil
 }
 
+// Compression returns the compression currently used for batches sent over the Conn. It is available as soon
+// as compression is negotiated through the NetworkSettings packet, which happens early during the login
+// sequence, so Compression may be called before the connection is fully established. Until compression is
+// negotiated, batches are not compressed and packet.NopCompression is returned.
+func (conn *Conn) Compression() packet.Compression {
+	if c := conn.activeCompression.Load(); c != nil {
+		return *c
+	}
+	return packet.NopCompression
+}
+
+// Encrypted returns true if encryption is enabled for the Conn. Encryption is enabled during the login
+// sequence, once the server has sent a ServerToClientHandshake packet, and remains disabled for the entire
+// connection if the login sequence does not include this packet.
+func (conn *Conn) Encrypted() bool {
+	return conn.encryptionKeyLen.Load() != 0
+}
+
+// EncryptionKeySize returns the size in bytes of the key used to encrypt the Conn, or 0 if encryption is not
+// enabled. The key itself is not exposed.
+func (conn *Conn) EncryptionKeySize() int {
+	return int(conn.encryptionKeyLen.Load())
+}
+
+// SecurityInfo returns information on the encryption of the Conn, such as the curve used for the key
+// exchange and the salt of the connection, for example for audit logging. If encryption is not enabled, the
+// Encrypted field of the SecurityInfo returned is false. The key used for encryption is not exposed.
+func (conn *Conn) SecurityInfo() SecurityInfo {
+	if info := conn.securityInfo.Load(); info != nil {
+		info := *info
+		info.Salt = slices.Clone(info.Salt)
+		return info
+	}
+	return SecurityInfo{}
+}
+
 // Close closes the Conn and its underlying connection. Before closing, it also calls Flush() so that any
 // packets currently pending are sent out.
 func (conn *Conn) Close() error {
 	return conn.close(net.ErrClosed)
 }
 
+// disconnectTimeout is the maximum duration CloseWithDisconnect waits for the data sent to be delivered.
+const disconnectTimeout = time.Second * 5
+
+// CloseWithDisconnect disconnects the other end of the Conn with the message passed and closes the Conn. If
+// the message is empty, a client is sent to the server list immediately instead of being shown a disconnect
+// screen. Close, which is called after writing the Disconnect packet, sends out the packets pending, but the
+// underlying connection may still be delivering them once it returns. If the underlying connection reports
+// when it is fully closed, like a RakNet connection, CloseWithDisconnect additionally waits for it to be
+// closed, at most 5 seconds, so that the message is delivered before, for example, the program exits.
+func (conn *Conn) CloseWithDisconnect(message string) error {
+	_ = conn.WritePacket(&packet.Disconnect{
+		HideDisconnectionScreen: message == "",
+		Message:                 message,
+	})
+	err := conn.close(conn.closeErr(message))
+	if c, ok := conn.conn.(interface{ Context() context.Context }); ok {
+		timer := time.NewTimer(disconnectTimeout)
+		defer timer.Stop()
+		select {
+		case <-c.Context().Done():
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
 // LocalAddr returns the local address of the underlying connection.
 func (conn *Conn) LocalAddr() net.Addr {
 	return conn.conn.LocalAddr()
@@ -497,45 +1331,115 @@ func (conn *Conn) RemoteAddr() net.Addr {
 	return conn.conn.RemoteAddr()
 }
 
-// Protocol returns the protocol used by the connection.
+// Protocol returns the protocol used by the connection. For a Conn obtained from a Listener, this is the
+// protocol out of ListenConfig.AcceptedProtocols that was negotiated with the client during login, which may
+// be older than the current protocol. For a Conn obtained using a Dialer, it is the Dialer's Protocol. The
+// protocol does not change once the Conn is logged in.
 func (conn *Conn) Protocol() Protocol {
 	return conn.proto
 }
 
+// ClientVersion returns the game version of the client of the connection, such as "1.21.50", as reported by
+// the client in its login request. For a Conn obtained using a Dialer, this is the game version sent to the
+// server. If the client did not report its game version, the version of the negotiated protocol is returned.
+// Like Protocol, the version does not change once the Conn is logged in.
+func (conn *Conn) ClientVersion() string {
+	if conn.clientData.GameVersion != "" {
+		return conn.clientData.GameVersion
+	}
+	return conn.proto.Ver()
+}
+
 // SetDeadline sets the read and write deadline of the connection. It is equivalent to calling SetReadDeadline
 // and SetWriteDeadline at the same time.
 func (conn *Conn) SetDeadline(t time.Time) error {
-	return conn.SetReadDeadline(t)
+	_ = conn.SetReadDeadline(t)
+	return conn.SetWriteDeadline(t)
 }
 
-// SetReadDeadline sets the read deadline of the Conn to the time passed. The time must be after time.Now().
-// Passing an empty time.Time to the method (time.Time{}) results in the read deadline being cleared.
+// SetReadDeadline sets the read deadline of the Conn to the time passed. Calls to ReadPacket, ReadBytes, Read and
+// ReadBatch, including those already in progress, return a timeout error once the deadline is reached. The error
+// returned is a net.Error of which the Timeout method returns true. Packets that were already received are not
+// lost when the deadline is reached: They are returned by the next read after the deadline is extended.
+// Passing a time in the past results in reads timing out immediately, and passing an empty time.Time to the
+// method (time.Time{}) results in the read deadline being cleared.
 func (conn *Conn) SetReadDeadline(t time.Time) error {
-	empty := time.Time{}
-	if t == empty {
-		conn.readDeadline = make(chan time.Time)
-	} else if t.Before(time.Now()) {
-		panic(fmt.Errorf("error setting read deadline: time passed is before time.Now()"))
-	} else {
-		conn.readDeadline = time.After(time.Until(t))
-	}
+	conn.readDeadline.set(t)
 	return nil
 }
 
-// SetWriteDeadline is a stub function to implement net.Conn. It has no functionality.
-func (conn *Conn) SetWriteDeadline(time.Time) error {
+// SetWriteDeadline sets the write deadline of the Conn to the time passed. Packets written are buffered
+// until they are flushed, so the deadline applies to flushing them to the underlying net.Conn, either using
+// Flush or by the automatic flushes of the Conn. A flush started after the deadline fails, as does a flush in
+// progress when the deadline passes if the underlying net.Conn supports write deadlines. The other end can
+// then no longer decode the data sent after it, so the Conn is closed and the flush returns a *FlushError
+// wrapped in a net.Error of which the Timeout method returns true. Passing an empty time.Time clears the
+// write deadline.
+func (conn *Conn) SetWriteDeadline(t time.Time) error {
+	conn.writeDeadline.Store(t)
+	// Not every net.Conn supports write deadlines. Flushes started after the deadline fail regardless, so the
+	// error is ignored.
+	_ = conn.conn.SetWriteDeadline(t)
 	return nil
 }
 
+// writeDeadlineTime returns the write deadline set using SetWriteDeadline, or an empty time.Time if none was
+// set.
+func (conn *Conn) writeDeadlineTime() time.Time {
+	t, _ := conn.writeDeadline.Load().(time.Time)
+	return t
+}
+
+// MTU returns the MTU negotiated with the server when the Conn was dialed over the "raknet" network: The size
+// in bytes of the largest datagram sent, including its IP and UDP headers. Packets larger than the MTU are
+// split over multiple datagrams. MTU returns 0 if the MTU is not known, which is the case for connections
+// accepted by a Listener and those of other networks. See Dialer.MaxMTU to limit the MTU negotiated.
+func (conn *Conn) MTU() int {
+	return conn.mtu
+}
+
 // Latency returns a rolling average of latency between the sending and the receiving end of the connection.
 // The latency returned is updated continuously and is half the round trip time (RTT).
+// If the underlying connection does not measure its latency, Latency returns half the RTT last measured
+// using NetworkStackLatency packets written with NeedsResponse set to true, and their responses. In this case,
+// Latency returns 0 until the first response is received.
 func (conn *Conn) Latency() time.Duration {
 	if c, ok := conn.conn.(interface {
 		Latency() time.Duration
 	}); ok {
 		return c.Latency()
 	}
-	panic(fmt.Sprintf("connection type %T has no Latency() time.Duration method", conn.conn))
+	return time.Duration(conn.latency.Load())
+}
+
+// maxLatencyProbes is the maximum amount of NetworkStackLatency packets awaiting a response that are tracked
+// to measure latency. Probes that are never responded to are discarded once this amount is exceeded.
+const maxLatencyProbes = 16
+
+// observeLatency tracks NetworkStackLatency packets sent and received to measure the latency of the
+// connection. sent specifies if the packet passed was written to the connection or read from it.
+func (conn *Conn) observeLatency(pk packet.Packet, sent bool) {
+	latency, ok := pk.(*packet.NetworkStackLatency)
+	if !ok {
+		return
+	}
+	conn.latencyMu.Lock()
+	defer conn.latencyMu.Unlock()
+
+	if sent {
+		if !latency.NeedsResponse {
+			return
+		}
+		if conn.latencyProbes == nil || len(conn.latencyProbes) >= maxLatencyProbes {
+			conn.latencyProbes = make(map[int64]time.Time, maxLatencyProbes)
+		}
+		conn.latencyProbes[latency.Timestamp] = time.Now()
+		return
+	}
+	if t, ok := conn.latencyProbes[latency.Timestamp]; ok && !latency.NeedsResponse {
+		delete(conn.latencyProbes, latency.Timestamp)
+		conn.latency.Store(int64(time.Since(t) / 2))
+	}
 }
 
 // ClientCacheEnabled checks if the connection has the client blob cache enabled. If true, the server may send
@@ -549,9 +1453,18 @@ func (conn *Conn) ClientCacheEnabled() bool {
 // Listener, this is the radius that the client requested. For connections obtained through a Dialer, this
 // is the radius that the server approved upon.
 func (conn *Conn) ChunkRadius() int {
+	conn.gameDataMu.Lock()
+	defer conn.gameDataMu.Unlock()
 	return int(conn.gameData.ChunkRadius)
 }
 
+// ClientThrottle returns the client throttling settings of the connection. For connections obtained through
+// a Listener, these are the settings sent to the client. For connections obtained through a Dialer, these are
+// the settings received from the server.
+func (conn *Conn) ClientThrottle() ClientThrottle {
+	return conn.clientThrottle
+}
+
 // Context returns the connection's context. The context is canceled when the connection is closed,
 // allowing for cancellation of operations that are tied to the lifecycle of the connection.
 func (conn *Conn) Context() context.Context {
@@ -583,6 +1496,48 @@ func (conn *Conn) deferPacket(pk *packetData) {
 	conn.deferredPacketMu.Unlock()
 }
 
+// readBatch reads the next batch from the underlying connection and returns the packets it holds. If batch
+// forwarding is enabled, the batch is passed to ReadBatch instead and no packets are returned.
+func (conn *Conn) readBatch() ([][]byte, error) {
+	batch, err := conn.dec.ReadBatch()
+	if err != nil {
+		return nil, err
+	}
+	// A batch may already be in the process of being read when reads are paused, so it is held here rather
+	// than before reading it, until reads are resumed.
+	if err := conn.waitForResume(); err != nil {
+		return nil, err
+	}
+	if len(batch) != 0 {
+		// The batch header and the checksum of encrypted batches were stripped from the batch, but are
+		// counted as part of the batch read.
+		n := len(batch) + 1
+		if conn.Encrypted() {
+			n += 8
+		}
+		conn.stats.bytesRead.Add(uint64(n))
+	}
+	if !conn.batchForwarding.Load() {
+		packets, err := conn.dec.DecodeBatch(batch)
+		if err != nil {
+			return nil, err
+		}
+		n := countPackets(packets, &conn.stats.packetsRead, &conn.stats.bytesDecompressed)
+		if conn.decompressLimiter != nil && !conn.decompressLimiter.allow(n) {
+			_ = conn.close(ErrDecompressRateExceeded)
+			return nil, conn.wrap(ErrDecompressRateExceeded, "read batch")
+		}
+		return packets, nil
+	}
+	if len(batch) != 0 {
+		select {
+		case <-conn.ctx.Done():
+		case conn.batches <- slices.Clone(batch):
+		}
+	}
+	return nil, nil
+}
+
 // receive receives an incoming serialised packet from the underlying connection. If the connection is not yet
 // logged in, the packet is immediately handled.
 func (conn *Conn) receive(data []byte) error {
@@ -629,12 +1584,24 @@ func (conn *Conn) handle(pkData *packetData) error {
 			return conn.handleMultiple(pks)
 		}
 	}
+	if conn.strictLogin && !conn.loggedIn && !slices.Contains(loginPermittedIDs, pkData.h.PacketID) {
+		// A client accepted by a Listener may only send the packets of the current phase of the login
+		// sequence until it is logged in. Any other packet would otherwise be handed to the user before the
+		// client completed the login sequence.
+		_ = conn.WritePacket(&packet.Disconnect{Message: "Unexpected packet during login."})
+		_ = conn.Flush()
+		return &UnexpectedPacketError{PacketID: pkData.h.PacketID, Expected: conn.expectedNames()}
+	}
 	// This is not the packet we expected next in the login sequence. We push it back so that it may
 	// be handled by the user.
 	conn.deferPacket(pkData)
 	return nil
 }
 
+// loginPermittedIDs holds the IDs of packets that a client may send at any point of the login sequence, in
+// addition to the packets expected in the current phase.
+var loginPermittedIDs = []uint32{packet.IDClientCacheStatus, packet.IDPacketViolationWarning}
+
 // handleMultiple handles multiple packets and returns an error if at least one of those packets could not be handled
 // successfully.
 func (conn *Conn) handleMultiple(pks []packet.Packet) error {
@@ -700,38 +1667,30 @@ func (conn *Conn) handlePacket(pk packet.Packet) error {
 // handleRequestNetworkSettings handles an incoming RequestNetworkSettings packet. It returns an error if the protocol
 // version is not supported, otherwise sending back a NetworkSettings packet.
 func (conn *Conn) handleRequestNetworkSettings(pk *packet.RequestNetworkSettings) error {
-	found := false
-	for _, pro := range conn.acceptedProto {
-		if pro.ID() == pk.ClientProtocol {
-			conn.proto = pro
-			conn.pool = pro.Packets(true)
-			found = true
-			break
-		}
-	}
-	if !found {
-		status := packet.PlayStatusLoginFailedClient
-		if pk.ClientProtocol > protocol.CurrentProtocol {
-			// The server is outdated in this case, so we have to change the status we send.
-			status = packet.PlayStatusLoginFailedServer
-		}
-		_ = conn.WritePacket(&packet.PlayStatus{Status: status})
-		return fmt.Errorf("incompatible protocol version: expected %v, got %v", protocol.CurrentProtocol, pk.ClientProtocol)
+	if err := conn.selectProtocol(pk.ClientProtocol); err != nil {
+		return err
 	}
 
 	conn.expect(packet.IDLogin)
 	if err := conn.WritePacket(&packet.NetworkSettings{
-		CompressionThreshold: 512,
-		CompressionAlgorithm: conn.compression.EncodeCompression(),
+		CompressionThreshold:    uint16(conn.compressionThreshold),
+		CompressionAlgorithm:    conn.compression.EncodeCompression(),
+		ClientThrottle:          conn.clientThrottle.Enabled,
+		ClientThrottleThreshold: conn.clientThrottle.Threshold,
+		ClientThrottleScalar:    conn.clientThrottle.Scalar,
 	}); err != nil {
 		return fmt.Errorf("send NetworkSettings: %w", err)
 	}
 	_ = conn.Flush()
 
+	active := conn.compression
+	conn.activeCompression.Store(&active)
 	compression := conn.compression
 	if pk.ClientProtocol >= 649 { // 1.20.60
 		// TODO: I hate this hack as much as the next person, but I don't see another other way out.
 		compression = packet.NewOnTheFlyCompression(compression)
+		conn.enc.SetCompressionThreshold(conn.compressionThreshold)
+		conn.onTheFlyCompression = true
 	}
 	conn.enc.EnableCompression(compression)
 	conn.dec.EnableCompression(compression, conn.maxDecompressedLen)
@@ -740,41 +1699,77 @@ func (conn *Conn) handleRequestNetworkSettings(pk *packet.RequestNetworkSettings
 
 // handleNetworkSettings handles an incoming NetworkSettings packet, enabling compression for future packets.
 func (conn *Conn) handleNetworkSettings(pk *packet.NetworkSettings) error {
-	alg, ok := packet.CompressionByID(pk.CompressionAlgorithm)
+	alg, ok := packet.LookupCompression(pk.CompressionAlgorithm)
 	if !ok {
 		return fmt.Errorf("unknown compression algorithm %v", pk.CompressionAlgorithm)
 	}
+	conn.compression = alg
+	conn.activeCompression.Store(&alg)
+	conn.clientThrottle = ClientThrottle{
+		Enabled:   pk.ClientThrottle,
+		Threshold: pk.ClientThrottleThreshold,
+		Scalar:    pk.ClientThrottleScalar,
+	}
 	compression := alg
 	if conn.proto.ID() >= 649 { // 1.20.60
 		// TODO: I hate this hack as much as the next person, but I don't see another other way out.
-		compression = packet.NewOnTheFlyCompression(compression)
+		compression = packet.NewOnTheFlyCompression(alg)
+		// Batches below the threshold may be sent uncompressed, as each batch
+		// records the compression used.
+		conn.enc.SetCompressionThreshold(int(pk.CompressionThreshold))
+		conn.onTheFlyCompression = true
 	}
 	conn.enc.EnableCompression(compression)
-	conn.dec.EnableCompression(compression, conn.maxDecompressedLen)
+	if conn.onTheFlyCompression && conn.compressionFallback {
+		conn.dec.EnableCompression(packet.NewOnTheFlyCompressionWithFallback(alg), conn.maxDecompressedLen)
+	} else {
+		conn.dec.EnableCompression(compression, conn.maxDecompressedLen)
+	}
 	conn.readyToLogin = true
 	return nil
 }
 
-// handleLogin handles an incoming login packet. It verifies and decodes the login request found in the packet
-// and returns an error if it couldn't be done successfully.
-func (conn *Conn) handleLogin(pk *packet.Login) error {
-	found := false
+// selectProtocol selects the accepted Protocol with the ID passed for the connection. If the protocol is not
+// accepted, the client is disconnected with a message telling it to update either the game or the server,
+// and an error is returned.
+func (conn *Conn) selectProtocol(id int32) error {
+	lowest, highest := conn.acceptedProto[0].ID(), conn.acceptedProto[0].ID()
 	for _, pro := range conn.acceptedProto {
-		if pro.ID() == pk.ClientProtocol {
-			conn.proto = pro
-			conn.pool = pro.Packets(true)
-			found = true
-			break
-		}
+		lowest, highest = min(lowest, pro.ID()), max(highest, pro.ID())
+	}
+	if conn.minProtocol != 0 {
+		lowest = max(lowest, conn.minProtocol)
 	}
-	if !found {
-		status := packet.PlayStatusLoginFailedClient
-		if pk.ClientProtocol > protocol.CurrentProtocol {
-			// The server is outdated in this case, so we have to change the status we send.
-			status = packet.PlayStatusLoginFailedServer
+	if conn.maxProtocol != 0 {
+		highest = min(highest, conn.maxProtocol)
+	}
+	if id >= lowest && id <= highest {
+		for _, pro := range conn.acceptedProto {
+			if pro.ID() == id {
+				conn.proto = pro
+				conn.setPool(pro.Packets(true))
+				return nil
+			}
 		}
+	}
+	status, message, required := packet.PlayStatusLoginFailedClient, conn.outdatedClientMessage, lowest
+	if id > highest {
+		// The server is outdated in this case, so we have to change the status we send.
+		status, message, required = packet.PlayStatusLoginFailedServer, conn.outdatedServerMessage, highest
+	}
+	if message != "" {
+		_ = conn.WritePacket(&packet.Disconnect{Message: strings.ReplaceAll(message, "{protocol}", strconv.Itoa(int(required)))})
+	} else {
 		_ = conn.WritePacket(&packet.PlayStatus{Status: status})
-		return fmt.Errorf("%v connected with an incompatible protocol: expected protocol = %v, client protocol = %v", conn.identityData.DisplayName, protocol.CurrentProtocol, pk.ClientProtocol)
+	}
+	return fmt.Errorf("incompatible protocol version: expected %v to %v, got %v", lowest, highest, id)
+}
+
+// handleLogin handles an incoming login packet. It verifies and decodes the login request found in the packet
+// and returns an error if it couldn't be done successfully.
+func (conn *Conn) handleLogin(pk *packet.Login) error {
+	if err := conn.selectProtocol(pk.ClientProtocol); err != nil {
+		return err
 	}
 
 	// The next expected packet is a response from the client to the handshake.
@@ -787,11 +1782,23 @@ func (conn *Conn) handleLogin(pk *packet.Login) error {
 	if err != nil {
 		return fmt.Errorf("parse login request: %w", err)
 	}
+	// The request was parsed successfully, so it cannot fail to be split.
+	conn.rawChainData, conn.rawClientData, _ = login.SplitRequest(pk.ConnectionRequest)
 
 	// Make sure the player is logged in with XBOX Live when necessary.
 	if !authResult.XBOXLiveAuthenticated && conn.authEnabled {
 		_ = conn.WritePacket(&packet.Disconnect{Message: text.Colourf("<red>You must be logged in with XBOX Live to join.</red>")})
-		return fmt.Errorf("client was not authenticated to XBOX Live")
+		return fmt.Errorf("%w: client was not authenticated to XBOX Live", login.ErrChainUntrusted)
+	}
+	if !authResult.XBOXLiveAuthenticated && conn.offlineIdentity {
+		conn.identityData.Identity = login.OfflineUUID(conn.identityData.DisplayName).String()
+	}
+	conn.log = conn.log.With("xuid", conn.identityData.XUID, "name", conn.identityData.DisplayName)
+	if conn.onLogin != nil {
+		if err := conn.onLogin(conn.identityData, conn.clientData); err != nil {
+			_ = conn.WritePacket(&packet.Disconnect{Message: err.Error()})
+			return fmt.Errorf("login rejected: %w", err)
+		}
 	}
 	if err := conn.enableEncryption(authResult.PublicKey); err != nil {
 		return fmt.Errorf("enable encryption: %w", err)
@@ -870,8 +1877,7 @@ func (conn *Conn) handleServerToClientHandshake(pk *packet.ServerToClientHandsha
 	keyBytes := sha256.Sum256(append(salt, sharedSecret...))
 
 	// Finally we enable encryption for the enc and dec using the secret pubKey bytes we produced.
-	conn.enc.EnableEncryption(conn.proto.Encryption(keyBytes))
-	conn.dec.EnableEncryption(conn.proto.Encryption(keyBytes))
+	conn.setEncryptionKey(keyBytes, pub.Curve.Params().Name, salt)
 
 	// We write a ClientToServerHandshake packet (which has no payload) as a response.
 	_ = conn.WritePacket(&packet.ClientToServerHandshake{})
@@ -888,6 +1894,7 @@ func (conn *Conn) handleClientCacheStatus(pk *packet.ClientCacheStatus) error {
 // handleResourcePacksInfo handles a ResourcePacksInfo packet sent by the server. The client responds by
 // sending the packs it needs downloaded.
 func (conn *Conn) handleResourcePacksInfo(pk *packet.ResourcePacksInfo) error {
+	conn.phase.Store(phaseResourcePack)
 	// First create a new resource pack queue with the information in the packet so we can download them
 	// properly later.
 	totalPacks := len(pk.TexturePacks)
@@ -905,6 +1912,20 @@ func (conn *Conn) handleResourcePacksInfo(pk *packet.ResourcePacksInfo) error {
 			conn.packQueue.packAmount--
 			continue
 		}
+		if conn.acceptResourcePack != nil && !conn.acceptResourcePack(pack) {
+			if pk.TexturePackRequired {
+				// The server does not allow joining without its packs, so we refuse all of them, after which
+				// the server disconnects us.
+				_ = conn.WritePacket(&packet.ResourcePackClientResponse{Response: packet.PackResponseRefused})
+				return fmt.Errorf("resource pack (UUID=%v, version=%v) was refused, but the server requires its packs to be accepted", pack.UUID, pack.Version)
+			}
+			conn.ignoredResourcePacks = append(conn.ignoredResourcePacks, exemptedResourcePack{
+				uuid:    id,
+				version: pack.Version,
+			})
+			conn.packQueue.packAmount--
+			continue
+		}
 		if conn.downloadResourcePack != nil && !conn.downloadResourcePack(uuid.MustParse(id), pack.Version, index, totalPacks) {
 			conn.ignoredResourcePacks = append(conn.ignoredResourcePacks, exemptedResourcePack{
 				uuid:    id,
@@ -913,14 +1934,25 @@ func (conn *Conn) handleResourcePacksInfo(pk *packet.ResourcePacksInfo) error {
 			conn.packQueue.packAmount--
 			continue
 		}
-		// This UUID_Version is a hack Mojang put in place.
-		packsToDownload = append(packsToDownload, id+"_"+pack.Version)
-		conn.packQueue.downloadingPacks[id] = downloadingPack{
+		download := downloadingPack{
 			size:       pack.Size,
-			buf:        bytes.NewBuffer(make([]byte, 0, pack.Size)),
+			version:    pack.Version,
 			newFrag:    make(chan []byte),
 			contentKey: pack.ContentKey,
 		}
+		if conn.resourcePackWriter != nil {
+			w, err := conn.resourcePackWriter(pack.UUID, pack.Version, pack.Size)
+			if err != nil {
+				conn.closeResourcePackWriters()
+				return fmt.Errorf("resource pack writer (UUID=%v, version=%v): %w", pack.UUID, pack.Version, err)
+			}
+			download.w = w
+		} else {
+			download.buf = bytes.NewBuffer(make([]byte, 0, pack.Size))
+		}
+		// This UUID_Version is a hack Mojang put in place.
+		packsToDownload = append(packsToDownload, id+"_"+pack.Version)
+		conn.packQueue.downloadingPacks[id] = download
 	}
 
 	if len(packsToDownload) != 0 {
@@ -937,6 +1969,15 @@ func (conn *Conn) handleResourcePacksInfo(pk *packet.ResourcePacksInfo) error {
 	return nil
 }
 
+const (
+	// phaseLogin is the phase of the login sequence up to receiving the ResourcePacksInfo packet.
+	phaseLogin = "login"
+	// phaseResourcePack is the phase in which resource packs are downloaded.
+	phaseResourcePack = "resource pack"
+	// phaseSpawn is the phase from the ResourcePackStack packet until the player is spawned.
+	phaseSpawn = "spawn"
+)
+
 // handleResourcePackStack handles a ResourcePackStack packet sent by the server. The stack defines the order
 // that resource packs are applied in.
 func (conn *Conn) handleResourcePackStack(pk *packet.ResourcePackStack) error {
@@ -961,6 +2002,7 @@ func (conn *Conn) handleResourcePackStack(pk *packet.ResourcePackStack) error {
 		}
 	}
 	conn.expect(packet.IDStartGame)
+	conn.phase.Store(phaseSpawn)
 	_ = conn.WritePacket(&packet.ResourcePackClientResponse{Response: packet.PackResponseCompleted})
 	return nil
 }
@@ -1044,7 +2086,7 @@ func (conn *Conn) handleResourcePackClientResponse(pk *packet.ResourcePackClient
 
 // startGame sends a StartGame packet using the game data of the connection.
 func (conn *Conn) startGame() {
-	data := conn.gameData
+	data := conn.GameData()
 	_ = conn.WritePacket(&packet.StartGame{
 		Difficulty:                   data.Difficulty,
 		EntityUniqueID:               data.EntityUniqueID,
@@ -1068,7 +2110,10 @@ func (conn *Conn) startGame() {
 		Items:                        data.Items, // For clients below 1.21.60.
 		AchievementsDisabled:         true,
 		Generator:                    1,
-		EducationFeaturesEnabled:     true,
+		EducationEditionOffer:        data.EducationEditionOffer,
+		EducationFeaturesEnabled:     !data.EducationFeaturesDisabled,
+		EducationProductID:           data.EducationProductID,
+		EducationSharedResourceURI:   data.EducationSharedResourceURI,
 		MultiPlayerGame:              true,
 		MultiPlayerCorrelationID:     uuid.Must(uuid.NewRandom()).String(),
 		CommandsEnabled:              true,
@@ -1125,7 +2170,8 @@ func (conn *Conn) handleResourcePackDataInfo(pk *packet.ResourcePackDataInfo) er
 		pack.size = pk.Size
 	}
 
-	// Remove the resource pack from the downloading packs and add it to the awaiting packets.
+	// Remove the resource pack from the downloading packs and add it to the awaiting packets. From here on,
+	// the goroutine downloading the pack is responsible for closing its writer.
 	delete(conn.packQueue.downloadingPacks, id)
 	conn.packQueue.awaitingPacks[id] = &pack
 
@@ -1147,15 +2193,40 @@ func (conn *Conn) handleResourcePackDataInfo(pk *packet.ResourcePackDataInfo) er
 			})
 			select {
 			case <-conn.ctx.Done():
+				if pack.w != nil {
+					_ = pack.w.Close()
+				}
 				return
 			case frag := <-pack.newFrag:
-				// Write the fragment to the full buffer of the downloading resource pack.
-				_, _ = pack.buf.Write(frag)
+				if pack.w == nil {
+					// Write the fragment to the full buffer of the downloading resource pack.
+					_, _ = pack.buf.Write(frag)
+					continue
+				}
+				if _, err := pack.w.Write(frag); err != nil {
+					// The writer aborted the download, so we can't proceed with the login sequence.
+					_ = pack.w.Close()
+					_ = conn.close(fmt.Errorf("download resource pack (UUID=%v): write: %w", id, err))
+					return
+				}
+			}
+		}
+		if pack.w != nil {
+			if err := pack.w.Close(); err != nil {
+				_ = conn.close(fmt.Errorf("download resource pack (UUID=%v): close: %w", id, err))
+				return
 			}
 		}
 		conn.packMu.Lock()
 		defer conn.packMu.Unlock()
 
+		if pack.w != nil {
+			// The pack was written to the resource pack writer, so we don't hold it ourselves. We ignore
+			// the pack so that it is still considered downloaded in the ResourcePackStack.
+			conn.ignoredResourcePacks = append(conn.ignoredResourcePacks, exemptedResourcePack{uuid: id, version: pack.version})
+			conn.finishResourcePackDownload()
+			return
+		}
 		if pack.buf.Len() != int(pack.size) {
 			conn.log.Error(fmt.Sprintf("download resource pack: incorrect resource pack size: expected %v, got %v", pack.size, pack.buf.Len()), "UUID", id)
 			return
@@ -1166,17 +2237,39 @@ func (conn *Conn) handleResourcePackDataInfo(pk *packet.ResourcePackDataInfo) er
 			conn.log.Error("download resource pack: invalid full resource pack data: "+err.Error(), "UUID", id)
 			return
 		}
-		conn.packQueue.packAmount--
 		// Finally we add the resource to the resource packs slice.
 		conn.resourcePacks = append(conn.resourcePacks, newPack.WithContentKey(pack.contentKey))
-		if conn.packQueue.packAmount == 0 {
-			conn.expect(packet.IDResourcePackStack)
-			_ = conn.WritePacket(&packet.ResourcePackClientResponse{Response: packet.PackResponseAllPacksDownloaded})
-		}
+		conn.finishResourcePackDownload()
 	}()
 	return nil
 }
 
+// closeResourcePackWriters closes the writers obtained from the resource pack writer for all resource packs
+// of which the download has not yet started. Writers of packs that are being downloaded are closed by the
+// goroutine downloading them. closeResourcePackWriters must only be called from the goroutine reading
+// packets, which owns the resource pack queue.
+func (conn *Conn) closeResourcePackWriters() {
+	if conn.packQueue == nil {
+		return
+	}
+	for id, pack := range conn.packQueue.downloadingPacks {
+		if pack.w != nil {
+			_ = pack.w.Close()
+		}
+		delete(conn.packQueue.downloadingPacks, id)
+	}
+}
+
+// finishResourcePackDownload marks the download of a resource pack as finished. If all resource packs were
+// downloaded, the server is notified. packMu must be held while calling finishResourcePackDownload.
+func (conn *Conn) finishResourcePackDownload() {
+	conn.packQueue.packAmount--
+	if conn.packQueue.packAmount == 0 {
+		conn.expect(packet.IDResourcePackStack)
+		_ = conn.WritePacket(&packet.ResourcePackClientResponse{Response: packet.PackResponseAllPacksDownloaded})
+	}
+}
+
 // handleResourcePackChunkData handles a resource pack chunk data packet, which holds a fragment of a resource
 // pack that is being downloaded.
 func (conn *Conn) handleResourcePackChunkData(pk *packet.ResourcePackChunkData) error {
@@ -1187,7 +2280,10 @@ func (conn *Conn) handleResourcePackChunkData(pk *packet.ResourcePackChunkData)
 		// download a resource pack.
 		return fmt.Errorf("chunk data for resource pack that was not being downloaded")
 	}
-	lastData := pack.buf.Len()+int(pack.chunkSize) >= int(pack.size)
+	// All chunks before this one had the full chunk size, so the amount of data received so far follows from
+	// the index of the chunk. pack.buf cannot be used for this, as it is nil if a resource pack writer is used.
+	received := uint64(pack.expectedIndex) * uint64(pack.chunkSize)
+	lastData := received+uint64(pack.chunkSize) >= pack.size
 	if !lastData && uint32(len(pk.Data)) != pack.chunkSize {
 		// The chunk data didn't have the full size and wasn't the last data to be sent for the resource pack,
 		// meaning we got too little data.
@@ -1245,6 +2341,9 @@ func (conn *Conn) handleResourcePackChunkRequest(pk *packet.ResourcePackChunkReq
 // handleStartGame handles an incoming StartGame packet. It is the signal that the player has been added to a
 // world, and it obtains most of its dedicated properties.
 func (conn *Conn) handleStartGame(pk *packet.StartGame) error {
+	conn.gameDataMu.Lock()
+	defer conn.gameDataMu.Unlock()
+
 	conn.gameData = GameData{
 		Difficulty:                   pk.Difficulty,
 		WorldName:                    pk.WorldName,
@@ -1278,6 +2377,10 @@ func (conn *Conn) handleStartGame(pk *packet.StartGame) error {
 		ClientSideGeneration:         pk.ClientSideGeneration,
 		Experiments:                  pk.Experiments,
 		UseBlockNetworkIDHashes:      pk.UseBlockNetworkIDHashes,
+		EducationEditionOffer:        pk.EducationEditionOffer,
+		EducationFeaturesDisabled:    !pk.EducationFeaturesEnabled,
+		EducationProductID:           pk.EducationProductID,
+		EducationSharedResourceURI:   pk.EducationSharedResourceURI,
 	}
 	conn.expect(packet.IDItemRegistry)
 	return nil
@@ -1286,7 +2389,9 @@ func (conn *Conn) handleStartGame(pk *packet.StartGame) error {
 // handleItemRegistry handles an incoming ItemRegistry packet. It contains the item definitions that the client
 // should use, including the shield ID which is necessary for reading and writing items in the future.
 func (conn *Conn) handleItemRegistry(pk *packet.ItemRegistry) error {
+	conn.gameDataMu.Lock()
 	conn.gameData.Items = pk.Items
+	conn.gameDataMu.Unlock()
 	for _, item := range pk.Items {
 		if item.Name == "minecraft:shield" {
 			conn.shieldID.Store(int32(item.RuntimeID))
@@ -1305,12 +2410,15 @@ func (conn *Conn) handleRequestChunkRadius(pk *packet.RequestChunkRadius) error
 		return fmt.Errorf("expected chunk radius of at least 1, got %v", pk.ChunkRadius)
 	}
 	conn.expect(packet.IDSetLocalPlayerAsInitialised)
+	conn.gameDataMu.Lock()
 	radius := pk.ChunkRadius
 	if r := conn.gameData.ChunkRadius; r != 0 {
 		radius = r
 	}
-	_ = conn.WritePacket(&packet.ChunkRadiusUpdated{ChunkRadius: radius})
 	conn.gameData.ChunkRadius = pk.ChunkRadius
+	conn.gameDataMu.Unlock()
+
+	_ = conn.WritePacket(&packet.ChunkRadiusUpdated{ChunkRadius: radius})
 	// Clients pre-1.21.80 crash when not sending all biomes, due to achievements assuming all biomes are present.
 	// To maintain backwards compatibility, we send empty biomes so the protocol can handle legacy biome data
 	// for older clients (see: https://github.com/Sandertv/gophertunnel/blob/a61732e9cb7bc04e5e7dd961ad4fea597f1229dc/minecraft/conn.go#L1274-L1278).
@@ -1328,7 +2436,9 @@ func (conn *Conn) handleChunkRadiusUpdated(pk *packet.ChunkRadiusUpdated) error
 	}
 	conn.expect(packet.IDPlayStatus)
 
+	conn.gameDataMu.Lock()
 	conn.gameData.ChunkRadius = pk.ChunkRadius
+	conn.gameDataMu.Unlock()
 	conn.gameDataReceived.Store(true)
 
 	conn.tryFinaliseClientConn()
@@ -1339,11 +2449,14 @@ func (conn *Conn) handleChunkRadiusUpdated(pk *packet.ChunkRadiusUpdated) error
 // packet in the spawning sequence and it marks the point where a server sided connection is considered
 // logged in.
 func (conn *Conn) handleSetLocalPlayerAsInitialised(pk *packet.SetLocalPlayerAsInitialised) error {
-	if pk.EntityRuntimeID != conn.gameData.EntityRuntimeID {
-		return fmt.Errorf("entity runtime ID mismatch: expected %v (from StartGame), got %v", conn.gameData.EntityRuntimeID, pk.EntityRuntimeID)
+	if rid := conn.GameData().EntityRuntimeID; pk.EntityRuntimeID != rid {
+		return fmt.Errorf("entity runtime ID mismatch: expected %v (from StartGame), got %v", rid, pk.EntityRuntimeID)
 	}
 	if conn.waitingForSpawn.CompareAndSwap(true, false) {
+		conn.spawned.Store(true)
+		conn.gameDataMu.Lock()
 		close(conn.spawn)
+		conn.gameDataMu.Unlock()
 	}
 	return nil
 }
@@ -1401,9 +2514,15 @@ func (conn *Conn) tryFinaliseClientConn() {
 		conn.waitingForSpawn.Store(false)
 		conn.gameDataReceived.Store(false)
 
-		close(conn.spawn)
+		rid := conn.GameData().EntityRuntimeID
 		conn.loggedIn = true
-		_ = conn.WritePacket(&packet.SetLocalPlayerAsInitialised{EntityRuntimeID: conn.gameData.EntityRuntimeID})
+		conn.spawned.Store(true)
+		conn.gameDataMu.Lock()
+		close(conn.spawn)
+		conn.gameDataMu.Unlock()
+		// SetLocalPlayerAsInitialised is the last packet of the spawn sequence, so it is not passed to the
+		// packet filter.
+		_ = conn.sendPackets([]packet.Packet{&packet.SetLocalPlayerAsInitialised{EntityRuntimeID: rid}}, 0, 0)
 	}
 }
 
@@ -1433,11 +2552,45 @@ func (conn *Conn) enableEncryption(clientPublicKey *ecdsa.PublicKey) error {
 	keyBytes := sha256.Sum256(append(conn.salt, sharedSecret...))
 
 	// Finally we enable encryption for the encoder and decoder using the secret key bytes we produced.
-	conn.enc.EnableEncryption(conn.proto.Encryption(keyBytes))
-	conn.dec.EnableEncryption(conn.proto.Encryption(keyBytes))
+	conn.setEncryptionKey(keyBytes, clientPublicKey.Curve.Params().Name, conn.salt)
 	return nil
 }
 
+// setEncryptionKey enables encryption for the encoder and decoder of the connection using the key passed,
+// which was produced using a key exchange on the curve and with the salt passed.
+func (conn *Conn) setEncryptionKey(key [32]byte, curve string, salt []byte) {
+	enc := conn.proto.Encryption(key)
+	conn.enc.EnableEncryption(enc)
+	conn.dec.EnableEncryption(conn.proto.Encryption(key))
+	info := securityInfo(curve, salt, enc, len(key))
+	conn.securityInfo.Store(&info)
+	conn.encryptionKeyLen.Store(int32(len(key)))
+}
+
+// spawnError returns a *SpawnError for the error passed, holding the names of the packets currently expected
+// to arrive.
+func (conn *Conn) spawnError(err error) *SpawnError {
+	return &SpawnError{Expected: conn.expectedNames(), Err: err}
+}
+
+// expectedNames returns the names of the packets currently expected to arrive.
+func (conn *Conn) expectedNames() []string {
+	// Both pools are used, so that the names of packets sent by either side are found.
+	pool := packet.NewServerPool()
+	maps.Copy(pool, packet.NewClientPool())
+
+	ids := conn.expectedIDs.Load().([]uint32)
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if f, ok := pool[id]; ok {
+			names = append(names, strings.TrimPrefix(fmt.Sprintf("%T", f()), "*packet."))
+			continue
+		}
+		names = append(names, fmt.Sprintf("packet %v", id))
+	}
+	return names
+}
+
 // expect sets the packet IDs that are next expected to arrive.
 func (conn *Conn) expect(packetIDs ...uint32) {
 	conn.expectedIDs.Store(packetIDs)
@@ -1446,7 +2599,12 @@ func (conn *Conn) expect(packetIDs ...uint32) {
 func (conn *Conn) close(cause error) error {
 	var err error
 	conn.once.Do(func() {
-		err = conn.Flush()
+		// Flush is not used here, as it closes the connection itself if flushing fails.
+		conn.sendMu.Lock()
+		if err = conn.flush(); err != nil {
+			err = conn.wrap(err, "flush")
+		}
+		conn.sendMu.Unlock()
 		conn.cancelFunc(cause)
 		_ = conn.conn.Close()
 	})
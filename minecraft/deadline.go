@@ -0,0 +1,60 @@
+package minecraft
+
+import (
+	"sync"
+	"time"
+)
+
+// deadline is a resettable deadline that may be waited on. Unlike a channel obtained using time.After, the
+// channel returned by wait remains closed once the deadline expires, until the deadline is set again. Calls
+// waiting on the channel while the deadline is changed observe the new deadline.
+type deadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+// set sets the deadline to the time passed. If t is the zero time.Time, the deadline is cleared. If t is in
+// the past, the deadline expires immediately.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// The timer already fired, so wait for the channel to be closed before replacing it.
+		<-d.cancel
+	}
+	d.timer = nil
+
+	closed := d.cancel != nil && isClosed(d.cancel)
+	if d.cancel == nil || closed {
+		d.cancel = make(chan struct{})
+	}
+	if t.IsZero() {
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() { close(cancel) })
+		return
+	}
+	close(d.cancel)
+}
+
+// wait returns a channel that is closed when the deadline expires. The channel returned is nil, and thus
+// blocks forever, if the deadline was never set.
+func (d *deadline) wait() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+// isClosed checks if the channel passed is closed without blocking.
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,50 @@
+package minecraft_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestReadPacketDecodeError checks that a packet that fails to decode closes a Conn configured to disconnect
+// on invalid packets, and that the error returned by ReadPacket wraps a *minecraft.DecodeError.
+func TestReadPacketDecodeError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{DisconnectOnInvalidPackets: true}, minecraft.ListenConfig{}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	// A Text packet without a payload: Decoding it fails as soon as the text type is read.
+	if _, err := server.Write([]byte{packet.IDText}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := server.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		_, err := client.ReadPacket()
+		if err == nil {
+			continue
+		}
+		var decodeErr *minecraft.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("expected error wrapping *DecodeError, got %v", err)
+		}
+		if decodeErr.PacketID != packet.IDText {
+			t.Errorf("expected packet ID %v, got %v", packet.IDText, decodeErr.PacketID)
+		}
+		// The Conn is closed, and errors returned after that are caused by the DecodeError too.
+		if _, err := client.ReadPacket(); !errors.As(err, &decodeErr) {
+			t.Errorf("expected subsequent error wrapping *DecodeError, got %v", err)
+		}
+		return
+	}
+}
@@ -12,12 +12,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math"
 	"math/rand"
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
@@ -34,12 +36,21 @@ import (
 // Dialer allows specifying specific settings for connection to a Minecraft server.
 // The zero value of Dialer is used for the package level Dial function.
 type Dialer struct {
-	// ErrorLog is a log.Logger that errors that occur during packet handling of
-	// servers are written to. By default, errors are not logged.
+	// ErrorLog is a slog.Logger that errors that occur during packet handling of
+	// servers are written to. Records logged for a Conn carry the remote address and,
+	// once known, the XUID and display name of the player as attributes, so that
+	// a custom slog.Handler may route them to any sink. By default, errors are not
+	// logged.
 	ErrorLog *slog.Logger
 
 	// ClientData is the client data used to login to the server with. It includes fields such as the skin,
-	// locale and UUIDs unique to the client. If empty, a default is sent produced using defaultClientData().
+	// locale and UUIDs unique to the client. Fields set are sent as they are, including DeviceOS,
+	// DeviceModel, GameVersion and DefaultInputMode, so that the client may present itself as any device.
+	// Only fields left empty are filled with defaults, which are those of an Android device.
+	// Note that the ClientData is signed by the client itself, so a server may only verify it by comparing it
+	// against the identity data in the login chain: If a TokenSource is set, the chain holds the XUID,
+	// display name and the title ID of the Android version of the game, which a server may compare against
+	// DeviceOS and ThirdPartyName.
 	ClientData login.ClientData
 	// IdentityData is the identity data used to login to the server with. It includes the username, UUID and
 	// XUID of the player.
@@ -64,11 +75,53 @@ type Dialer struct {
 	// from which the packet originated, and the destination address.
 	PacketFunc func(header packet.Header, payload []byte, src, dst net.Addr)
 
+	// PacketFilter is called for every packet read using Conn.ReadPacket and every packet written using
+	// Conn.WritePacket after the connection has spawned. incoming is true for packets read and false for
+	// packets written. A non-nil packet returned replaces the packet passed, so that it is returned by
+	// ReadPacket or written to the network instead. If drop is true, the packet is discarded instead.
+	// If a non-nil error is returned, ReadPacket or WritePacket returns it.
+	// Packets of the login and spawn sequence, including those written by the Conn itself, are never passed
+	// to PacketFilter, so that these sequences cannot be obstructed by dropping or modifying packets.
+	PacketFilter func(pk packet.Packet, incoming bool) (modified packet.Packet, drop bool, err error)
+
+	// TransferFunc, if set, is called for every Transfer packet read using Conn.ReadPacket, after it passed
+	// PacketFilter, with the address and port of the server that the client is transferred to. The address
+	// and port returned replace those of the Transfer packet returned by ReadPacket, so that the transfer may
+	// be redirected. If follow is false, the Transfer packet is dropped instead, vetoing the transfer.
+	// Dialer.DialTransfer may be used to follow a Transfer packet returned by ReadPacket.
+	TransferFunc func(address string, port uint16) (newAddress string, newPort uint16, follow bool)
+
+	// Packets is a packet.Pool holding custom packets that are added to the packets decoded by connections
+	// dialed using the Dialer. Packets read with an ID found in Packets are decoded into the packet returned by the
+	// function registered, rather than into a *packet.Unknown. Dial returns an error if Packets holds
+	// an ID of a packet already implemented by the Protocol of the Dialer, unless OverridePackets is true.
+	Packets packet.Pool
+	// OverridePackets specifies if packets in Packets may replace packets with the same ID that are already
+	// implemented.
+	OverridePackets bool
+
 	// DownloadResourcePack is called individually for every texture and behaviour pack sent by the connection when
 	// using Dialer.Dial(), and can be used to stop the pack from being downloaded. The function is called with the UUID
 	// and version of the resource pack, the number of the current pack being downloaded, and the total amount of packs.
 	// The boolean returned determines if the pack will be downloaded or not.
 	DownloadResourcePack func(id uuid.UUID, version string, current, total int) bool
+	// AcceptResourcePack, if set, is called for every texture and behaviour pack offered by the server when
+	// using Dialer.Dial(), before DownloadResourcePack. It may be used to refuse packs based on the information
+	// the server sent about them, such as their size or content key. If AcceptResourcePack returns false, the
+	// pack is not downloaded. If the server requires its packs to be accepted, refusing a pack leads to the
+	// packs being refused as a whole and the dial failing with an error, as the server would otherwise
+	// disconnect the client. If nil, all packs are accepted.
+	AcceptResourcePack func(pack protocol.TexturePackInfo) bool
+
+	// ResourcePackWriter, if set, is called for every texture and behaviour pack that is downloaded when using
+	// Dialer.Dial(), after DownloadResourcePack returned true for it. The function is called with the UUID,
+	// version and size of the resource pack, and the data of the pack is written to the io.WriteCloser
+	// returned as it is downloaded, instead of being kept in memory. The io.WriteCloser is closed once the
+	// download is complete. Packs written to a ResourcePackWriter are not returned by Conn.ResourcePacks.
+	// ResourcePackWriter may be used to store packs on disk, for example to cache them across connections.
+	// If ResourcePackWriter or a call to Write or Close returns an error, the download is aborted and the
+	// connection is closed. This may be used to reject packs that are too large.
+	ResourcePackWriter func(id uuid.UUID, version string, size uint64) (io.WriteCloser, error)
 
 	// DisconnectOnUnknownPackets specifies if the connection should disconnect if packets received are not present
 	// in the packet pool. If true, such packets lead to the connection being closed immediately.
@@ -76,9 +129,33 @@ type Dialer struct {
 	DisconnectOnUnknownPackets bool
 
 	// DisconnectOnInvalidPackets specifies if invalid packets (either too few bytes or too many bytes) should be
-	// allowed. If true, such packets lead to the connection being closed immediately. If false,
-	// packets with too many bytes will be returned while packets with too few bytes will be skipped.
+	// allowed. If true, such packets lead to the connection being closed immediately, after which
+	// Conn.ReadPacket returns an error wrapping a *DecodeError. If false, packets with too many bytes will be
+	// returned while packets with too few bytes will be skipped.
 	DisconnectOnInvalidPackets bool
+	// ZeroCopy specifies if the data returned by Conn.ReadPacketAndBytes is returned without copying it.
+	// By default, this data, and the payload of a *packet.Unknown returned for packets excluded using
+	// Conn.SetDecodeFilter, is copied out of the batch it was read from, so that it may be retained. If
+	// ZeroCopy is true, the data refers to the batch directly and is only valid until the next call to
+	// ReadPacket: It must be copied if it is used after that. Packets decoded are not affected, as their
+	// fields never refer to the data they were decoded from.
+	ZeroCopy bool
+	// CompressionFallback specifies if batches sent by the server that cannot be decoded using the compression
+	// algorithm they are prefixed with are decoded as if they lacked the prefix, first by decompressing them
+	// using the compression negotiated and then without decompression. It may be enabled to connect to
+	// servers that do not prefix every batch with the compression algorithm used. Batches are then no longer
+	// decompressed as they are streamed, and a batch that cannot be decoded may be decompressed up to three
+	// times. CompressionFallback has no effect for protocol versions before 1.20.60.
+	CompressionFallback bool
+	// MaxDecompressedRate is the maximum average amount of bytes per second that the batches sent by the
+	// server may decompress to. It protects against a server sending highly compressible data to occupy the
+	// CPU with decompression. If the rate is exceeded, the connection is closed with
+	// ErrDecompressRateExceeded. If 0, the rate is not limited.
+	MaxDecompressedRate int
+	// DecompressedBurst is the amount of decompressed bytes that the server may send at once above
+	// MaxDecompressedRate, such as the chunks sent while spawning. If 0, a burst of four seconds at
+	// MaxDecompressedRate is allowed.
+	DecompressedBurst int
 
 	// Protocol is the Protocol version used to communicate with the target server. By default, this field is
 	// set to the current protocol as implemented in the minecraft/protocol package. Note that packets written
@@ -92,6 +169,7 @@ type Dialer struct {
 	// The default FlushRate (when set to 0) is time.Second/20. If FlushRate is set negative, packets
 	// will not be flushed automatically. In this case, calling `(*Conn).Flush()` is required after any
 	// calls to `(*Conn).Write()` or `(*Conn).WritePacket()` to send the packets over network.
+	// The rate may be changed for an individual Conn using `(*Conn).SetFlushInterval()`.
 	FlushRate time.Duration
 
 	// EnableClientCache, if set to true, enables the client blob cache for the client. This means that the
@@ -105,10 +183,44 @@ type Dialer struct {
 	// For getting this to work with BDS, authentication should be disabled.
 	KeepXBLIdentityData bool
 
+	// HandshakeTimeout is the maximum duration that the login sequence may take after the connection to the
+	// server has been established, up to the point where the player is spawned. If the login sequence takes
+	// longer, the connection is closed and a HandshakeTimeoutError is returned, holding the phase of the login
+	// sequence the connection was in. Unlike the context passed to DialContext, this timeout does not include
+	// authentication and establishing the network connection. If zero, no handshake timeout is applied.
+	HandshakeTimeout time.Duration
+
 	// EnableLegacyAuth, if set to true, will use the legacy authentication behavior
 	// (pre-1.21.90) when connecting to the server. This should only be used for outdated
 	// servers, as enabling it will cause compatibility issues with updated servers.
 	EnableLegacyAuth bool
+
+	// DialFunc, if non-nil, is used to open the underlying connection instead of dialing the address
+	// directly, for example to route the connection through a proxy. For the "raknet" network, DialFunc is
+	// called with the "udp" network and must return a packet oriented net.Conn, such as one obtained through
+	// a SOCKS5 UDP association, in which each Write sends a single datagram. DialFunc is also used to ping
+	// the server. DialFunc is only supported by the "raknet" network.
+	DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+	// MaxMTU is the maximum MTU, the size in bytes of the largest datagram including its IP and UDP headers,
+	// negotiated with the server. By default, RakNet first attempts an MTU of 1492, which fails on networks
+	// with a smaller MTU, such as those of some VPNs and containers, only falling back to smaller sizes after
+	// several attempts. If non-zero, the MTU negotiated does not exceed MaxMTU, which is clamped between 400
+	// and 1492. The MTU negotiated is returned by Conn.MTU. MaxMTU is only supported by the "raknet" network.
+	MaxMTU int
+
+	// PrivateKey, if non-nil, is the P-384 private key used to sign the login request and to establish
+	// encryption, instead of a key generated for every connection. It exists so that tests may produce
+	// reproducible login requests and should be left nil otherwise: Reusing a private key across connections
+	// weakens their encryption.
+	PrivateKey *ecdsa.PrivateKey
+
+	// Simulate, if non-nil, simulates the conditions of a poor network connection by delaying all data sent
+	// and received over the connection according to the NetSim. It is intended for testing and should not
+	// be set otherwise.
+	Simulate *NetSim
+
+	// network is the Network used instead of the Network registered under the ID passed. It is set by Pipe.
+	network Network
 }
 
 // Dial dials a Minecraft connection to the address passed over the network passed. The network is typically
@@ -170,10 +282,17 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	if d.FlushRate == 0 {
 		d.FlushRate = time.Second / 20
 	}
+	if err := checkPackets(d.Protocol.Packets(false), d.Packets, d.OverridePackets); err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: err}
+	}
 
-	key, err := ecdsa.GenerateKey(elliptic.P384(), cryptorand.Reader)
-	if err != nil {
-		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("generating ECDSA key: %w", err)}
+	key := d.PrivateKey
+	if key == nil {
+		if key, err = ecdsa.GenerateKey(elliptic.P384(), cryptorand.Reader); err != nil {
+			return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("generating ECDSA key: %w", err)}
+		}
+	} else if key.Curve != elliptic.P384() {
+		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("private key must use the P-384 curve")}
 	}
 	var chainData string
 	if d.TokenSource != nil || d.XBLToken != nil {
@@ -192,10 +311,28 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 		d.IdentityData = identityData
 	}
 
-	n, ok := networkByID(network, d.ErrorLog)
+	n, ok := d.network, d.network != nil
+	if !ok {
+		n, ok = networkByID(network, d.ErrorLog)
+	}
 	if !ok {
 		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("dial: no network under id %v", network)}
 	}
+	if d.DialFunc != nil {
+		r, ok := n.(RakNet)
+		if !ok {
+			return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("dial: DialFunc is not supported by network %v", network)}
+		}
+		r.dial = d.DialFunc
+		n = r
+	}
+	mtu := new(atomic.Uint32)
+	if r, ok := n.(RakNet); ok {
+		r.maxMTU, r.mtu = d.MaxMTU, mtu
+		n = r
+	} else if d.MaxMTU != 0 {
+		return nil, &net.OpError{Op: "dial", Net: "minecraft", Err: fmt.Errorf("dial: MaxMTU is not supported by network %v", network)}
+	}
 
 	var pong []byte
 	var netConn net.Conn
@@ -207,17 +344,29 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	if err != nil {
 		return nil, err
 	}
+	if d.Simulate != nil {
+		netConn = newSimConn(netConn, *d.Simulate)
+	}
 
 	conn = newConn(netConn, key, d.ErrorLog, d.Protocol, d.FlushRate, false)
-	conn.pool = conn.proto.Packets(false)
+	conn.customPackets = d.Packets
+	conn.setPool(conn.proto.Packets(false))
 	conn.identityData = d.IdentityData
 	conn.clientData = d.ClientData
 	conn.packetFunc = d.PacketFunc
+	conn.packetFilter = d.PacketFilter
+	conn.transferFunc = d.TransferFunc
 	conn.downloadResourcePack = d.DownloadResourcePack
+	conn.acceptResourcePack = d.AcceptResourcePack
+	conn.resourcePackWriter = d.ResourcePackWriter
 	conn.cacheEnabled = d.EnableClientCache
 	conn.disconnectOnInvalidPacket = d.DisconnectOnInvalidPackets
 	conn.disconnectOnUnknownPacket = d.DisconnectOnUnknownPackets
+	conn.zeroCopy = d.ZeroCopy
+	conn.compressionFallback = d.CompressionFallback
+	conn.mtu = int(mtu.Load())
 	conn.maxDecompressedLen = math.MaxInt
+	conn.decompressLimiter = decompressLimiter(d.MaxDecompressedRate, d.DecompressedBurst)
 
 	defaultIdentityData(&conn.identityData)
 	defaultClientData(address, conn.identityData.DisplayName, &conn.clientData)
@@ -232,21 +381,27 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 		}
 		request = login.EncodeOffline(conn.identityData, conn.clientData, key, d.EnableLegacyAuth)
 	} else {
-		// We login as an Android device and this will show up in the 'titleId' field in the JWT chain, which
-		// we can't edit. We just enforce Android data for logging in.
-		setAndroidData(&conn.clientData)
-
 		request = login.Encode(chainData, conn.clientData, key, d.EnableLegacyAuth)
 		identityData, _, _, _ := login.Parse(request)
 		// If we got the identity data from Minecraft auth, we need to make sure we set it in the Conn too, as
 		// we are not aware of the identity data ourselves yet.
 		conn.identityData = identityData
 	}
+	conn.log = conn.log.With("xuid", conn.identityData.XUID, "name", conn.identityData.DisplayName)
+	conn.rawChainData, conn.rawClientData, _ = login.SplitRequest(request)
 
 	readyForLogin, connected := make(chan struct{}), make(chan struct{})
 	ctx, cancel := context.WithCancelCause(ctx)
 	go listenConn(conn, readyForLogin, connected, cancel)
 
+	var handshakeTimeout <-chan time.Time
+	if d.HandshakeTimeout > 0 {
+		t := time.NewTimer(d.HandshakeTimeout)
+		defer t.Stop()
+		handshakeTimeout = t.C
+	}
+	conn.phase.Store(phaseLogin)
+
 	conn.expect(packet.IDNetworkSettings, packet.IDPlayStatus)
 	if err := conn.WritePacket(&packet.RequestNetworkSettings{ClientProtocol: d.Protocol.ID()}); err != nil {
 		return nil, conn.wrap(fmt.Errorf("send request network settings: %w", err), "dial")
@@ -258,6 +413,8 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 		return nil, conn.wrap(context.Cause(ctx), "dial")
 	case <-conn.ctx.Done():
 		return nil, conn.closeErr("dial")
+	case <-handshakeTimeout:
+		return nil, conn.handshakeTimeout()
 	case <-readyForLogin:
 		// We've received our network settings, so we can now send our login request.
 		conn.expect(packet.IDServerToClientHandshake, packet.IDPlayStatus)
@@ -271,6 +428,8 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 			return nil, conn.wrap(context.Cause(ctx), "dial")
 		case <-conn.ctx.Done():
 			return nil, conn.closeErr("dial")
+		case <-handshakeTimeout:
+			return nil, conn.handshakeTimeout()
 		case <-connected:
 			// We've connected successfully. We return the connection and no error.
 			return conn, nil
@@ -278,6 +437,29 @@ func (d Dialer) DialContext(ctx context.Context, network, address string) (conn
 	}
 }
 
+// DialTransfer dials the server that the Transfer packet passed, read from the Conn passed, transfers the
+// client to, over the network passed. The Conn passed is left open and should typically be closed by the
+// caller once DialTransfer returns, as the client would otherwise be connected to both servers.
+// The client data of the Conn passed, such as its skin and device ID, is reused for the new connection, so
+// that the client appears to the new server as the same client. If the Dialer has a TokenSource, it is used
+// to authenticate again, which does not require the user to log in again if the TokenSource holds a valid
+// token, like the TokenSource from auth.RefreshTokenSource.
+func (d Dialer) DialTransfer(ctx context.Context, network string, conn *Conn, pk *packet.Transfer) (*Conn, error) {
+	d.ClientData = conn.ClientData()
+	// The server address is that of the server transferred from, so it is filled again with the new address.
+	d.ClientData.ServerAddress = ""
+	d.IdentityData = conn.IdentityData()
+	return d.DialContext(ctx, network, net.JoinHostPort(pk.Address, strconv.Itoa(int(pk.Port))))
+}
+
+// handshakeTimeout closes the Conn and returns a HandshakeTimeoutError for the phase of the login sequence
+// that the Conn is currently in.
+func (conn *Conn) handshakeTimeout() error {
+	err := conn.wrap(HandshakeTimeoutError{Phase: conn.phase.Load().(string)}, "dial")
+	_ = conn.close(err)
+	return err
+}
+
 // readChainIdentityData reads a login.IdentityData from the Mojang chain
 // obtained through authentication.
 func readChainIdentityData(chainData []byte) (login.IdentityData, error) {
@@ -307,12 +489,15 @@ func readChainIdentityData(chainData []byte) (login.IdentityData, error) {
 func listenConn(conn *Conn, readyForLogin, connected chan struct{}, cancel context.CancelCauseFunc) {
 	defer func() {
 		_ = conn.Close()
+		// Packs of which the download never started still hold the writers obtained from the resource
+		// pack writer, which are closed once no more packets are handled.
+		conn.closeResourcePackWriters()
 	}()
 	cancelContext := true
 	for {
 		// We finally arrived at the packet decoding loop. We constantly decode packets that arrive
 		// and push them to the Conn so that they may be processed.
-		packets, err := conn.dec.Decode()
+		packets, err := conn.readBatch()
 		if err != nil {
 			if !errors.Is(err, net.ErrClosed) {
 				if cancelContext {
@@ -384,8 +569,12 @@ var skinGeometry []byte
 
 // defaultClientData edits the ClientData passed to have defaults set to all fields that were left unchanged.
 func defaultClientData(address, username string, d *login.ClientData) {
-	d.ServerAddress = address
-	d.ThirdPartyName = username
+	if d.ServerAddress == "" {
+		d.ServerAddress = address
+	}
+	if d.ThirdPartyName == "" {
+		d.ThirdPartyName = username
+	}
 	if d.DeviceOS == 0 {
 		d.DeviceOS = protocol.DeviceAndroid
 	}
@@ -443,12 +632,6 @@ func defaultClientData(address, username string, d *login.ClientData) {
 	}
 }
 
-// setAndroidData ensures the login.ClientData passed matches settings you would see on an Android device.
-func setAndroidData(data *login.ClientData) {
-	data.DeviceOS = protocol.DeviceAndroid
-	data.GameVersion = protocol.CurrentVersion
-}
-
 // clearXBLIdentityData clears data from the login.IdentityData that is only set when a player is logged into
 // XBOX Live.
 func clearXBLIdentityData(data *login.IdentityData) {
@@ -489,16 +672,21 @@ func splitPong(s string) []string {
 	return append(tokens, string(runes))
 }
 
-// addressWithPongPort parses the redirect IPv4 port from the pong and returns the address passed with the port
-// found if present, or the original address if not.
+// addressWithPongPort parses the redirect port from the pong and returns the address passed with the port
+// found if present, or the original address if not. The IPv6 port of the pong is used if the address passed
+// holds an IPv6 address, and the IPv4 port otherwise.
 func addressWithPongPort(pong []byte, address string) string {
 	frag := splitPong(string(pong))
-	if len(frag) > 10 {
-		portStr := frag[10]
+	i, defaultPort := 10, 19132
+	if host, _, err := net.SplitHostPort(address); err == nil && strings.Contains(host, ":") {
+		i, defaultPort = 11, 19133
+	}
+	if len(frag) > i {
+		portStr := frag[i]
 		port, err := strconv.Atoi(portStr)
-		// Vanilla (realms, in particular) will sometimes send port 19132 when you ping a port that isn't 19132 already,
-		// but we should ignore that.
-		if err != nil || port == 19132 {
+		// Vanilla (realms, in particular) will sometimes send the default port when you ping a port that isn't the
+		// default port already, but we should ignore that.
+		if err != nil || port == defaultPort || port <= 0 {
 			return address
 		}
 		// Remove the port from the address.
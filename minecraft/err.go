@@ -1,12 +1,22 @@
 package minecraft
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
+	"strings"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 )
 
 var errBufferTooSmall = errors.New("a message sent was larger than the buffer used to receive the message into")
 
+// ErrDecompressRateExceeded is the error a Conn is closed with if the other end sends batches that decompress
+// to more bytes per second than allowed by ListenConfig.MaxDecompressedRate or Dialer.MaxDecompressedRate.
+// It is wrapped in a net.OpError returned by operations on the Conn after it is closed.
+var ErrDecompressRateExceeded = errors.New("rate of decompressed bytes exceeded")
+
 // wrap wraps the error passed into a net.OpError with the op as operation and returns it, or nil if the error
 // passed is nil.
 func (conn *Conn) wrap(err error, op string) error {
@@ -31,3 +41,110 @@ type DisconnectError string
 func (d DisconnectError) Error() string {
 	return string(d)
 }
+
+// FlushError is returned by Conn.Flush and Conn.FlushContext, wrapped in a net.OpError, if the packets buffered
+// by the Conn could not be written in full. Because the other end of the connection cannot decode any data
+// following a partially written batch, the Conn is closed when a FlushError is returned.
+type FlushError struct {
+	// Written is the number of bytes of the batch that were written before writing failed.
+	Written int
+	// Len is the total length of the batch in bytes. It is 0 if the batch failed to be encoded before it
+	// could be written.
+	Len int
+	// Err is the error that caused the flush to fail.
+	Err error
+}
+
+// Error ...
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("flush: wrote %v of %v bytes: %v", e.Written, e.Len, e.Err)
+}
+
+// Unwrap returns the error that caused the flush to fail.
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the flush failed because a write deadline or the deadline of a context passed.
+func (e *FlushError) Timeout() bool {
+	var netErr net.Error
+	return errors.Is(e.Err, context.DeadlineExceeded) || (errors.As(e.Err, &netErr) && netErr.Timeout())
+}
+
+// SpawnError is returned by Conn.DoSpawnContext and Conn.StartGameContext, wrapped in a net.OpError, if the
+// context passed is done before the spawn sequence is complete. Expected holds the names of the packets that
+// the Conn was waiting for at that time, which identifies the step of the spawn sequence that was not
+// completed.
+type SpawnError struct {
+	Expected []string
+	// Err is the error of the context that was done.
+	Err error
+}
+
+// Error ...
+func (e *SpawnError) Error() string {
+	return fmt.Sprintf("spawn sequence not completed while waiting for %v: %v", strings.Join(e.Expected, " or "), e.Err)
+}
+
+// Unwrap returns the error of the context that was done.
+func (e *SpawnError) Unwrap() error {
+	return e.Err
+}
+
+// UnexpectedPacketError is returned when a client accepted by a Listener sends a packet that is not part of
+// the current phase of the login sequence before it is logged in. The client is disconnected when this
+// happens. Expected holds the names of the packets that the Conn was waiting for at that time.
+type UnexpectedPacketError struct {
+	PacketID uint32
+	Expected []string
+}
+
+// Error ...
+func (e *UnexpectedPacketError) Error() string {
+	return fmt.Sprintf("unexpected packet (ID=%v) during login while waiting for %v", e.PacketID, strings.Join(e.Expected, " or "))
+}
+
+// DecodeError is the error produced when a packet read could not be decoded, either because its payload was
+// malformed, which makes the packet panic while decoding, or because bytes were left after decoding it.
+// Packets that fail to decode after the login sequence are logged and skipped, leaving the Conn usable, unless
+// the Conn is configured to disconnect on invalid packets.
+type DecodeError struct {
+	// PacketID is the ID of the packet that failed to decode.
+	PacketID uint32
+	// Packet is the packet that failed to decode, holding the fields decoded before the error occurred.
+	Packet packet.Packet
+	// Err is the error that occurred while decoding the packet.
+	Err error
+}
+
+// Error ...
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode packet %T (ID=%v): %v", e.Packet, e.PacketID, e.Err)
+}
+
+// Unwrap returns the error that occurred while decoding the packet.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// HandshakeTimeoutError is returned by Dialer.DialContext, wrapped in a net.OpError, if the login sequence was
+// not completed within the Dialer.HandshakeTimeout. Phase holds the phase of the login sequence that the
+// connection was in when the timeout expired: "login", "resource pack" or "spawn".
+type HandshakeTimeoutError struct {
+	Phase string
+}
+
+// Error ...
+func (e HandshakeTimeoutError) Error() string {
+	return "handshake timed out during " + e.Phase + " phase"
+}
+
+// Timeout always returns true.
+func (e HandshakeTimeoutError) Timeout() bool {
+	return true
+}
+
+// Unwrap returns context.DeadlineExceeded, so that errors.Is may be used to check for timeouts.
+func (e HandshakeTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
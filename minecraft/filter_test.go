@@ -0,0 +1,52 @@
+package minecraft_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestPacketFilterSpawn checks that a packet filter dropping every packet written does not obstruct the
+// login and spawn sequence, and that packets written after spawning are passed to the filter.
+func TestPacketFilterSpawn(t *testing.T) {
+	dropAll := func(pk packet.Packet, incoming bool) (packet.Packet, bool, error) {
+		return nil, !incoming, nil
+	}
+	replaceText := func(pk packet.Packet, incoming bool) (packet.Packet, bool, error) {
+		if text, ok := pk.(*packet.Text); ok && !incoming {
+			return &packet.Text{TextType: text.TextType, Message: "filtered"}, false, nil
+		}
+		return nil, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{PacketFilter: dropAll}, minecraft.ListenConfig{PacketFilter: replaceText}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	if err := server.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: "original"}); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+	if err := server.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		pk, err := client.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if text, ok := pk.(*packet.Text); ok {
+			if text.Message != "filtered" {
+				t.Fatalf("expected text to be replaced by the filter, got %q", text.Message)
+			}
+			return
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package minecraft
 
 import (
+	"reflect"
+
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
@@ -110,4 +112,110 @@ type GameData struct {
 	// its index in the expected block palette. This is useful for servers that wish to support multiple protocol versions
 	// and custom blocks, but it will result in extra bytes being written for every block in a sub chunk palette.
 	UseBlockNetworkIDHashes bool
+	// EducationEditionOffer specifies the region of Minecraft: Education Edition that the world is from, with
+	// 0 being None, 1 being RestOfWorld and 2 being China.
+	EducationEditionOffer int32
+	// EducationFeaturesDisabled is true if the features specific to Minecraft: Education Edition, such as its
+	// blocks and entities, are disabled in the world. It is the negation of the EducationFeaturesEnabled field
+	// of the StartGame packet: StartGame always enabled these features before the field was added to
+	// GameData, and the field is negated so that the zero value of GameData keeps doing so.
+	EducationFeaturesDisabled bool
+	// EducationProductID is a UUID identifying the Minecraft: Education Edition server instance.
+	EducationProductID string
+	// EducationSharedResourceURI holds the button name and link of a resource shared with Minecraft:
+	// Education Edition clients.
+	EducationSharedResourceURI protocol.EducationSharedResourceURI
+}
+
+// GameDataDelta holds the differences between two GameData values, as returned by GameData.Diff. It may be
+// used by a proxy moving a client from one server to another to find out which packets must be sent to the
+// client to update it to the GameData of the new server.
+type GameDataDelta struct {
+	// Dimension is true if the dimensions differ. A packet.ChangeDimension must be sent to move the client
+	// to the new dimension, which also clears all chunks held by the client.
+	Dimension bool
+	// Position is true if the PlayerPosition, Pitch or Yaw differ. The client may be moved using a
+	// packet.MovePlayer.
+	Position bool
+	// EntityIDs is true if the EntityUniqueID or EntityRuntimeID differ. The client keeps the entity IDs it
+	// received in the StartGame packet, so the IDs of the player must be translated in packets passed
+	// between the client and the new server.
+	EntityIDs bool
+	// PlayerGameMode is true if the game modes of the player differ. It may be updated using a
+	// packet.SetPlayerGameType.
+	PlayerGameMode bool
+	// WorldGameMode is true if the default game modes of the world differ. It may be updated using a
+	// packet.SetDefaultGameType.
+	WorldGameMode bool
+	// Difficulty is true if the difficulties differ. It may be updated using a packet.SetDifficulty.
+	Difficulty bool
+	// Time is true if the world times differ. It may be updated using a packet.SetTime.
+	Time bool
+	// WorldSpawn is true if the world spawns differ. It may be updated using a packet.SetSpawnPosition.
+	WorldSpawn bool
+	// PlayerPermissions is true if the permission levels of the player differ. It may be updated using a
+	// packet.UpdateAbilities.
+	PlayerPermissions bool
+	// ChunkRadius is true if the chunk radii differ. It may be updated using a packet.ChunkRadiusUpdated.
+	ChunkRadius bool
+	// GameRules holds the game rules of the new GameData that are not present in the old GameData or have a
+	// different value, so that they may be sent using a packet.GameRulesChanged. Game rules only present in
+	// the old GameData cannot be removed from the client and are not included.
+	GameRules []protocol.GameRule
+	// Static holds the names of the fields of GameData that differ but cannot be updated after the
+	// StartGame packet, such as CustomBlocks, Items and UseBlockNetworkIDHashes. If Static is not empty,
+	// the client must be transferred to the new server to fully update it.
+	Static []string
+}
+
+// Empty checks if the GameDataDelta holds no differences.
+func (delta GameDataDelta) Empty() bool {
+	return !delta.Dimension && !delta.Position && !delta.EntityIDs && !delta.PlayerGameMode && !delta.WorldGameMode &&
+		!delta.Difficulty && !delta.Time && !delta.WorldSpawn && !delta.PlayerPermissions && !delta.ChunkRadius &&
+		len(delta.GameRules) == 0 && len(delta.Static) == 0
+}
+
+// Diff compares the GameData with the GameData passed, which is typically the GameData of a server that a
+// client is moved to, and returns the differences between them. Fields that are only informative, such as
+// WorldName and WorldSeed, are not compared.
+func (data GameData) Diff(other GameData) GameDataDelta {
+	delta := GameDataDelta{
+		Dimension:         data.Dimension != other.Dimension,
+		Position:          data.PlayerPosition != other.PlayerPosition || data.Pitch != other.Pitch || data.Yaw != other.Yaw,
+		EntityIDs:         data.EntityUniqueID != other.EntityUniqueID || data.EntityRuntimeID != other.EntityRuntimeID,
+		PlayerGameMode:    data.PlayerGameMode != other.PlayerGameMode,
+		WorldGameMode:     data.WorldGameMode != other.WorldGameMode,
+		Difficulty:        data.Difficulty != other.Difficulty,
+		Time:              data.Time != other.Time,
+		WorldSpawn:        data.WorldSpawn != other.WorldSpawn,
+		PlayerPermissions: data.PlayerPermissions != other.PlayerPermissions,
+		ChunkRadius:       data.ChunkRadius != other.ChunkRadius,
+	}
+	rules := make(map[string]any, len(data.GameRules))
+	for _, rule := range data.GameRules {
+		rules[rule.Name] = rule.Value
+	}
+	for _, rule := range other.GameRules {
+		if v, ok := rules[rule.Name]; !ok || !reflect.DeepEqual(v, rule.Value) {
+			delta.GameRules = append(delta.GameRules, rule)
+		}
+	}
+
+	a, b := reflect.ValueOf(data), reflect.ValueOf(other)
+	for _, name := range staticGameDataFields {
+		if !reflect.DeepEqual(a.FieldByName(name).Interface(), b.FieldByName(name).Interface()) {
+			delta.Static = append(delta.Static, name)
+		}
+	}
+	return delta
+}
+
+// staticGameDataFields holds the names of the fields of GameData that the client only accepts in the
+// StartGame packet.
+var staticGameDataFields = []string{
+	"BaseGameVersion", "Hardcore", "EditorWorldType", "CreatedInEditor", "ExportedFromEditor",
+	"PersonaDisabled", "CustomSkinsDisabled", "EmoteChatMuted", "ServerBlockStateChecksum", "CustomBlocks",
+	"Items", "PlayerMovementSettings", "ServerAuthoritativeInventory", "Experiments", "ClientSideGeneration",
+	"ChatRestrictionLevel", "DisablePlayerInteractions", "UseBlockNetworkIDHashes", "EducationEditionOffer",
+	"EducationFeaturesDisabled", "EducationProductID", "EducationSharedResourceURI",
 }
@@ -0,0 +1,108 @@
+package minecraft_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// TestGameDataEducation checks that the education fields of the GameData passed to StartGame are sent to the
+// client in the StartGame packet.
+func TestGameDataEducation(t *testing.T) {
+	tests := []minecraft.GameData{
+		{},
+		{
+			EducationEditionOffer:      2,
+			EducationFeaturesDisabled:  true,
+			EducationProductID:         "3d4bb6b2-4bfa-4e6a-9a37-8ac34a2db6d1",
+			EducationSharedResourceURI: protocol.EducationSharedResourceURI{ButtonName: "Lesson", LinkURI: "https://example.com/lesson"},
+		},
+	}
+	for _, data := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{}, minecraft.ListenConfig{}, data)
+		cancel()
+		if err != nil {
+			t.Fatalf("pipe: %v", err)
+		}
+		got := client.GameData()
+		_ = client.Close()
+		_ = server.Close()
+
+		if got.EducationEditionOffer != data.EducationEditionOffer || got.EducationFeaturesDisabled != data.EducationFeaturesDisabled ||
+			got.EducationProductID != data.EducationProductID || got.EducationSharedResourceURI != data.EducationSharedResourceURI {
+			t.Errorf("education fields not preserved: sent %+v, got %+v", data, got)
+		}
+	}
+}
+
+func TestGameDataDiff(t *testing.T) {
+	old := minecraft.GameData{
+		WorldName:       "lobby",
+		Dimension:       0,
+		PlayerPosition:  mgl32.Vec3{0, 64, 0},
+		EntityUniqueID:  1,
+		EntityRuntimeID: 1,
+		PlayerGameMode:  2,
+		WorldGameMode:   2,
+		Difficulty:      1,
+		Time:            1000,
+		ChunkRadius:     8,
+		GameRules: []protocol.GameRule{
+			{Name: "dodaylightcycle", Value: false},
+			{Name: "randomtickspeed", Value: uint32(1)},
+		},
+		BaseGameVersion: "1.17.0",
+	}
+	if delta := old.Diff(old); !delta.Empty() {
+		t.Errorf("expected no differences between equal GameData, got %+v", delta)
+	}
+
+	other := old
+	other.WorldName, other.WorldSeed = "survival", 12345
+	if delta := old.Diff(other); !delta.Empty() {
+		t.Errorf("expected informative fields not to be compared, got %+v", delta)
+	}
+
+	other.Dimension = 1
+	other.Yaw = 90
+	other.Difficulty = 3
+	other.ChunkRadius = 12
+	other.GameRules = []protocol.GameRule{
+		{Name: "dodaylightcycle", Value: false},
+		{Name: "randomtickspeed", Value: uint32(3)},
+		{Name: "showcoordinates", Value: true},
+	}
+	other.BaseGameVersion = "1.21.0"
+	other.UseBlockNetworkIDHashes = true
+	want := minecraft.GameDataDelta{
+		Dimension:   true,
+		Position:    true,
+		Difficulty:  true,
+		ChunkRadius: true,
+		GameRules: []protocol.GameRule{
+			{Name: "randomtickspeed", Value: uint32(3)},
+			{Name: "showcoordinates", Value: true},
+		},
+		Static: []string{"BaseGameVersion", "UseBlockNetworkIDHashes"},
+	}
+	delta := old.Diff(other)
+	if !reflect.DeepEqual(delta, want) {
+		t.Errorf("expected %+v, got %+v", want, delta)
+	}
+	if delta.Empty() {
+		t.Error("expected delta with differences not to be empty")
+	}
+
+	// Game rules only present in the old GameData cannot be removed and are not reported.
+	other = old
+	other.GameRules = nil
+	if delta := old.Diff(other); !delta.Empty() {
+		t.Errorf("expected removed game rules not to be reported, got %+v", delta)
+	}
+}
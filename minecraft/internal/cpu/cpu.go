@@ -0,0 +1,6 @@
+// Package cpu detects features of the CPU that the program is running on.
+package cpu
+
+// X86HasAVX2 is true if the CPU is an x86 CPU that supports AVX2 instructions and the operating system
+// supports using them. It is always false on other architectures.
+var X86HasAVX2 bool
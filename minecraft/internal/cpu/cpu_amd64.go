@@ -0,0 +1,25 @@
+package cpu
+
+func init() {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	osxsave, avx := ecx1&(1<<27) != 0, ecx1&(1<<28) != 0
+	if !osxsave || !avx {
+		return
+	}
+	// The operating system must save the XMM and YMM registers on context switches for AVX to be usable.
+	if xcr0, _ := xgetbv(); xcr0&0x6 != 0x6 {
+		return
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	X86HasAVX2 = ebx7&(1<<5) != 0
+}
+
+// cpuid executes the CPUID instruction with the leaf and sub-leaf passed.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// xgetbv executes the XGETBV instruction, returning the value of the XCR0 register.
+func xgetbv() (eax, edx uint32)
@@ -25,14 +25,24 @@ import (
 
 // ListenConfig holds settings that may be edited to change behaviour of a Listener.
 type ListenConfig struct {
-	// ErrorLog is a log.Logger that errors that occur during packet handling of
-	// clients are written to. By default, errors are not logged.
+	// ErrorLog is a slog.Logger that errors that occur during packet handling of
+	// clients are written to. Records logged for a Conn carry the remote address and,
+	// once known, the XUID and display name of the player as attributes, so that
+	// a custom slog.Handler may route them to any sink. By default, errors are not
+	// logged.
 	ErrorLog *slog.Logger
 
 	// AuthenticationDisabled specifies if authentication of players that join is disabled. If set to true, no
 	// verification will be done to ensure that the player connecting is authenticated using their XBOX Live
 	// account.
 	AuthenticationDisabled bool
+	// OfflineIdentity specifies if the Identity of the IdentityData of players that are not logged into XBOX
+	// Live is replaced with a UUID derived from their display name, as returned by login.OfflineUUID. The login
+	// chain of these players is still parsed and verified to be well-formed, but it is self-signed, so the
+	// identity data it holds, including the display name and XUID, must not be trusted. OfflineIdentity is
+	// typically used together with AuthenticationDisabled for LAN or test servers, so that players have an
+	// identity that is consistent across sessions.
+	OfflineIdentity bool
 
 	// MaximumPlayers is the maximum amount of players accepted in the server. If non-zero, players that
 	// attempt to join while the server is full will be kicked during login. If zero, the maximum player count
@@ -46,27 +56,80 @@ type ListenConfig struct {
 	AllowUnknownPackets bool
 
 	// AllowInvalidPackets specifies if invalid packets (either too few bytes or too many bytes) should be
-	// allowed. If false (by default), such packets lead to the connection being closed immediately. If true,
-	// packets with too many bytes will be returned while packets with too few bytes will be skipped.
+	// allowed. If false (by default), such packets lead to the connection being closed immediately, after
+	// which Conn.ReadPacket returns an error wrapping a *DecodeError. If true, packets with too many bytes will
+	// be returned while packets with too few bytes will be skipped.
 	AllowInvalidPackets bool
+	// ZeroCopy specifies if the data returned by Conn.ReadPacketAndBytes is returned without copying it.
+	// By default, this data, and the payload of a *packet.Unknown returned for packets excluded using
+	// Conn.SetDecodeFilter, is copied out of the batch it was read from, so that it may be retained. If
+	// ZeroCopy is true, the data refers to the batch directly and is only valid until the next call to
+	// ReadPacket: It must be copied if it is used after that. Packets decoded are not affected, as their
+	// fields never refer to the data they were decoded from.
+	ZeroCopy bool
 
 	// StatusProvider is the ServerStatusProvider of the Listener. When set to nil, the default provider,
 	// ListenerStatusProvider, is used as provider.
+	// For the "raknet" network, the provider is called when an unconnected ping is received, so that the
+	// status sent in response, such as a live player count, is up to date. To protect against floods of
+	// pings, the provider is called at most once every 100 milliseconds, in which case pings are responded
+	// to with the status last returned. The provider is called on the goroutine reading packets from the
+	// network, so it should return quickly. Additionally, the status is refreshed every StatusRefreshRate, and
+	// when a player joins or leaves.
 	StatusProvider ServerStatusProvider
+	// StatusRefreshRate is the rate at which the ServerStatusProvider is called to refresh the status shown in
+	// the server list, regardless of pings received. It is the only rate at which the status is refreshed for
+	// networks other than "raknet". If 0, the default StatusRefreshRate of 4 seconds is used.
+	StatusRefreshRate time.Duration
 
 	// AcceptedProtocols is a slice of Protocol accepted by a Listener created with this ListenConfig. The current
 	// Protocol is always added to this slice. Clients with a protocol version that is not present in this slice will
 	// be disconnected.
 	AcceptedProtocols []Protocol
+	// MinimumProtocol and MaximumProtocol are the lowest and highest protocol IDs of clients accepted by the
+	// Listener. Clients with a protocol outside of this range are disconnected as soon as their protocol is
+	// known, even if it is present in AcceptedProtocols. If zero, the protocol is not limited in that
+	// direction.
+	MinimumProtocol, MaximumProtocol int32
+	// OutdatedClientMessage is the message that clients with a protocol below MinimumProtocol, or with a
+	// protocol older than any of the accepted protocols, are disconnected with. Any occurrence of "{protocol}"
+	// is replaced with the lowest protocol ID accepted. If empty, the client is disconnected with a PlayStatus
+	// packet instead, for which the client shows its own message asking the player to update the game.
+	OutdatedClientMessage string
+	// OutdatedServerMessage is like OutdatedClientMessage, but for clients with a protocol above
+	// MaximumProtocol or newer than any of the accepted protocols. Any occurrence of "{protocol}" is replaced
+	// with the highest protocol ID accepted.
+	OutdatedServerMessage string
 	// Compression is the packet.Compression to use for packets sent over this Conn. If set to nil, the compression
-	// will default to packet.flateCompression.
+	// will default to packet.flateCompression. If set to packet.SnappyCompression while packet.SnappyAvailable
+	// returns false, packet.DefaultCompression is used instead. If set to packet.NopCompression, clients are
+	// told not to compress batches at all, and batches are sent and read uncompressed.
 	Compression packet.Compression // TODO: Change this to snappy once Windows crashes are resolved.
+	// CompressionThreshold is the minimum size in bytes of a batch of packets for it to be compressed. Smaller
+	// batches are sent uncompressed, which saves CPU time for batches that barely compress. The threshold is
+	// sent to clients in the NetworkSettings packet and only applies to clients on 1.20.60 or above. If 0,
+	// the default value of 512 is used. Setting this to a negative integer results in all batches being
+	// compressed.
+	CompressionThreshold int
+	// Packets is a packet.Pool holding custom packets that are added to the packets decoded by connections
+	// accepted by the Listener. Packets read with an ID found in Packets are decoded into the packet returned by the
+	// function registered, rather than into a *packet.Unknown. Listen returns an error if Packets holds
+	// an ID of a packet already implemented by any of the AcceptedProtocols or the current protocol, unless
+	// OverridePackets is true.
+	Packets packet.Pool
+	// OverridePackets specifies if packets in Packets may replace packets with the same ID that are already
+	// implemented.
+	OverridePackets bool
+	// ClientThrottle holds the client throttling settings sent to clients in the NetworkSettings packet. By
+	// default, client throttling is disabled.
+	ClientThrottle ClientThrottle
 	// FlushRate is the rate at which packets sent are flushed. Packets are buffered for a duration up to
 	// FlushRate and are compressed/encrypted together to improve compression ratios. The lower this
 	// time.Duration, the lower the latency but the less efficient both network and cpu wise.
 	// The default FlushRate (when set to 0) is time.Second/20. If FlushRate is set negative, packets
 	// will not be flushed automatically. In this case, calling `(*Conn).Flush()` is required after any
 	// calls to `(*Conn).Write()` or `(*Conn).WritePacket()` to send the packets over network.
+	// The rate may be changed for an individual Conn using `(*Conn).SetFlushInterval()`.
 	FlushRate time.Duration
 
 	// ResourcePacks is a slice of resource packs that the listener may hold. Each client will be asked to
@@ -88,9 +151,80 @@ type ListenConfig struct {
 	// from which the packet originated, and the destination address.
 	PacketFunc func(header packet.Header, payload []byte, src, dst net.Addr)
 
+	// PacketFilter is called for every packet read using Conn.ReadPacket and every packet written using
+	// Conn.WritePacket after the connection has spawned. incoming is true for packets read and false for
+	// packets written. A non-nil packet returned replaces the packet passed, so that it is returned by
+	// ReadPacket or written to the network instead. If drop is true, the packet is discarded instead.
+	// If a non-nil error is returned, ReadPacket or WritePacket returns it.
+	// Packets of the login and spawn sequence, including those written by the Conn itself, are never passed
+	// to PacketFilter, so that these sequences cannot be obstructed by dropping or modifying packets.
+	PacketFilter func(pk packet.Packet, incoming bool) (modified packet.Packet, drop bool, err error)
+
+	// OnLogin, if set, is called with the identity data and client data of a client once its login request
+	// has been verified, before the resource packs are sent and before the client is spawned. It may be used
+	// to check a client against a ban list or allowlist. If OnLogin returns a non-nil error, the client is
+	// disconnected with the message of the error and the connection is never returned by Listener.Accept.
+	// OnLogin is called on the goroutine that handles the login of the client, so it may block to look up
+	// data without holding up the logins of other clients.
+	OnLogin func(identityData login.IdentityData, clientData login.ClientData) error
+
+	// LoginFailed, if set, is called with the address of a client and the error that occurred if the client
+	// fails to log in, after which the connection is closed. Errors caused by a login request that could not be
+	// verified wrap one of login.ErrChainInvalid, login.ErrChainUntrusted, login.ErrTokenExpired or
+	// login.ErrClientDataInvalid, so that they may be told apart using errors.Is, for example to rate limit
+	// the address of clients sending forged login requests.
+	LoginFailed func(addr net.Addr, err error)
+
+	// MaxPendingLogins is the maximum amount of connections that may be in the process of logging in at the
+	// same time. New connections above this amount are closed as soon as they are established, before any of
+	// their packets are handled, so that a flood of connections cannot occupy the Listener with logins. These
+	// connections are never returned by Listener.Accept. If 0, the amount of pending logins is not limited.
+	MaxPendingLogins int
+	// MaxConnectionRate is the maximum amount of new connections per second accepted by the Listener. New
+	// connections above this rate are closed like those above MaxPendingLogins. A burst of up to a second
+	// worth of connections is allowed. If 0, the rate of new connections is not limited.
+	MaxConnectionRate int
+	// MaxConnectionRatePerIP is like MaxConnectionRate, but limits the rate of new connections from a single
+	// IP address. If 0, the rate of new connections per IP address is not limited.
+	MaxConnectionRatePerIP int
+	// NotifyThrottledConnections specifies if connections closed due to MaxPendingLogins,
+	// MaxConnectionRate or MaxConnectionRatePerIP are sent a PlayStatus packet with the
+	// PlayStatusLoginFailedServerFull status before being closed, so that the client shows that the server is
+	// full rather than timing out. By default, these connections are closed without sending any packet.
+	NotifyThrottledConnections bool
+
 	// MaxDecompressedLen is the maximum length of a decompressed packet to prevent potential exploits. If 0,
 	// the default value is 16MB (16 * 1024 * 1024). Setting this to a negative integer disables the limit.
 	MaxDecompressedLen int
+	// MaxPacketsPerBatch is the maximum amount of packets that a client may send in a single batch. Batches
+	// holding more packets are rejected before the packets in them are decoded. If 0, the default limit of
+	// 812 packets is used, which legitimate clients never exceed. If negative, the amount of packets in a
+	// batch is not limited.
+	MaxPacketsPerBatch int
+	// MaxDecompressedRate is the maximum average amount of bytes per second that the batches sent by a client
+	// may decompress to. It protects against a client sending highly compressible data to occupy the CPU with
+	// decompression. If the rate is exceeded, the connection is closed with ErrDecompressRateExceeded. If 0,
+	// the rate is not limited.
+	MaxDecompressedRate int
+	// DecompressedBurst is the amount of decompressed bytes that a client may send at once above
+	// MaxDecompressedRate. If 0, a burst of four seconds at MaxDecompressedRate is allowed.
+	DecompressedBurst int
+	// DualStack specifies if the Listener should accept connections over both IPv4 and IPv6 if the host of
+	// the address passed to Listen is an unspecified IP address, such as 0.0.0.0 or ::. By default, the
+	// Listener only accepts connections of the IP version of such an address, while an address with an empty
+	// host is listened on using both IP versions where the system supports it. DualStack is only supported
+	// by the "raknet" network.
+	DualStack bool
+	// OnUnconnectedPing is called for every unconnected ping received by the Listener, such as those sent by
+	// clients to display the server in their server list, before it is responded to. If it returns false, the
+	// ping is dropped without a response, which may be used to rate limit or block addresses that scan for
+	// servers. OnUnconnectedPing is called on the goroutine reading packets from the network, so it should
+	// return quickly. If nil, every ping is responded to. OnUnconnectedPing is only supported by the
+	// "raknet" network.
+	OnUnconnectedPing func(addr net.Addr) bool
+
+	// network is the Network used instead of the Network registered under the ID passed. It is set by Pipe.
+	network Network
 }
 
 // Listener implements a Minecraft listener on top of an unspecific net.Listener. It abstracts away the
@@ -106,6 +240,15 @@ type Listener struct {
 	// playerCount is the amount of players connected to the server. If MaximumPlayers is non-zero and equal
 	// to the playerCount, no more players will be accepted.
 	playerCount atomic.Int32
+	// pendingLogins is the amount of connections that are not yet logged in. If MaxPendingLogins is non-zero
+	// and equal to pendingLogins, new connections are closed immediately.
+	pendingLogins atomic.Int32
+	// connLimiter limits the rate of new connections if MaxConnectionRate or MaxConnectionRatePerIP is set.
+	// It is only used by the goroutine accepting connections.
+	connLimiter *connLimiter
+	// lastPingRefresh is the time in Unix nanoseconds at which the status was last refreshed because of an
+	// unconnected ping. It is used to limit the rate at which pings call the ServerStatusProvider.
+	lastPingRefresh atomic.Int64
 
 	incoming chan *Conn
 	close    chan struct{}
@@ -115,7 +258,8 @@ type Listener struct {
 
 // Listen announces on the local network address. The network is typically "raknet".
 // If the host in the address parameter is empty or a literal unspecified IP address, Listen listens on all
-// available unicast and anycast IP addresses of the local system.
+// available unicast and anycast IP addresses of the local system. The address may be an IPv4 or IPv6
+// address, such as "[::]:19132". See ListenConfig.DualStack to listen on both IPv4 and IPv6 addresses.
 func (cfg ListenConfig) Listen(network string, address string) (*Listener, error) {
 	if cfg.ErrorLog == nil {
 		cfg.ErrorLog = slog.New(internal.DiscardHandler{})
@@ -127,19 +271,71 @@ func (cfg ListenConfig) Listen(network string, address string) (*Listener, error
 	if cfg.Compression == nil {
 		cfg.Compression = packet.DefaultCompression
 	}
+	if cfg.Compression == packet.SnappyCompression && !packet.SnappyAvailable() {
+		cfg.ErrorLog.Warn("snappy compression is not available on this CPU, falling back to the default compression")
+		cfg.Compression = packet.DefaultCompression
+	}
+	if cfg.CompressionThreshold == 0 {
+		cfg.CompressionThreshold = 512
+	} else if cfg.CompressionThreshold < 0 {
+		// Every batch has at least one byte, so a threshold of 1 results in all batches being compressed.
+		cfg.CompressionThreshold = 1
+	} else if cfg.CompressionThreshold > math.MaxUint16 {
+		cfg.CompressionThreshold = math.MaxUint16
+	}
 	if cfg.FlushRate == 0 {
 		cfg.FlushRate = time.Second / 20
 	}
+	if cfg.StatusRefreshRate <= 0 {
+		cfg.StatusRefreshRate = time.Second * 4
+	}
 	if cfg.MaxDecompressedLen == 0 {
 		cfg.MaxDecompressedLen = 16 * 1024 * 1024 // 16MB
 	} else if cfg.MaxDecompressedLen < 0 {
 		cfg.MaxDecompressedLen = math.MaxInt
 	}
 
-	n, ok := networkByID(network, cfg.ErrorLog)
+	// The protocol of a connection is only known once it logs in, so the custom packets are checked against
+	// the pools of all protocols that may be negotiated.
+	for _, pro := range append(slices.Clone(cfg.AcceptedProtocols), proto{}) {
+		if err := checkPackets(pro.Packets(true), cfg.Packets, cfg.OverridePackets); err != nil {
+			return nil, fmt.Errorf("listen: protocol %v: %w", pro.Ver(), err)
+		}
+	}
+	n, ok := cfg.network, cfg.network != nil
+	if !ok {
+		n, ok = networkByID(network, cfg.ErrorLog)
+	}
 	if !ok {
 		return nil, fmt.Errorf("listen: no network under id %v", network)
 	}
+	if cfg.DualStack {
+		r, ok := n.(RakNet)
+		if !ok {
+			return nil, fmt.Errorf("listen: DualStack is not supported by network %v", network)
+		}
+		r.dualStack = true
+		n = r
+	}
+	if _, ok := n.(RakNet); !ok && cfg.OnUnconnectedPing != nil {
+		return nil, fmt.Errorf("listen: OnUnconnectedPing is not supported by network %v", network)
+	}
+	// pinged holds the Listener once it is created, so that its status may be refreshed when pinged. Pings
+	// may be received before that, in which case the status set when the Listener starts listening is sent.
+	pinged := new(atomic.Pointer[Listener])
+	if r, ok := n.(RakNet); ok {
+		onPing := cfg.OnUnconnectedPing
+		r.onPing = func(addr net.Addr) bool {
+			if onPing != nil && !onPing(addr) {
+				return false
+			}
+			if l := pinged.Load(); l != nil {
+				l.refreshStatus()
+			}
+			return true
+		}
+		n = r
+	}
 
 	netListener, err := n.Listen(address)
 	if err != nil {
@@ -157,6 +353,8 @@ func (cfg ListenConfig) Listen(network string, address string) (*Listener, error
 		close:    make(chan struct{}),
 		key:      key,
 	}
+	listener.connLimiter = newConnLimiter(cfg.MaxConnectionRate, cfg.MaxConnectionRatePerIP)
+	pinged.Store(listener)
 
 	// Actually start listening.
 	go listener.listen(n)
@@ -188,7 +386,8 @@ func (listener *Listener) Accept() (net.Conn, error) {
 
 // Disconnect disconnects a Minecraft Conn passed by first sending a disconnect with the message passed, and
 // closing the connection after. If the message passed is empty, the client will be immediately sent to the
-// server list instead of a disconnect screen.
+// server list instead of a disconnect screen. Unlike Conn.CloseWithDisconnect, Disconnect does not wait for
+// the message to be delivered.
 func (listener *Listener) Disconnect(conn *Conn, message string) error {
 	_ = conn.WritePacket(&packet.Disconnect{
 		HideDisconnectionScreen: message == "",
@@ -197,6 +396,20 @@ func (listener *Listener) Disconnect(conn *Conn, message string) error {
 	return conn.close(conn.closeErr(message))
 }
 
+// DisconnectWithStatus disconnects a Minecraft Conn passed by first sending a PlayStatus packet with the
+// status passed, and closing the connection after. Unlike Disconnect, the client shows its own screen for the
+// status, such as one asking the player to update the game for packet.PlayStatusLoginFailedClient, or one
+// telling the player the server is full for packet.PlayStatusLoginFailedServerFull. The status must be one
+// of the packet.PlayStatusLoginFailed constants, and the Conn should not yet have been spawned using
+// Conn.StartGame, as the client only handles these statuses before it is spawned.
+func (listener *Listener) DisconnectWithStatus(conn *Conn, status int32) error {
+	if status == packet.PlayStatusLoginSuccess || status == packet.PlayStatusPlayerSpawn {
+		return conn.wrap(fmt.Errorf("play status %v is not a login failure status", status), "disconnect")
+	}
+	_ = conn.WritePacket(&packet.PlayStatus{Status: status})
+	return conn.close(conn.closeErr(fmt.Sprintf("login failed with status %v", status)))
+}
+
 // AddResourcePack adds a new resource pack to the listener's resource packs.
 // Note: This method will not update resource packs for active connections.
 func (listener *Listener) AddResourcePack(pack *resource.Pack) {
@@ -235,12 +448,26 @@ func (listener *Listener) updatePongData() {
 	)))
 }
 
+// pingRefreshInterval is the minimum interval between two refreshes of the status of a Listener caused by
+// unconnected pings.
+const pingRefreshInterval = time.Millisecond * 100
+
+// refreshStatus refreshes the pong data of the listener in response to an unconnected ping, unless it was
+// already refreshed because of a ping within the last pingRefreshInterval.
+func (listener *Listener) refreshStatus() {
+	now, last := time.Now().UnixNano(), listener.lastPingRefresh.Load()
+	if now-last < int64(pingRefreshInterval) || !listener.lastPingRefresh.CompareAndSwap(last, now) {
+		return
+	}
+	listener.updatePongData()
+}
+
 // listen starts listening for incoming connections and packets. When a player is fully connected, it submits
 // it to the accepted connections channel so that a call to Accept can pick it up.
 func (listener *Listener) listen(n Network) {
 	listener.updatePongData()
 	go func() {
-		ticker := time.NewTicker(time.Second * 4)
+		ticker := time.NewTicker(listener.cfg.StatusRefreshRate)
 		defer ticker.Stop()
 		for {
 			select {
@@ -277,29 +504,50 @@ func (listener *Listener) createConn(n Network, netConn net.Conn) {
 	conn := newConn(netConn, listener.key, listener.cfg.ErrorLog, proto{}, listener.cfg.FlushRate, true)
 	conn.acceptedProto = append(listener.cfg.AcceptedProtocols, proto{})
 	conn.compression = listener.cfg.Compression
+	conn.compressionThreshold = listener.cfg.CompressionThreshold
+	conn.clientThrottle = listener.cfg.ClientThrottle
+	conn.minProtocol, conn.maxProtocol = listener.cfg.MinimumProtocol, listener.cfg.MaximumProtocol
+	conn.outdatedClientMessage, conn.outdatedServerMessage = listener.cfg.OutdatedClientMessage, listener.cfg.OutdatedServerMessage
 
 	// Temporarily set the protocol to the latest: We don't know the actual protocol until we read the Login packet.
 	conn.proto = proto{}
 	conn.maxDecompressedLen = listener.cfg.MaxDecompressedLen
-	conn.pool = conn.proto.Packets(true)
+	conn.decompressLimiter = decompressLimiter(listener.cfg.MaxDecompressedRate, listener.cfg.DecompressedBurst)
+	if listener.cfg.MaxPacketsPerBatch != 0 {
+		conn.dec.SetMaxPacketsPerBatch(listener.cfg.MaxPacketsPerBatch)
+	}
+	conn.customPackets = listener.cfg.Packets
+	conn.setPool(conn.proto.Packets(true))
 
 	conn.packetFunc = listener.cfg.PacketFunc
+	conn.packetFilter = listener.cfg.PacketFilter
 	conn.texturePacksRequired = listener.cfg.TexturePacksRequired
 	conn.resourcePacks = packs
 	conn.fetchResourcePacks = listener.cfg.FetchResourcePacks
+	conn.onLogin = listener.cfg.OnLogin
 	conn.gameData.WorldName = listener.status().ServerName
 	conn.authEnabled = !listener.cfg.AuthenticationDisabled
+	conn.offlineIdentity = listener.cfg.OfflineIdentity
 	conn.disconnectOnUnknownPacket = !listener.cfg.AllowUnknownPackets
 	conn.disconnectOnInvalidPacket = !listener.cfg.AllowInvalidPackets
+	conn.strictLogin = true
+	conn.zeroCopy = listener.cfg.ZeroCopy
 
 	// Enable compression based on the protocol.
 	// 10 was the last RakNet protocol version, that reading Login packet at the first packet
 	// before RequestNetworkSettings packet getting added on version 11.
-	if netConn.(*raknet.Conn).ProtocolVersion() <= 10 {
+	if c, ok := netConn.(*raknet.Conn); ok && c.ProtocolVersion() <= 10 {
 		conn.enc.EnableCompression(n.Compression(netConn))
 		conn.dec.EnableCompression(n.Compression(netConn), conn.maxDecompressedLen)
 	}
 
+	if listener.throttled(netConn.RemoteAddr()) {
+		if listener.cfg.NotifyThrottledConnections {
+			_ = conn.WritePacket(&packet.PlayStatus{Status: packet.PlayStatusLoginFailedServerFull})
+		}
+		_ = conn.close(conn.closeErr("connection throttled"))
+		return
+	}
 	if listener.playerCount.Load() == int32(listener.cfg.MaximumPlayers) && listener.cfg.MaximumPlayers != 0 {
 		// The server was full. We kick the player immediately and close the connection.
 		_ = conn.WritePacket(&packet.PlayStatus{Status: packet.PlayStatusLoginFailedServerFull})
@@ -307,11 +555,21 @@ func (listener *Listener) createConn(n Network, netConn net.Conn) {
 		return
 	}
 	listener.playerCount.Add(1)
+	listener.pendingLogins.Add(1)
 	listener.updatePongData()
 
 	go listener.handleConn(conn)
 }
 
+// throttled checks if a new connection from the address passed should be closed because MaxPendingLogins
+// is reached or because the rate of new connections exceeds MaxConnectionRate or MaxConnectionRatePerIP.
+func (listener *Listener) throttled(addr net.Addr) bool {
+	if listener.cfg.MaxPendingLogins > 0 && listener.pendingLogins.Load() >= int32(listener.cfg.MaxPendingLogins) {
+		return true
+	}
+	return listener.connLimiter != nil && !listener.connLimiter.allow(addr)
+}
+
 // status returns the current ServerStatus of the Listener.
 func (listener *Listener) status() ServerStatus {
 	status := listener.cfg.StatusProvider.ServerStatus(int(listener.playerCount.Load()), listener.cfg.MaximumPlayers)
@@ -324,7 +582,11 @@ func (listener *Listener) status() ServerStatus {
 // handleConn handles an incoming connection of the Listener. It will first attempt to get the connection to
 // log in, after which it will expose packets received to the user.
 func (listener *Listener) handleConn(conn *Conn) {
+	pending := true
 	defer func() {
+		if pending {
+			listener.pendingLogins.Add(-1)
+		}
 		_ = conn.Close()
 		listener.playerCount.Add(-1)
 		listener.updatePongData()
@@ -332,7 +594,7 @@ func (listener *Listener) handleConn(conn *Conn) {
 	for {
 		// We finally arrived at the packet decoding loop. We constantly decode packets that arrive
 		// and push them to the Conn so that they may be processed.
-		packets, err := conn.dec.Decode()
+		packets, err := conn.readBatch()
 		if err != nil {
 			if !errors.Is(err, net.ErrClosed) {
 				conn.log.Error(err.Error())
@@ -343,9 +605,14 @@ func (listener *Listener) handleConn(conn *Conn) {
 			loggedInBefore := conn.loggedIn
 			if err := conn.receive(data); err != nil {
 				conn.log.Error(err.Error())
+				if !loggedInBefore && listener.cfg.LoginFailed != nil {
+					listener.cfg.LoginFailed(conn.RemoteAddr(), err)
+				}
 				return
 			}
 			if !loggedInBefore && conn.loggedIn {
+				pending = false
+				listener.pendingLogins.Add(-1)
 				select {
 				case <-listener.close:
 					// The listener was closed while this one was logged in, so the incoming channel will be
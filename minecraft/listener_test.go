@@ -0,0 +1,46 @@
+package minecraft_test
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sandertv/go-raknet"
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// countingStatusProvider is a minecraft.ServerStatusProvider that returns the amount of times it was called
+// as its server name.
+type countingStatusProvider struct {
+	calls atomic.Int32
+}
+
+// ServerStatus ...
+func (p *countingStatusProvider) ServerStatus(playerCount, maxPlayers int) minecraft.ServerStatus {
+	return minecraft.ServerStatus{ServerName: strconv.Itoa(int(p.calls.Add(1))), PlayerCount: playerCount, MaxPlayers: maxPlayers}
+}
+
+// TestListenerStatusPing checks that the status of a Listener is obtained from its ServerStatusProvider when
+// it is pinged, rather than only when the status is refreshed periodically.
+func TestListenerStatusPing(t *testing.T) {
+	provider := &countingStatusProvider{}
+	l, err := minecraft.ListenConfig{StatusProvider: provider, StatusRefreshRate: time.Hour}.Listen("raknet", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	ping := func() string {
+		pong, err := raknet.PingTimeout(l.Addr().String(), time.Second*5)
+		if err != nil {
+			t.Fatalf("ping: %v", err)
+		}
+		return minecraft.ParsePongData(pong).ServerName
+	}
+	first := ping()
+	time.Sleep(time.Millisecond * 150)
+	if second := ping(); second == first {
+		t.Errorf("expected status to be refreshed when pinged, got %v twice", first)
+	}
+}
@@ -1,12 +1,16 @@
 package nbt
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"go/ast"
 	"io"
 	"reflect"
-	"strings"
+	"strconv"
 	"sync"
 )
 
@@ -20,9 +24,25 @@ type Decoder struct {
 	// technically invalid, but some implementations do this to represent an
 	// empty NBT tree.
 	AllowZero bool
+	// MaxDepth is the maximum nesting depth of TAG_List and TAG_Compound tags that may be decoded. If the
+	// nesting depth exceeds MaxDepth, Decode returns a MaximumDepthReachedError. If 0, a maximum depth of
+	// 512 is used.
+	MaxDepth int
+	// PreserveOrder, when set to true, makes the Decoder decode TAG_Compounds into an OrderedMap rather than
+	// a map[string]any when decoding into a value of the type any, so that the order of the tags in the
+	// compound is preserved.
+	PreserveOrder bool
+	// MaxArrayLength is the maximum number of elements of TAG_Byte_Array, TAG_Int_Array, TAG_Long_Array and
+	// TAG_List tags that may be decoded. If a longer length is read, Decode returns an InvalidLengthError
+	// before allocating memory for the elements. If 0, the length is not limited, other than that, when
+	// decoding from a *bytes.Reader or *bytes.Buffer, a length is rejected if fewer bytes remain than its
+	// elements would require.
+	MaxArrayLength int
 
 	r     *offsetReader
 	depth int
+	// maxElements is the maximum number of tags read by Valid and elements the number of tags read so far.
+	maxElements, elements int
 }
 
 // NewDecoder returns a new Decoder for the input stream reader passed.
@@ -35,6 +55,61 @@ func NewDecoderWithEncoding(r io.Reader, encoding Encoding) *Decoder {
 	return &Decoder{Encoding: encoding, r: newOffsetReader(r)}
 }
 
+// NewDecoderAuto returns a new Decoder for the input stream reader passed, like NewDecoder, but additionally
+// detects if the data in the stream is compressed using gzip or zlib, as is commonly the case for world and
+// structure files. If so, the data is decompressed transparently. Otherwise, the data is decoded as is. The
+// Encoding of the Decoder returned may be changed to decode the data using a different encoding.
+// An error is returned if the data appeared to be compressed, but the compression header was invalid.
+func NewDecoderAuto(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	// Peeking does not consume the bytes, so uncompressed data may still be read from br in full.
+	magic, _ := br.Peek(2)
+	var src io.Reader = br
+	if len(magic) == 2 {
+		switch {
+		case magic[0] == 0x1f && magic[1] == 0x8b:
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("nbt: read gzip header: %w", err)
+			}
+			src = zr
+		case magic[0]&0x0f == 8 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0 && isZlib(br):
+			// Zlib data starts with a CMF byte indicating the deflate method, followed by a FLG byte such
+			// that CMF and FLG together form a multiple of 31. Uncompressed NBT may satisfy this too, such
+			// as a root TAG_String (0x08) with a name length of 29 (0x1d), so isZlib checks if the data
+			// following the header actually decompresses.
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("nbt: read zlib header: %w", err)
+			}
+			src = zr
+		}
+	}
+	return NewDecoder(src), nil
+}
+
+// zlibProbeSize is the maximum number of decompressed bytes read by isZlib to check if data is compressed.
+const zlibProbeSize = 1 << 16
+
+// isZlib checks if the data buffered by the bufio.Reader passed, which starts with a valid zlib header, is
+// zlib compressed, by decompressing it without consuming it. The data is considered compressed if it
+// decompresses without errors, or if it was valid up to the end of the data buffered or zlibProbeSize
+// decompressed bytes.
+func isZlib(br *bufio.Reader) bool {
+	data, _ := br.Peek(br.Size())
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		// NBT is never compressed using a preset dictionary, so ErrDictionary is not considered valid either.
+		return false
+	}
+	_, err = io.CopyN(io.Discard, zr, zlibProbeSize)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		// The data buffered was valid, but the compressed data continues past it.
+		return len(data) == br.Size()
+	}
+	return err == nil || err == io.EOF
+}
+
 // Decode reads the next NBT object from the input stream and stores it into the pointer to an object passed.
 // See the Unmarshal docs for the conversion between NBT tags to Go types.
 func (d *Decoder) Decode(v any) error {
@@ -42,6 +117,7 @@ func (d *Decoder) Decode(v any) error {
 	if val.Kind() != reflect.Ptr {
 		return NonPointerTypeError{ActualType: val.Type()}
 	}
+	d.r.maxLength = d.MaxArrayLength
 	tagType, tagName, err := d.tag()
 	if err != nil {
 		return err
@@ -69,10 +145,12 @@ func (d *Decoder) Decode(v any) error {
 //	TAG_List: []any(/any) (The value type of the slice may vary. Depending on the type of
 //	          values in the List tag, it might be of the type of any of the other tags, such as []int64.
 //
-// TAG_Compound: struct{...}/map[string]any(/any)
+// TAG_Compound: struct{...}/map[string]any/OrderedMap(/any)
 // TAG_IntArray: [...]int32(/any) (The value must be an int32 array, not a slice)
 // TAG_LongArray: [...]int64(/any) (The value must be an int64 array, not a slice)
 //
+// Any tag may additionally be decoded into a RawTag, which holds the encoded payload of the tag as is.
+//
 // Unmarshal returns an error if the data is decoded into a struct and the struct does not have all fields
 // that the matching TAG_Compound in the NBT has, in order to prevent the loss of data. For varying data, the
 // data should be decoded into a map.
@@ -80,7 +158,9 @@ func (d *Decoder) Decode(v any) error {
 //
 // Unmarshal accepts struct fields with the 'nbt' struct tag. The 'nbt' struct tag allows setting the name of
 // a field that some tag should be decoded in. Setting the struct tag to '-' means that field will never be
-// filled by the decoding of the data passed.
+// filled by the decoding of the data passed. A default value may be set for a field by adding ',default=value'
+// as the last option of the struct tag. If the TAG_Compound decoded has no tag for the field, the field is set
+// to the default value. Default values may only be set for fields of a string, bool or numeric type.
 func Unmarshal(data []byte, v any) error {
 	return UnmarshalEncoding(data, v, NetworkLittleEndian)
 }
@@ -113,6 +193,9 @@ var fieldMapPool = sync.Pool{
 // unmarshalTag decodes a tag from the decoder's input stream into the reflect.Value passed, assuming the tag
 // has the type and name passed.
 func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) error {
+	if val.Type() == rawTagType {
+		return d.unmarshalRawTag(val, t)
+	}
 	k := val.Kind()
 	switch t {
 	default:
@@ -217,6 +300,9 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 		if err != nil {
 			return err
 		}
+		if err := d.r.checkLength(int64(length), 1, "ByteArray"); err != nil {
+			return err
+		}
 		b := make([]byte, length)
 		if _, err := d.r.Read(b); err != nil {
 			return BufferOverrunError{Op: "ByteArray"}
@@ -275,7 +361,10 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 		val.Set(value)
 
 	case tagSlice:
-		d.depth++
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
 		listTypeByte, err := d.r.ReadByte()
 		if err != nil {
 			return BufferOverrunError{Op: "Slice"}
@@ -302,6 +391,9 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 				val.Set(reflect.MakeSlice(sliceType, int(length), int(length)))
 				break
 			}
+			if err := d.r.checkLength(int64(length), 1, "ByteSlice"); err != nil {
+				return err
+			}
 			b := make([]byte, length)
 			if _, err := d.r.Read(b); err != nil {
 				return BufferOverrunError{Op: "ByteSlice"}
@@ -315,7 +407,7 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 		case tagInt32:
 			b, err := d.Encoding.Int32Slice(d.r)
 			if err != nil {
-				return BufferOverrunError{Op: "Int32Slice"}
+				return err
 			}
 			switch {
 			case k == reflect.Slice && val.Type().Elem().Kind() == reflect.Int32, isAny(val):
@@ -326,7 +418,7 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 		case tagInt64:
 			b, err := d.Encoding.Int64Slice(d.r)
 			if err != nil {
-				return BufferOverrunError{Op: "Int64Slice"}
+				return err
 			}
 			switch {
 			case k == reflect.Slice && val.Type().Elem().Kind() == reflect.Int64, isAny(val):
@@ -339,6 +431,10 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 			if err != nil {
 				return err
 			}
+			// Every element of a TAG_List is at least one byte long, which is used to validate the length.
+			if err := d.r.checkLength(int64(length), 1, "Slice"); err != nil {
+				return err
+			}
 			v := reflect.MakeSlice(sliceType, int(length), int(length))
 			for i := 0; i < int(length); i++ {
 				if err := d.unmarshalTag(v.Index(i), listType, ""); err != nil {
@@ -351,11 +447,16 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 				}
 			}
 			val.Set(v)
-			d.depth--
 		}
 
 	case tagStruct:
-		d.depth++
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		if val.Type() == orderedMapType || (isAny(val) && d.PreserveOrder) {
+			return d.unmarshalOrderedMap(val)
+		}
 		switch val.Kind() {
 		default:
 			return InvalidTypeError{Off: d.r.off, FieldType: val.Type(), Field: tagName, TagType: t}
@@ -364,6 +465,13 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 			// from when they were used, meaning we don't have to re-allocate each element.
 			fields := fieldMapPool.Get().(map[string]reflect.Value)
 			d.populateFields(val, fields)
+
+			// If any of the fields of the struct has a default value, we keep track of the tags present in
+			// the compound, so that all other fields may be set to their default value.
+			var present map[string]struct{}
+			if hasDefaults(val.Type()) {
+				present = make(map[string]struct{}, len(fields))
+			}
 			for {
 				nestedTagType, nestedTagName, err := d.tag()
 				if err != nil {
@@ -381,12 +489,20 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 					if err = d.unmarshalTag(field, nestedTagType, nestedTagName); err != nil {
 						return err
 					}
+					if present != nil {
+						present[nestedTagName] = struct{}{}
+					}
 					continue
 				}
 				// We return an error if the struct does not have one of the fields found in the compound. It
 				// is rather important no data is lost during the decoding.
 				return UnexpectedNamedTagError{Off: d.r.off, TagName: tagName + "." + nestedTagName, TagType: nestedTagType}
 			}
+			if present != nil {
+				if err := setDefaults(val, present); err != nil {
+					return err
+				}
+			}
 			// Finally we delete all fields in the map and return it to the sync.Pool so that it may be
 			// re-used by the next operation.
 			for k := range fields {
@@ -422,11 +538,54 @@ func (d *Decoder) unmarshalTag(val reflect.Value, t tagType, tagName string) err
 			}
 			val.Set(m)
 		}
-		d.depth--
 	}
 	return nil
 }
 
+// unmarshalOrderedMap decodes the tags of a TAG_Compound into an OrderedMap and sets it to the value passed.
+func (d *Decoder) unmarshalOrderedMap(val reflect.Value) error {
+	var m OrderedMap
+	for {
+		nestedTagType, nestedTagName, err := d.tag()
+		if err != nil {
+			return err
+		}
+		if !nestedTagType.IsValid() {
+			return UnknownTagError{Off: d.r.off, Op: "OrderedMap", TagType: nestedTagType}
+		}
+		if nestedTagType == tagEnd {
+			// We reached the end of the compound.
+			break
+		}
+		var value any
+		if err := d.unmarshalTag(reflect.ValueOf(&value).Elem(), nestedTagType, nestedTagName); err != nil {
+			return err
+		}
+		m.Set(nestedTagName, value)
+	}
+	val.Set(reflect.ValueOf(m))
+	return nil
+}
+
+// enter increments the nesting depth of the Decoder when decoding a TAG_List or TAG_Compound. An error is
+// returned if the depth exceeds the maximum depth of the Decoder.
+func (d *Decoder) enter() error {
+	maxDepth := d.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = maximumNestingDepth
+	}
+	if d.depth >= maxDepth {
+		return MaximumDepthReachedError{Depth: maxDepth}
+	}
+	d.depth++
+	return nil
+}
+
+// leave decrements the nesting depth of the Decoder after decoding a TAG_List or TAG_Compound.
+func (d *Decoder) leave() {
+	d.depth--
+}
+
 // populateFields populates the map passed with the fields of the reflect representation of a struct passed.
 // It takes into consideration the nbt struct field tag.
 func (d *Decoder) populateFields(val reflect.Value, m map[string]reflect.Value) {
@@ -447,20 +606,112 @@ func (d *Decoder) populateFields(val reflect.Value, m map[string]reflect.Value)
 			if tag == "-" {
 				continue
 			}
-			tag = strings.TrimSuffix(tag, ",omitempty")
-			if tag != "" {
-				name = tag
+			if opts := parseStructTag(tag); opts.name != "" {
+				name = opts.name
 			}
 		}
 		m[name] = field
 	}
 }
 
+// defaultsCache holds, per struct type, whether any of its fields has a default value set in its struct tag.
+var defaultsCache sync.Map
+
+// hasDefaults checks if any of the fields of the struct type passed, including those of embedded structs,
+// has a default value set using the 'default' option of the 'nbt' struct tag.
+func hasDefaults(t reflect.Type) bool {
+	if v, ok := defaultsCache.Load(t); ok {
+		return v.(bool)
+	}
+	found := false
+	for i := 0; i < t.NumField() && !found; i++ {
+		fieldType := t.Field(i)
+		if !ast.IsExported(fieldType.Name) {
+			continue
+		}
+		if fieldType.Anonymous {
+			found = fieldType.Type.Kind() == reflect.Struct && hasDefaults(fieldType.Type)
+			continue
+		}
+		found = parseStructTag(fieldType.Tag.Get("nbt")).hasDefault
+	}
+	defaultsCache.Store(t, found)
+	return found
+}
+
+// setDefaults sets all fields of the struct value passed that have a default value, but of which no tag was
+// present in the TAG_Compound decoded, to their default value. The names of the tags that were present are
+// held in the map passed.
+func setDefaults(val reflect.Value, present map[string]struct{}) error {
+	for i := 0; i < val.NumField(); i++ {
+		fieldType := val.Type().Field(i)
+		if !ast.IsExported(fieldType.Name) {
+			continue
+		}
+		field := val.Field(i)
+		if fieldType.Anonymous && field.Kind() == reflect.Struct {
+			if err := setDefaults(field, present); err != nil {
+				return err
+			}
+			continue
+		}
+		tag, _ := fieldType.Tag.Lookup("nbt")
+		if tag == "-" {
+			continue
+		}
+		opts := parseStructTag(tag)
+		name := fieldType.Name
+		if opts.name != "" {
+			name = opts.name
+		}
+		if _, ok := present[name]; ok || !opts.hasDefault {
+			continue
+		}
+		if err := setDefault(field, opts.def); err != nil {
+			return InvalidDefaultValueError{Field: name, Value: opts.def, FieldType: field.Type()}
+		}
+	}
+	return nil
+}
+
+// setDefault parses the default value passed into a value of the type of the reflect.Value passed and sets
+// it. Only strings, booleans and numeric types may have a default value.
+func setDefault(val reflect.Value, def string) error {
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(def)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		val.SetBool(v)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(def, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(v)
+	case reflect.Uint8:
+		v, err := strconv.ParseUint(def, 10, 8)
+		if err != nil {
+			return err
+		}
+		val.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(def, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported type %v", val.Type())
+	}
+	return nil
+}
+
 // tag reads a tag from the decoder, and its name if the tag type is not a TAG_End.
 func (d *Decoder) tag() (t tagType, tagName string, err error) {
-	if d.depth >= maximumNestingDepth {
-		return 0, "", MaximumDepthReachedError{}
-	}
 	if d.r.off >= maximumNetworkOffset && d.Encoding == NetworkLittleEndian {
 		return 0, "", MaximumBytesReadError{}
 	}
@@ -0,0 +1,119 @@
+package nbt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewDecoderAuto(t *testing.T) {
+	want := map[string]any{"name": "test", "value": int32(7)}
+	data, err := Marshal(want)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	gz, zl := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(gz)
+	_, _ = gw.Write(data)
+	_ = gw.Close()
+	zw := zlib.NewWriter(zl)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+
+	for name, b := range map[string][]byte{"uncompressed": data, "gzip": gz.Bytes(), "zlib": zl.Bytes()} {
+		dec, err := NewDecoderAuto(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%v: new decoder: %v", name, err)
+		}
+		var got map[string]any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("%v: decode: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: expected %v, got %v", name, want, got)
+		}
+	}
+}
+
+// TestNewDecoderAutoZlibLike checks that uncompressed NBT starting with bytes that form a valid zlib header is
+// decoded as is: A root TAG_String (0x08) with a name of 29 bytes (0x1d) is a multiple of 31.
+func TestNewDecoderAutoZlibLike(t *testing.T) {
+	name, value := strings.Repeat("n", 0x1d), "value"
+	data := append([]byte{byte(tagString), byte(len(name))}, name...)
+	data = append(append(data, byte(len(value))), value...)
+
+	dec, err := NewDecoderAuto(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("new decoder: %v", err)
+	}
+	var got string
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got != value {
+		t.Errorf("expected %q, got %q", value, got)
+	}
+}
+
+// nested returns a value of depth TAG_Lists or TAG_Compounds nested in each other, built by wrapping the
+// innermost value passed using the function passed.
+func nested(depth int, innermost any, wrap func(v any, i int) any) any {
+	v := innermost
+	for i := 1; i < depth; i++ {
+		v = wrap(v, i)
+	}
+	return v
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	const maxDepth = 4
+	tests := map[string]func(depth int) any{
+		"compounds": func(depth int) any {
+			return nested(depth, map[string]any{}, func(v any, _ int) any { return map[string]any{"v": v} })
+		},
+		"lists": func(depth int) any {
+			return nested(depth, []any{}, func(v any, _ int) any { return []any{v} })
+		},
+		"lists and compounds": func(depth int) any {
+			return nested(depth, map[string]any{}, func(v any, i int) any {
+				if i%2 == 0 {
+					return map[string]any{"v": v}
+				}
+				return []any{v}
+			})
+		},
+		// Arrays do not count towards the depth, but the lists holding them must.
+		"lists of arrays": func(depth int) any {
+			return nested(depth+1, [2]int32{1, 2}, func(v any, _ int) any { return []any{v} })
+		},
+	}
+	for name, value := range tests {
+		for depth := 1; depth <= maxDepth+1; depth++ {
+			data, err := Marshal(value(depth))
+			if err != nil {
+				t.Fatalf("%v: marshal depth %v: %v", name, depth, err)
+			}
+			dec := NewDecoder(bytes.NewReader(data))
+			dec.MaxDepth = maxDepth
+			var v any
+			err = dec.Decode(&v)
+			if depth <= maxDepth {
+				if err != nil {
+					t.Errorf("%v: expected depth %v to be decoded, got %v", name, depth, err)
+				}
+				continue
+			}
+			var depthErr MaximumDepthReachedError
+			if !errors.As(err, &depthErr) || depthErr.Depth != maxDepth {
+				t.Errorf("%v: expected MaximumDepthReachedError{Depth: %v} for depth %v, got %v", name, maxDepth, depth, err)
+			}
+			if err := Valid(data, NetworkLittleEndian, ValidMaxDepth(maxDepth)); !errors.As(err, &depthErr) {
+				t.Errorf("%v: expected Valid to return MaximumDepthReachedError for depth %v, got %v", name, depth, err)
+			}
+		}
+	}
+}
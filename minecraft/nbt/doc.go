@@ -27,8 +27,11 @@
 // The 'nbt' struct tag may be filled out the following ways:
 //
 //	'-': Ignores the field completely when encoding and decoding.
-//	',omitempty': Doesn't encode the field if its value is the same as the default value.
+//	',omitempty': Doesn't encode the field if its value is empty: Slices, maps and strings with a length of 0
+//	               and other values equal to their zero value.
 //	'name(,omitempty)': Encodes/decodes the field with a different name than its usual name.
+//	'name,default=value': Decodes the value passed into the field if the field is absent in the NBT decoded.
+//	                      The default option must be the last option of the struct tag.
 //
 // If no 'nbt' struct tag is present for a field, the name of the field will be used to encode/decode the
 // struct. Note that this package, unlike the JSON standard library package, is case sensitive when decoding.
@@ -5,7 +5,6 @@ import (
 	"io"
 	"math"
 	"reflect"
-	"strings"
 	"sync"
 )
 
@@ -70,11 +69,15 @@ func (e *Encoder) Encode(v any) error {
 //	[]<type>: TAG_List
 //	struct{...}: TAG_Compound
 //	map[string]<type/any>: TAG_Compound
+//	OrderedMap: TAG_Compound
+//	RawTag: The type of the tag held
 //
 // Marshal accepts struct fields with the 'nbt' struct tag. The 'nbt' struct tag allows setting the name of
 // a field that some tag should be decoded in. Setting the struct tag to '-' means that field will never be
 // filled by the decoding of the data passed. Suffixing the 'nbt' struct tag with ',omitempty' will prevent
-// the field from being encoded if it is equal to its default value.
+// the field from being encoded if it is empty: Slices, maps and strings are empty if their length is 0, and
+// other values are empty if they are equal to their zero value. A struct field that is not empty is always
+// written, even if all of its own fields are omitted.
 func Marshal(v any) ([]byte, error) {
 	return MarshalEncoding(v, NetworkLittleEndian)
 }
@@ -110,6 +113,9 @@ func (e *Encoder) marshal(val reflect.Value, tagName string) error {
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
+	if val.Type() == rawTagType {
+		return e.marshalRawTag(val.Interface().(RawTag), tagName)
+	}
 	tagType := tagFromType(val.Type())
 	if tagType == math.MaxUint8 {
 		return IncompatibleTypeError{Type: val.Type(), ValueName: tagName}
@@ -230,6 +236,15 @@ func (e *Encoder) encode(val reflect.Value, tagName string) error {
 
 	case reflect.Struct:
 		e.depth++
+		if val.Type() == orderedMapType {
+			for key, value := range val.Interface().(OrderedMap).All() {
+				if err := e.marshal(reflect.ValueOf(value), key); err != nil {
+					return err
+				}
+			}
+			e.depth--
+			return e.w.WriteByte(byte(tagEnd))
+		}
 		if err := e.writeStructValues(val); err != nil {
 			return err
 		}
@@ -273,16 +288,14 @@ func (e *Encoder) writeStructValues(val reflect.Value) error {
 			continue
 		}
 		tagName := fieldType.Name
-		if strings.HasSuffix(tag, ",omitempty") {
-			tag = strings.TrimSuffix(tag, ",omitempty")
-			if reflect.DeepEqual(fieldValue.Interface(), reflect.Zero(fieldValue.Type()).Interface()) {
-				// The tag had the ',omitempty' tag, meaning it should be omitted if it has the zero
-				// value. If this is reached, that was the case, and we skip it.
-				continue
-			}
+		opts := parseStructTag(tag)
+		if opts.omitEmpty && isEmptyValue(fieldValue) {
+			// The tag had the ',omitempty' tag, meaning it should be omitted if it has an empty value. If
+			// this is reached, that was the case, and we skip it.
+			continue
 		}
-		if tag != "" {
-			tagName = tag
+		if opts.name != "" {
+			tagName = opts.name
 		}
 		if err := e.marshal(fieldValue, tagName); err != nil {
 			return err
@@ -294,7 +307,7 @@ func (e *Encoder) writeStructValues(val reflect.Value) error {
 // writeTag writes a single tag to the io.Writer held by the Encoder. The tag type and the name are written.
 func (e *Encoder) writeTag(t tagType, tagName string) error {
 	if e.depth >= maximumNestingDepth {
-		return MaximumDepthReachedError{}
+		return MaximumDepthReachedError{Depth: maximumNestingDepth}
 	}
 	if err := e.w.WriteByte(byte(t)); err != nil {
 		return err
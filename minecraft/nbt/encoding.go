@@ -42,7 +42,9 @@ var (
 	NetworkBigEndian networkBigEndian
 
 	// BigEndian is the fixed size big endian implementation of NBT. It is the original implementation, and is
-	// used only on Minecraft Java Edition.
+	// used only on Minecraft Java Edition. BigEndian may be passed to NewDecoderWithEncoding or
+	// NewEncoderWithEncoding to read or write Java Edition files such as structure (.nbt) or level (.dat)
+	// files. These files are typically compressed using gzip, which must be handled by the caller.
 	BigEndian bigEndian
 
 	_ Encoding = NetworkLittleEndian
@@ -191,6 +193,10 @@ func (e networkLittleEndian) Int32Slice(r *offsetReader) ([]int32, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
 	}
+	// Every varint is at least one byte long, which is used to validate the length.
+	if err := r.checkLength(int64(n), 1, "Int32Slice"); err != nil {
+		return nil, err
+	}
 	m := make([]int32, n)
 	for i := int32(0); i < n; i++ {
 		m[i], err = e.Int32(r)
@@ -207,6 +213,9 @@ func (e networkLittleEndian) Int64Slice(r *offsetReader) ([]int64, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
 	}
+	if err := r.checkLength(int64(n), 1, "Int64Slice"); err != nil {
+		return nil, err
+	}
 	m := make([]int64, n)
 	for i := int32(0); i < n; i++ {
 		m[i], err = e.Int64(r)
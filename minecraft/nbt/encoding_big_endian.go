@@ -135,6 +135,9 @@ func (e bigEndian) Int32Slice(r *offsetReader) ([]int32, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
 	}
+	if err := r.checkLength(int64(n), 4, "Int32Slice"); err != nil {
+		return nil, err
+	}
 	b := make([]byte, n*4)
 	if _, err := r.Read(b); err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
@@ -151,6 +154,9 @@ func (e bigEndian) Int64Slice(r *offsetReader) ([]int64, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
 	}
+	if err := r.checkLength(int64(n), 8, "Int64Slice"); err != nil {
+		return nil, err
+	}
 	b := make([]byte, n*8)
 	if _, err := r.Read(b); err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
@@ -293,6 +299,9 @@ func (e littleEndian) Int32Slice(r *offsetReader) ([]int32, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
 	}
+	if err := r.checkLength(int64(n), 4, "Int32Slice"); err != nil {
+		return nil, err
+	}
 	b := make([]byte, n*4)
 	if _, err := r.Read(b); err != nil {
 		return nil, BufferOverrunError{Op: "Int32Slice"}
@@ -315,6 +324,9 @@ func (e littleEndian) Int64Slice(r *offsetReader) ([]int64, error) {
 	if err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
 	}
+	if err := r.checkLength(int64(n), 8, "Int64Slice"); err != nil {
+		return nil, err
+	}
 	b := make([]byte, n*8)
 	if _, err := r.Read(b); err != nil {
 		return nil, BufferOverrunError{Op: "Int64Slice"}
@@ -0,0 +1,106 @@
+package nbt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+// javaHelloWorld is the test.nbt sample file of the Java Edition NBT specification, uncompressed: A
+// TAG_Compound named 'hello world' holding a TAG_String named 'name' with the value 'Bananrama'.
+var javaHelloWorld = []byte{
+	0x0a, 0x00, 0x0b, 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd',
+	0x08, 0x00, 0x04, 'n', 'a', 'm', 'e', 0x00, 0x09, 'B', 'a', 'n', 'a', 'n', 'r', 'a', 'm', 'a',
+	0x00,
+}
+
+func TestBigEndianJavaSample(t *testing.T) {
+	gz := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(gz)
+	_, _ = w.Write(javaHelloWorld)
+	_ = w.Close()
+
+	want := map[string]any{"name": "Bananrama"}
+	for name, data := range map[string][]byte{"uncompressed": javaHelloWorld, "gzip": gz.Bytes()} {
+		dec, err := NewDecoderAuto(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%v: new decoder: %v", name, err)
+		}
+		dec.Encoding = BigEndian
+		var got map[string]any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("%v: decode: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: expected %v, got %v", name, want, got)
+		}
+	}
+
+	// The name of the root tag is not written by the Encoder, so the data is the same as the sample apart
+	// from the name.
+	data, err := MarshalEncoding(want, BigEndian)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	wantData := append([]byte{0x0a, 0x00, 0x00}, javaHelloWorld[14:]...)
+	if !bytes.Equal(data, wantData) {
+		t.Errorf("expected %x, got %x", wantData, data)
+	}
+}
+
+func TestBigEndianBytes(t *testing.T) {
+	tests := []struct {
+		v    any
+		want []byte
+	}{
+		{v: int16(0x0102), want: []byte{0x02, 0x00, 0x00, 0x01, 0x02}},
+		{v: int32(0x01020304), want: []byte{0x03, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04}},
+		{v: int64(-2), want: []byte{0x04, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}},
+		{v: float32(1), want: []byte{0x05, 0x00, 0x00, 0x3f, 0x80, 0x00, 0x00}},
+		{v: float64(-2), want: []byte{0x06, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{v: "ab", want: []byte{0x08, 0x00, 0x00, 0x00, 0x02, 'a', 'b'}},
+		{v: [2]int32{1, -1}, want: []byte{0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff}},
+		{v: []int16{1}, want: []byte{0x09, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01}},
+	}
+	for _, test := range tests {
+		data, err := MarshalEncoding(test.v, BigEndian)
+		if err != nil {
+			t.Fatalf("marshal %v: %v", test.v, err)
+		}
+		if !bytes.Equal(data, test.want) {
+			t.Errorf("%T: expected %x, got %x", test.v, test.want, data)
+		}
+	}
+}
+
+func TestBigEndianRoundTrip(t *testing.T) {
+	want := map[string]any{
+		"byte":      byte(0xfe),
+		"short":     int16(-300),
+		"int":       int32(-70000),
+		"long":      int64(1 << 40),
+		"float":     float32(0.5),
+		"double":    float64(-1.25),
+		"string":    "Bananrama ✓",
+		"byteArray": [3]byte{1, 2, 3},
+		"intArray":  [2]int32{-1, 1 << 20},
+		"longArray": [1]int64{-1 << 40},
+		"list":      []int32{1, 2},
+		"compounds": []any{map[string]any{"a": byte(1)}, map[string]any{"b": "c"}},
+		"compound":  map[string]any{"nested": map[string]any{"empty": map[string]any{}}},
+	}
+	for _, encoding := range []Encoding{BigEndian, NetworkBigEndian} {
+		data, err := MarshalEncoding(want, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", encoding, err)
+		}
+		var got map[string]any
+		if err := UnmarshalEncoding(data, &got, encoding); err != nil {
+			t.Fatalf("%T: unmarshal: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: expected %v, got %v", encoding, want, got)
+		}
+	}
+}
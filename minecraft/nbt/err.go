@@ -80,6 +80,23 @@ func (err InvalidArraySizeError) Error() string {
 	return fmt.Sprintf("nbt: mismatched array size at %v during op '%v': expected size %v, found %v in NBT", err.Off, err.Op, err.GoLength, err.NBTLength)
 }
 
+// InvalidLengthError is returned when the length of an array or list read from the NBT is negative or
+// exceeds the maximum length set using Decoder.MaxArrayLength.
+type InvalidLengthError struct {
+	Off int64
+	Op  string
+	N   int64
+	Max int
+}
+
+// Error ...
+func (err InvalidLengthError) Error() string {
+	if err.N < 0 {
+		return fmt.Sprintf("nbt: invalid length at offset %v during op '%v': length %v is negative", err.Off, err.Op, err.N)
+	}
+	return fmt.Sprintf("nbt: invalid length at offset %v during op '%v': length %v exceeds maximum of %v", err.Off, err.Op, err.N, err.Max)
+}
+
 // UnexpectedNamedTagError is returned when a named tag is read from a compound which is not present in the
 // struct it is decoded into.
 type UnexpectedNamedTagError struct {
@@ -135,14 +152,28 @@ func (err InvalidStringError) Error() string {
 
 const maximumNestingDepth = 512
 
-// MaximumDepthReachedError is returned if the maximum depth of 512 compound/list tags has been reached while
-// reading or writing NBT.
+// MaximumDepthReachedError is returned if the maximum depth of compound/list tags has been reached while
+// reading or writing NBT. By default, this maximum depth is 512.
 type MaximumDepthReachedError struct {
+	// Depth is the maximum depth that was reached.
+	Depth int
 }
 
 // Error ...
 func (err MaximumDepthReachedError) Error() string {
-	return fmt.Sprintf("nbt: maximum nesting depth of %v was reached", maximumNestingDepth)
+	return fmt.Sprintf("nbt: maximum nesting depth of %v was reached", err.Depth)
+}
+
+// MaximumElementsReachedError is returned by Valid if the NBT checked holds more tags than the maximum set
+// using ValidMaxElements.
+type MaximumElementsReachedError struct {
+	// Elements is the maximum number of tags that was reached.
+	Elements int
+}
+
+// Error ...
+func (err MaximumElementsReachedError) Error() string {
+	return fmt.Sprintf("nbt: maximum number of %v tags was reached", err.Elements)
 }
 
 const maximumNetworkOffset = 4 * 1024 * 1024
@@ -168,3 +199,27 @@ type InvalidVarintError struct {
 func (err InvalidVarintError) Error() string {
 	return fmt.Sprintf("nbt: varint did not terminate after %v bytes at offset %v", err.N, err.Off)
 }
+
+// InvalidDefaultValueError is returned when the default value set in the 'nbt' struct tag of a field could
+// not be parsed into a value of the type of the field.
+type InvalidDefaultValueError struct {
+	Field     string
+	Value     string
+	FieldType reflect.Type
+}
+
+// Error ...
+func (err InvalidDefaultValueError) Error() string {
+	return fmt.Sprintf("nbt: invalid default value %q for field '%v': cannot parse into %v", err.Value, err.Field, err.FieldType)
+}
+
+// RawTagEncodingError is returned when a RawTag is encoded using a different Encoding than the one that its
+// data is encoded in.
+type RawTagEncodingError struct {
+	ValueName string
+}
+
+// Error ...
+func (err RawTagEncodingError) Error() string {
+	return fmt.Sprintf("nbt: raw tag (%v) cannot be encoded using a different encoding than it was decoded with", err.ValueName)
+}
@@ -0,0 +1,126 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fuzzSeeds returns NBT encoded using NetworkLittleEndian that is used as seed corpus by the fuzz tests. It
+// holds valid NBT, truncated NBT, deeply nested NBT and NBT with huge lengths.
+func fuzzSeeds(t testing.TB) [][]byte {
+	valid, err := Marshal(map[string]any{
+		"byte":   byte(1),
+		"string": "value",
+		"list":   []any{int32(1), int32(2)},
+		"nested": map[string]any{"long": int64(-5), "bytes": [3]byte{1, 2, 3}, "ints": [2]int32{4, 5}},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	seeds := [][]byte{valid, nil, {byte(tagStruct)}, {byte(tagStruct), 0}}
+	for _, n := range []int{1, len(valid) / 2, len(valid) - 1} {
+		seeds = append(seeds, valid[:n])
+	}
+	return append(seeds,
+		nestedLists(maximumNestingDepth+1),
+		nestedCompounds(maximumNestingDepth+1),
+		// A TAG_String with a length of 2^32-1.
+		[]byte{byte(tagString), 0, 0xff, 0xff, 0xff, 0xff, 0x0f},
+		// A TAG_List of TAG_Longs with a length of 2^31-1.
+		[]byte{byte(tagSlice), 0, byte(tagInt64), 0xfe, 0xff, 0xff, 0xff, 0x0f},
+		// A TAG_Int_Array with a length of 2^31-1.
+		[]byte{byte(tagInt32Array), 0, 0xfe, 0xff, 0xff, 0xff, 0x0f},
+		// A TAG_Byte_Array with a negative length.
+		[]byte{byte(tagByteArray), 0, 0x01},
+	)
+}
+
+// nestedLists returns a TAG_List holding a TAG_List depth times, with the innermost one empty.
+func nestedLists(depth int) []byte {
+	data := []byte{byte(tagSlice), 0}
+	for i := 1; i < depth; i++ {
+		// A list of one TAG_List.
+		data = append(data, byte(tagSlice), 2)
+	}
+	return append(data, byte(tagEnd), 0)
+}
+
+// nestedCompounds returns a TAG_Compound holding a TAG_Compound depth times, with the innermost one empty.
+func nestedCompounds(depth int) []byte {
+	data := []byte{byte(tagStruct), 0}
+	for i := 1; i < depth; i++ {
+		data = append(data, byte(tagStruct), 0)
+	}
+	return append(data, bytes.Repeat([]byte{byte(tagEnd)}, depth)...)
+}
+
+func FuzzDecoder(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v any
+		if err := Unmarshal(data, &v); err != nil {
+			return
+		}
+		if _, err := Marshal(v); err != nil {
+			t.Errorf("decoded %x into %v, which failed to encode: %v", data, v, err)
+		}
+	})
+}
+
+func FuzzValid(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := Valid(data, NetworkLittleEndian); err != nil {
+			return
+		}
+		// Any NBT considered valid must also be decoded successfully.
+		var v any
+		if err := Unmarshal(data, &v); err != nil {
+			t.Errorf("Valid accepted %x, but Unmarshal failed: %v", data, err)
+		}
+	})
+}
+
+// TestDecodeLimits checks that NBT that is too deeply nested or that holds lengths exceeding the data is
+// rejected by both Unmarshal and Valid.
+func TestDecodeLimits(t *testing.T) {
+	tests := map[string]struct {
+		data []byte
+		err  any
+	}{
+		"nested lists":     {data: nestedLists(maximumNestingDepth + 1), err: &MaximumDepthReachedError{}},
+		"nested compounds": {data: nestedCompounds(maximumNestingDepth + 1), err: &MaximumDepthReachedError{}},
+		"string length":    {data: []byte{byte(tagString), 0, 0xff, 0xff, 0xff, 0xff, 0x0f}},
+		"list length":      {data: []byte{byte(tagSlice), 0, byte(tagInt64), 0xfe, 0xff, 0xff, 0xff, 0x0f}, err: &BufferOverrunError{}},
+		"int array length": {data: []byte{byte(tagInt32Array), 0, 0xfe, 0xff, 0xff, 0xff, 0x0f}, err: &BufferOverrunError{}},
+		"negative length":  {data: []byte{byte(tagByteArray), 0, 0x01}, err: &InvalidLengthError{}},
+	}
+	for name, test := range tests {
+		var v any
+		for op, err := range map[string]error{"unmarshal": Unmarshal(test.data, &v), "valid": Valid(test.data, NetworkLittleEndian)} {
+			if err == nil {
+				t.Errorf("%v: expected %v error", name, op)
+				continue
+			}
+			if test.err != nil && !errors.As(err, test.err) {
+				t.Errorf("%v: expected %v error of type %T, got %v", name, op, test.err, err)
+			}
+		}
+	}
+
+	// Nesting up to the maximum depth is allowed.
+	for _, data := range [][]byte{nestedLists(maximumNestingDepth), nestedCompounds(maximumNestingDepth)} {
+		var v any
+		if err := Unmarshal(data, &v); err != nil {
+			t.Errorf("unmarshal: expected nesting of maximum depth to be decoded, got %v", err)
+		}
+		if err := Valid(data, NetworkLittleEndian); err != nil {
+			t.Errorf("valid: expected nesting of maximum depth to be valid, got %v", err)
+		}
+	}
+}
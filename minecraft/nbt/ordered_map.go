@@ -0,0 +1,72 @@
+package nbt
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// OrderedMap is a TAG_Compound that, unlike a map[string]any, preserves the order of its tags. Tags of an
+// OrderedMap are encoded in the order in which they were first set, so that NBT decoded into an OrderedMap
+// is encoded again with the exact same order of tags.
+// An OrderedMap is always decoded into if it is the type of the value decoded. Additionally, a Decoder with
+// PreserveOrder set to true decodes TAG_Compounds into an OrderedMap instead of a map[string]any if the value
+// decoded into is of the type any.
+// The zero value of an OrderedMap is an empty map ready to use. An OrderedMap should not be copied after it
+// is modified.
+type OrderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+// orderedMapType is the reflect.Type of an OrderedMap.
+var orderedMapType = reflect.TypeFor[OrderedMap]()
+
+// Set sets the value of the tag with the key passed. If the OrderedMap did not yet have a tag with the key,
+// it is added after all other tags. Otherwise, the value is replaced and the tag keeps its position.
+func (m *OrderedMap) Set(key string, value any) {
+	if m.values == nil {
+		m.values = make(map[string]any)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns the value of the tag with the key passed and true, or nil and false if the OrderedMap has no
+// tag with the key.
+func (m OrderedMap) Get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Delete removes the tag with the key passed from the OrderedMap, if present.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	m.keys = slices.Delete(m.keys, slices.Index(m.keys, key), slices.Index(m.keys, key)+1)
+}
+
+// Len returns the number of tags in the OrderedMap.
+func (m OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of all tags in the OrderedMap, in order.
+func (m OrderedMap) Keys() []string {
+	return slices.Clone(m.keys)
+}
+
+// All returns an iterator over the keys and values of all tags in the OrderedMap, in order.
+func (m OrderedMap) All() iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for _, key := range m.keys {
+			if !yield(key, m.values[key]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package nbt
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// orderedMap returns an OrderedMap with the keys and values passed, set in order.
+func orderedMap(kv ...any) OrderedMap {
+	var m OrderedMap
+	for i := 0; i < len(kv); i += 2 {
+		m.Set(kv[i].(string), kv[i+1])
+	}
+	return m
+}
+
+func TestOrderedMapPreserveOrder(t *testing.T) {
+	want := orderedMap(
+		"z", int32(1),
+		"a", orderedMap("y", "y", "b", orderedMap("x", byte(1), "c", byte(2))),
+		"m", []any{orderedMap("q", int16(1), "e", int16(2)), orderedMap("w", int16(3), "d", int16(4))},
+		"b", []int32{1, 2},
+	)
+	for _, encoding := range []Encoding{NetworkLittleEndian, LittleEndian, BigEndian} {
+		data, err := MarshalEncoding(want, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", encoding, err)
+		}
+		dec := NewDecoderWithEncoding(bytes.NewReader(data), encoding)
+		dec.PreserveOrder = true
+		var got any
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("%T: decode: %v", encoding, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%T: expected %v, got %v", encoding, want, got)
+		}
+		m := got.(OrderedMap)
+		a, _ := m.Get("a")
+		b, _ := a.(OrderedMap).Get("b")
+		list, _ := m.Get("m")
+		m0 := list.([]any)[0].(OrderedMap)
+		for _, keys := range [][2][]string{
+			{m.Keys(), {"z", "a", "m", "b"}},
+			{a.(OrderedMap).Keys(), {"y", "b"}},
+			{b.(OrderedMap).Keys(), {"x", "c"}},
+			{m0.Keys(), {"q", "e"}},
+		} {
+			if !slices.Equal(keys[0], keys[1]) {
+				t.Errorf("%T: expected keys %v, got %v", encoding, keys[1], keys[0])
+			}
+		}
+
+		// Encoding the NBT decoded must produce exactly the same data.
+		again, err := MarshalEncoding(got, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal again: %v", encoding, err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("%T: expected %x after encoding again, got %x", encoding, data, again)
+		}
+	}
+}
+
+func TestOrderedMapWithoutPreserveOrder(t *testing.T) {
+	data, err := Marshal(orderedMap("b", orderedMap("d", byte(1), "c", byte(2)), "a", byte(3)))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	// The root compound is decoded into an OrderedMap because it is the type decoded into, but nested
+	// compounds are decoded into a map[string]any without PreserveOrder.
+	var m OrderedMap
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if keys := m.Keys(); !slices.Equal(keys, []string{"b", "a"}) {
+		t.Errorf("expected keys [b a], got %v", keys)
+	}
+	if b, _ := m.Get("b"); !reflect.DeepEqual(b, map[string]any{"c": byte(2), "d": byte(1)}) {
+		t.Errorf("expected nested compound to be decoded into a map[string]any, got %#v", b)
+	}
+	var v any
+	if err := Unmarshal(data, &v); err != nil {
+		t.Fatalf("unmarshal into any: %v", err)
+	}
+	if _, ok := v.(map[string]any); !ok {
+		t.Errorf("expected map[string]any without PreserveOrder, got %T", v)
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	var m OrderedMap
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("c", 3)
+	m.Set("a", 4)
+	if keys := m.Keys(); !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("expected replaced key to keep its position, got %v", keys)
+	}
+	if v, ok := m.Get("a"); !ok || v != 4 {
+		t.Errorf("expected 4, got %v (%v)", v, ok)
+	}
+	m.Delete("b")
+	m.Delete("missing")
+	if keys := m.Keys(); !slices.Equal(keys, []string{"a", "c"}) || m.Len() != 2 {
+		t.Errorf("expected keys [a c] after delete, got %v", keys)
+	}
+	if _, ok := m.Get("b"); ok {
+		t.Error("expected deleted key to be absent")
+	}
+	var keys []string
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	if !slices.Equal(keys, []string{"a", "c"}) {
+		t.Errorf("expected All to yield keys [a c], got %v", keys)
+	}
+}
@@ -0,0 +1,119 @@
+package nbt
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+)
+
+// RawTag holds the encoded payload of a tag exactly as it was decoded, similar to json.RawMessage. A value of
+// the type RawTag, such as a struct field or the value of a map[string]RawTag, may hold a tag of any type.
+// When decoding into a RawTag, the payload of the tag is not decoded, but its bytes are copied into the
+// RawTag. When encoding a RawTag, the bytes are written as they are. This allows editing a single tag of a
+// TAG_Compound while leaving all other tags byte-for-byte identical.
+// RawTag is not supported as the element type of a slice.
+type RawTag struct {
+	// Type is the type of the tag, such as 10 for a TAG_Compound.
+	Type byte
+	// Data holds the payload of the tag, which excludes the type and the name of the tag.
+	Data []byte
+	// Encoding is the Encoding that Data is encoded in. A RawTag may only be encoded using the same
+	// Encoding. If nil, the RawTag may be encoded using any Encoding.
+	Encoding Encoding
+}
+
+// Decode decodes the payload of the RawTag into the pointer to a Go value passed, as if the tag was decoded
+// into it directly. See the Unmarshal docs for the conversion between NBT tags and Go types.
+func (raw RawTag) Decode(v any) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr {
+		return NonPointerTypeError{ActualType: val.Type()}
+	}
+	encoding := raw.Encoding
+	if encoding == nil {
+		encoding = NetworkLittleEndian
+	}
+	buf := bytes.NewBuffer(raw.Data)
+	d := &Decoder{Encoding: encoding, r: &offsetReader{
+		Reader:   buf,
+		ReadByte: buf.ReadByte,
+		Next:     buf.Next,
+	}}
+	return d.unmarshalTag(val.Elem(), tagType(raw.Type), "")
+}
+
+// rawTagType is the reflect.Type of a RawTag.
+var rawTagType = reflect.TypeFor[RawTag]()
+
+// unmarshalRawTag reads the payload of a tag with the tag type passed and sets its bytes to the RawTag value
+// passed. The payload is checked against the limits of the Decoder while it is read.
+func (d *Decoder) unmarshalRawTag(val reflect.Value, t tagType) error {
+	r := d.r
+	rec := &rawRecorder{r: r}
+	// The offset of the recording offsetReader is kept equal to that of r, so that errors hold the right
+	// offset.
+	recording := &offsetReader{Reader: rec, off: r.off, maxLength: r.maxLength}
+	recording.ReadByte = func() (byte, error) {
+		b, err := r.ReadByte()
+		if err == nil {
+			rec.data = append(rec.data, b)
+		}
+		recording.off = r.off
+		return b, err
+	}
+	recording.Next = func(n int) []byte {
+		data := r.Next(n)
+		rec.data = append(rec.data, data...)
+		recording.off = r.off
+		return data
+	}
+	d.r = recording
+	err := d.skipTag(t)
+	d.r = r
+	if err != nil {
+		return err
+	}
+	val.Set(reflect.ValueOf(RawTag{Type: byte(t), Data: rec.data, Encoding: d.Encoding}))
+	return nil
+}
+
+// rawRecorder is an io.Reader that reads from an offsetReader and records the bytes read.
+type rawRecorder struct {
+	r    *offsetReader
+	data []byte
+}
+
+// Read ...
+func (rec *rawRecorder) Read(p []byte) (int, error) {
+	n, err := rec.r.Read(p)
+	rec.data = append(rec.data, p[:n]...)
+	return n, err
+}
+
+// Len returns the amount of bytes remaining in the io.Reader of the offsetReader, or math.MaxInt if the
+// io.Reader is not able to report it, so that offsetReader.checkLength behaves like it does for the
+// io.Reader itself.
+func (rec *rawRecorder) Len() int {
+	if l, ok := rec.r.Reader.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+	return math.MaxInt
+}
+
+// marshalRawTag writes the type, name and payload of the RawTag passed.
+func (e *Encoder) marshalRawTag(raw RawTag, tagName string) error {
+	t := tagType(raw.Type)
+	if !t.IsValid() || t == tagEnd {
+		return UnknownTagError{Off: e.w.off, Op: "RawTag", TagType: t}
+	}
+	if raw.Encoding != nil && raw.Encoding != e.Encoding {
+		return RawTagEncodingError{ValueName: tagName}
+	}
+	if err := e.writeTag(t, tagName); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(raw.Data); err != nil {
+		return FailedWriteError{Op: "WriteRawTag", Off: e.w.off, Err: err}
+	}
+	return nil
+}
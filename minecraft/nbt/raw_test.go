@@ -0,0 +1,125 @@
+package nbt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// rawBlockEntity is a block entity of which only the ID is decoded, while the rest of its tags are kept as
+// they are.
+type rawBlockEntity struct {
+	ID    string `nbt:"id"`
+	Items RawTag `nbt:"Items"`
+	Extra RawTag `nbt:"Extra"`
+}
+
+func TestRawTagStructField(t *testing.T) {
+	items := []any{orderedMap("Slot", byte(0), "Name", "minecraft:stone", "Count", byte(3))}
+	extra := orderedMap("z", int64(1), "a", [2]int32{2, 3}, "s", "text")
+	src := orderedMap("id", "Chest", "Items", items, "Extra", extra)
+
+	for _, encoding := range []Encoding{NetworkLittleEndian, LittleEndian, BigEndian} {
+		data, err := MarshalEncoding(src, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", encoding, err)
+		}
+		// Decode from a reader that is unable to report its length too, which is read differently.
+		readers := map[string]io.Reader{"buffer": bytes.NewReader(data), "reader": struct{ io.Reader }{bytes.NewReader(data)}}
+		for name, r := range readers {
+			var entity rawBlockEntity
+			if err := NewDecoderWithEncoding(r, encoding).Decode(&entity); err != nil {
+				t.Fatalf("%T (%v): decode: %v", encoding, name, err)
+			}
+			if entity.ID != "Chest" || entity.Items.Type != byte(tagSlice) || entity.Extra.Type != byte(tagStruct) || entity.Extra.Encoding != encoding {
+				t.Fatalf("%T (%v): unexpected entity %+v", encoding, name, entity)
+			}
+
+			// Encoding the struct again must produce exactly the same data.
+			again, err := MarshalEncoding(entity, encoding)
+			if err != nil {
+				t.Fatalf("%T (%v): marshal again: %v", encoding, name, err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Errorf("%T (%v): expected %x after encoding again, got %x", encoding, name, data, again)
+			}
+		}
+
+		// Changing a field that is not raw leaves the raw tags unchanged.
+		var entity rawBlockEntity
+		if err := UnmarshalEncoding(data, &entity, encoding); err != nil {
+			t.Fatalf("%T: unmarshal: %v", encoding, err)
+		}
+		entity.ID = "Barrel"
+		changed, err := MarshalEncoding(entity, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal changed: %v", encoding, err)
+		}
+		want, _ := MarshalEncoding(orderedMap("id", "Barrel", "Items", items, "Extra", extra), encoding)
+		if !bytes.Equal(changed, want) {
+			t.Errorf("%T: expected %x after changing the ID, got %x", encoding, want, changed)
+		}
+	}
+}
+
+func TestRawTagDecode(t *testing.T) {
+	data, err := Marshal(map[string]any{
+		"compound": map[string]any{"a": int32(1), "b": "c"},
+		"list":     []int32{1, 2},
+		"string":   "s",
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var m map[string]RawTag
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var compound map[string]any
+	if err := m["compound"].Decode(&compound); err != nil {
+		t.Fatalf("decode compound: %v", err)
+	}
+	if want := map[string]any{"a": int32(1), "b": "c"}; !reflect.DeepEqual(compound, want) {
+		t.Errorf("expected %v, got %v", want, compound)
+	}
+	var list []int32
+	if err := m["list"].Decode(&list); err != nil || !reflect.DeepEqual(list, []int32{1, 2}) {
+		t.Errorf("expected [1 2], got %v (%v)", list, err)
+	}
+	var s string
+	if err := m["string"].Decode(&s); err != nil || s != "s" {
+		t.Errorf("expected s, got %v (%v)", s, err)
+	}
+	if err := m["string"].Decode(&list); err == nil {
+		t.Error("expected decoding a TAG_String into a slice to fail")
+	}
+	if err := m["string"].Decode(s); !errors.As(err, &NonPointerTypeError{}) {
+		t.Errorf("expected NonPointerTypeError, got %v", err)
+	}
+}
+
+func TestRawTagEncoding(t *testing.T) {
+	data, err := MarshalEncoding(map[string]any{"v": int32(300)}, LittleEndian)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var m map[string]RawTag
+	if err := UnmarshalEncoding(data, &m, LittleEndian); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, err := MarshalEncoding(m, NetworkLittleEndian); !errors.As(err, &RawTagEncodingError{}) {
+		t.Errorf("expected RawTagEncodingError, got %v", err)
+	}
+	// A RawTag without an Encoding may be encoded using any Encoding.
+	raw := RawTag{Type: byte(tagByte), Data: []byte{7}}
+	for _, encoding := range []Encoding{NetworkLittleEndian, LittleEndian, BigEndian} {
+		if _, err := MarshalEncoding(map[string]RawTag{"b": raw}, encoding); err != nil {
+			t.Errorf("%T: marshal: %v", encoding, err)
+		}
+	}
+	if _, err := Marshal(map[string]RawTag{"b": {Type: byte(tagEnd)}}); !errors.As(err, &UnknownTagError{}) {
+		t.Errorf("expected UnknownTagError for a raw TAG_End, got %v", err)
+	}
+}
@@ -14,6 +14,10 @@ type offsetReader struct {
 	ReadByte func() (byte, error)
 	// Next is a function provided by offsetReader if the io.Reader does not have a Next method.
 	Next func(n int) []byte
+
+	// maxLength is the maximum length of arrays and lists read, as set by Decoder.MaxArrayLength. If 0, the
+	// length is not limited other than by the bytes remaining in the io.Reader.
+	maxLength int
 }
 
 // newOffsetReader returns a new offset reader for the io.Reader passed, setting the ReadByte and Next
@@ -56,3 +60,17 @@ func (b *offsetReader) Read(p []byte) (n int, err error) {
 	b.off += int64(n)
 	return
 }
+
+// checkLength checks if n, the length of an array or list read, is valid. An error is returned if n
+// is negative or exceeds the maximum length set, or if the io.Reader is able to report the amount of bytes
+// remaining (like a *bytes.Reader or *bytes.Buffer) and fewer than n elements of size bytes remain. This
+// prevents a length read from causing a large allocation before it is found that the data is not present.
+func (b *offsetReader) checkLength(n int64, size int, op string) error {
+	if n < 0 || (b.maxLength > 0 && n > int64(b.maxLength)) {
+		return InvalidLengthError{Off: b.off, Op: op, N: n, Max: b.maxLength}
+	}
+	if l, ok := b.Reader.(interface{ Len() int }); ok && n*int64(size) > int64(l.Len()) {
+		return BufferOverrunError{Op: op}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package nbt
 import (
 	"math"
 	"reflect"
+	"strings"
 )
 
 const (
@@ -106,3 +107,46 @@ func tagFromType(p reflect.Type) tagType {
 	}
 	return math.MaxUint8
 }
+
+// structTag holds the options parsed from an 'nbt' struct field tag.
+type structTag struct {
+	// name is the name of the tag that the field is encoded as. It is empty if the field name should be used.
+	name string
+	// omitEmpty specifies if the field should not be encoded if it holds an empty value.
+	omitEmpty bool
+	// def is the default value of the field, set if no tag for the field is present in a TAG_Compound
+	// decoded. hasDefault specifies if a default value was set.
+	def        string
+	hasDefault bool
+}
+
+// parseStructTag parses an 'nbt' struct field tag in the format 'name(,omitempty)(,default=value)'. Because
+// the default value may contain commas itself, the default option must be the last option in the tag.
+func parseStructTag(tag string) structTag {
+	name, opts, _ := strings.Cut(tag, ",")
+	t := structTag{name: name}
+	for opts != "" {
+		if def, ok := strings.CutPrefix(opts, "default="); ok {
+			t.def, t.hasDefault = def, true
+			break
+		}
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "omitempty" {
+			t.omitEmpty = true
+		}
+	}
+	return t
+}
+
+// isEmptyValue checks if a reflect.Value is empty for the purpose of the 'omitempty' struct tag option. Like
+// in the JSON standard library, slices, maps and strings are empty if their length is 0. Any other value is
+// empty if it is the zero value of its type.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
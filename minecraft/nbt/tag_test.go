@@ -0,0 +1,130 @@
+package nbt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// defaults is a struct of which the fields have default values set in their struct tags.
+type defaults struct {
+	EmbeddedDefaults
+	Name    string  `nbt:"name,default=Steve, the second"`
+	Count   int32   `nbt:"count,omitempty,default=-5"`
+	Enabled bool    `nbt:",default=true"`
+	Scale   float32 `nbt:"scale,default=1.5"`
+	Level   byte    `nbt:"level,default=200"`
+	Plain   int64   `nbt:"plain"`
+	Skipped string  `nbt:"-"`
+}
+
+// EmbeddedDefaults is embedded in defaults to check that defaults of embedded structs are set.
+type EmbeddedDefaults struct {
+	Mode int16 `nbt:"mode,default=3"`
+}
+
+func TestStructTagDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]any
+		want defaults
+	}{
+		{
+			name: "all missing",
+			data: map[string]any{},
+			want: defaults{EmbeddedDefaults{Mode: 3}, "Steve, the second", -5, true, 1.5, 200, 0, ""},
+		},
+		{
+			// Tags that are present keep their value, even if it is the zero value.
+			name: "all present",
+			data: map[string]any{"mode": int16(0), "name": "", "count": int32(0), "Enabled": byte(0), "scale": float32(0), "level": byte(0), "plain": int64(7)},
+			want: defaults{Plain: 7},
+		},
+		{
+			name: "some missing",
+			data: map[string]any{"name": "Alex", "level": byte(1)},
+			want: defaults{EmbeddedDefaults{Mode: 3}, "Alex", -5, true, 1.5, 1, 0, ""},
+		},
+	}
+	for _, test := range tests {
+		data, err := Marshal(test.data)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", test.name, err)
+		}
+		var got defaults
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatalf("%v: unmarshal: %v", test.name, err)
+		}
+		if got != test.want {
+			t.Errorf("%v: expected %+v, got %+v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestStructTagDefaultInvalid(t *testing.T) {
+	data, err := Marshal(map[string]any{})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var v struct {
+		X int32 `nbt:"x,default=abc"`
+	}
+	var defaultErr InvalidDefaultValueError
+	if err := Unmarshal(data, &v); !errors.As(err, &defaultErr) || defaultErr.Field != "x" || defaultErr.Value != "abc" {
+		t.Errorf("expected InvalidDefaultValueError for field x, got %v", err)
+	}
+}
+
+func TestStructTagOmitEmpty(t *testing.T) {
+	type inner struct {
+		A int32  `nbt:"a,omitempty"`
+		B string `nbt:"b,omitempty"`
+	}
+	type outer struct {
+		Int      int32          `nbt:"int,omitempty"`
+		String   string         `nbt:"string,omitempty"`
+		Slice    []int32        `nbt:"slice,omitempty"`
+		Map      map[string]any `nbt:"map,omitempty"`
+		Inner    inner          `nbt:"inner"`
+		Omitted  inner          `nbt:"omitted,omitempty"`
+		Required int32          `nbt:"required"`
+	}
+	tests := []struct {
+		name string
+		v    outer
+		want map[string]any
+	}{
+		{
+			// An empty struct is still written as an empty compound unless it is tagged with omitempty itself.
+			name: "empty",
+			v:    outer{Slice: []int32{}, Map: map[string]any{}},
+			want: map[string]any{"inner": map[string]any{}, "required": int32(0)},
+		},
+		{
+			name: "filled",
+			v:    outer{Int: 1, String: "s", Slice: []int32{2}, Map: map[string]any{"k": byte(3)}, Inner: inner{A: 4}, Omitted: inner{B: "b"}, Required: 5},
+			want: map[string]any{
+				"int":      int32(1),
+				"string":   "s",
+				"slice":    []int32{2},
+				"map":      map[string]any{"k": byte(3)},
+				"inner":    map[string]any{"a": int32(4)},
+				"omitted":  map[string]any{"b": "b"},
+				"required": int32(5),
+			},
+		},
+	}
+	for _, test := range tests {
+		data, err := Marshal(test.v)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", test.name, err)
+		}
+		var got map[string]any
+		if err := Unmarshal(data, &got); err != nil {
+			t.Fatalf("%v: unmarshal: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
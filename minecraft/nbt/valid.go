@@ -0,0 +1,174 @@
+package nbt
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ValidOption is an option that may be passed to Valid to limit the NBT considered valid.
+type ValidOption func(d *Decoder)
+
+// ValidMaxDepth limits the nesting depth of TAG_List and TAG_Compound tags in NBT checked by Valid to n.
+// If n is 0 or lower, the default maximum depth of 512 is used.
+func ValidMaxDepth(n int) ValidOption {
+	return func(d *Decoder) { d.MaxDepth = n }
+}
+
+// ValidMaxArrayLength limits the number of elements of a single TAG_Byte_Array, TAG_Int_Array,
+// TAG_Long_Array or TAG_List tag in NBT checked by Valid to n. If n is 0 or lower, the length is not limited.
+func ValidMaxArrayLength(n int) ValidOption {
+	return func(d *Decoder) { d.MaxArrayLength = n }
+}
+
+// ValidMaxElements limits the total number of tags in NBT checked by Valid to n, including the root tag and
+// the elements of TAG_Lists. Elements of TAG_Byte_Array, TAG_Int_Array and TAG_Long_Array tags are not
+// counted separately. If n is 0 or lower, the total number of tags is not limited.
+func ValidMaxElements(n int) ValidOption {
+	return func(d *Decoder) { d.maxElements = n }
+}
+
+// Valid checks if the data passed holds a single well-formed NBT tag encoded using the encoding passed,
+// within the limits set using the options passed. Unlike Unmarshal, Valid does not decode the tags into Go
+// values, which makes it a cheap check before decoding or storing untrusted NBT. Valid returns the first
+// error encountered, which is of the same type as the error Unmarshal would return for it, or nil if the
+// data is valid. An error is also returned if bytes remain after the tag.
+func Valid(data []byte, encoding Encoding, opts ...ValidOption) error {
+	buf := bytes.NewBuffer(data)
+	d := &Decoder{Encoding: encoding, r: &offsetReader{
+		Reader:   buf,
+		ReadByte: buf.ReadByte,
+		Next:     buf.Next,
+	}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.r.maxLength = d.MaxArrayLength
+
+	t, _, err := d.tag()
+	if err != nil {
+		return err
+	}
+	if err := d.skipTag(t); err != nil {
+		return err
+	}
+	if buf.Len() != 0 {
+		return fmt.Errorf("nbt: %v unread bytes left after tag", buf.Len())
+	}
+	return nil
+}
+
+// skipTag reads the payload of a tag with the tag type passed without decoding it into a Go value. It
+// checks the payload against the limits of the Decoder the same way unmarshalTag does.
+func (d *Decoder) skipTag(t tagType) error {
+	if d.maxElements > 0 {
+		if d.elements >= d.maxElements {
+			return MaximumElementsReachedError{Elements: d.maxElements}
+		}
+		d.elements++
+	}
+	var err error
+	switch t {
+	default:
+		return UnknownTagError{Off: d.r.off, TagType: t, Op: "Match"}
+	case tagEnd:
+		return UnexpectedTagError{Off: d.r.off, TagType: tagEnd}
+	case tagByte:
+		if _, err := d.r.ReadByte(); err != nil {
+			return BufferOverrunError{Op: "Byte"}
+		}
+	case tagInt16:
+		_, err = d.Encoding.Int16(d.r)
+	case tagInt32:
+		_, err = d.Encoding.Int32(d.r)
+	case tagInt64:
+		_, err = d.Encoding.Int64(d.r)
+	case tagFloat32:
+		_, err = d.Encoding.Float32(d.r)
+	case tagFloat64:
+		_, err = d.Encoding.Float64(d.r)
+	case tagString:
+		_, err = d.Encoding.String(d.r)
+	case tagByteArray:
+		return d.skipArray(tagByte, "ByteArray")
+	case tagInt32Array:
+		return d.skipArray(tagInt32, "Int32Slice")
+	case tagInt64Array:
+		return d.skipArray(tagInt64, "Int64Slice")
+	case tagSlice:
+		// The depth is checked before reading the list, even if it is empty, as done by unmarshalTag.
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		listTypeByte, err := d.r.ReadByte()
+		if err != nil {
+			return BufferOverrunError{Op: "List"}
+		}
+		listType := tagType(listTypeByte)
+		if !listType.IsValid() {
+			return UnknownTagError{Off: d.r.off, TagType: listType, Op: "Slice"}
+		}
+		length, err := d.Encoding.Int32(d.r)
+		if err != nil {
+			return err
+		}
+		if length == 0 {
+			return nil
+		}
+		if err := d.r.checkLength(int64(length), 1, "Slice"); err != nil {
+			return err
+		}
+		for i := int32(0); i < length; i++ {
+			if err := d.skipTag(listType); err != nil {
+				return err
+			}
+		}
+	case tagStruct:
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		for {
+			nestedTagType, _, err := d.tag()
+			if err != nil {
+				return err
+			}
+			if nestedTagType == tagEnd {
+				return nil
+			}
+			if err := d.skipTag(nestedTagType); err != nil {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+// skipArray reads a TAG_Byte_Array, TAG_Int_Array or TAG_Long_Array with elements of the tag type passed,
+// without allocating memory for its elements.
+func (d *Decoder) skipArray(elem tagType, op string) error {
+	length, err := d.Encoding.Int32(d.r)
+	if err != nil {
+		return BufferOverrunError{Op: op}
+	}
+	if err := d.r.checkLength(int64(length), 1, op); err != nil {
+		return err
+	}
+	if elem == tagByte {
+		if len(d.r.Next(int(length))) != int(length) {
+			return BufferOverrunError{Op: op}
+		}
+		return nil
+	}
+	for i := int32(0); i < length; i++ {
+		if elem == tagInt32 {
+			_, err = d.Encoding.Int32(d.r)
+		} else {
+			_, err = d.Encoding.Int64(d.r)
+		}
+		if err != nil {
+			return BufferOverrunError{Op: op}
+		}
+	}
+	return nil
+}
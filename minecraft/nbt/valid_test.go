@@ -0,0 +1,104 @@
+package nbt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValid(t *testing.T) {
+	v := map[string]any{
+		"byte":      byte(1),
+		"short":     int16(2),
+		"int":       int32(3),
+		"long":      int64(4),
+		"float":     float32(5),
+		"double":    float64(6),
+		"string":    "seven",
+		"byteArray": [2]byte{8, 9},
+		"intArray":  [2]int32{10, 11},
+		"longArray": [2]int64{12, 13},
+		"list":      []int32{14, 15, 16},
+		"compounds": []any{map[string]any{"a": byte(1)}, map[string]any{}},
+		"compound":  map[string]any{"nested": map[string]any{"s": "t"}},
+	}
+	for _, encoding := range []Encoding{NetworkLittleEndian, LittleEndian, BigEndian} {
+		data, err := MarshalEncoding(v, encoding)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", encoding, err)
+		}
+		if err := Valid(data, encoding); err != nil {
+			t.Errorf("%T: expected NBT to be valid, got %v", encoding, err)
+		}
+		// Every truncation of the data must be invalid.
+		for n := 0; n < len(data); n++ {
+			if err := Valid(data[:n], encoding); err == nil {
+				t.Errorf("%T: expected NBT truncated to %v bytes to be invalid", encoding, n)
+			}
+		}
+		if err := Valid(append(data, 0), encoding); err == nil {
+			t.Errorf("%T: expected NBT followed by trailing bytes to be invalid", encoding)
+		}
+	}
+}
+
+func TestValidOptions(t *testing.T) {
+	data, err := Marshal(map[string]any{
+		"list":     []int32{1, 2, 3, 4},
+		"compound": map[string]any{"a": byte(1), "b": byte(2)},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	tests := []struct {
+		name string
+		opts []ValidOption
+		err  any
+	}{
+		{name: "no limits"},
+		{name: "depth", opts: []ValidOption{ValidMaxDepth(2)}},
+		{name: "depth exceeded", opts: []ValidOption{ValidMaxDepth(1)}, err: &MaximumDepthReachedError{}},
+		{name: "array length", opts: []ValidOption{ValidMaxArrayLength(4)}},
+		{name: "array length exceeded", opts: []ValidOption{ValidMaxArrayLength(3)}, err: &InvalidLengthError{}},
+		// The root compound, the list and its 4 elements, and the compound and its 2 tags.
+		{name: "elements", opts: []ValidOption{ValidMaxElements(9)}},
+		{name: "elements exceeded", opts: []ValidOption{ValidMaxElements(8)}, err: &MaximumElementsReachedError{}},
+	}
+	for _, test := range tests {
+		err := Valid(data, NetworkLittleEndian, test.opts...)
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%v: expected NBT to be valid, got %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, test.err) {
+			t.Errorf("%v: expected error of type %T, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+// TestValidMatchesUnmarshal checks that Valid returns an error of the same type as Unmarshal for invalid NBT.
+func TestValidMatchesUnmarshal(t *testing.T) {
+	tests := map[string][]byte{
+		"unknown tag":           {0x0d, 0},
+		"unknown list type":     {byte(tagSlice), 0, 0x0d, 2},
+		"end tag":               {byte(tagEnd)},
+		"string overrun":        {byte(tagString), 0, 4, 'a'},
+		"byte array overrun":    {byte(tagByteArray), 0, 4, 1},
+		"unterminated varint":   {byte(tagInt32), 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"unterminated compound": {byte(tagStruct), 0, byte(tagByte), 0, 1},
+	}
+	for name, data := range tests {
+		var v any
+		unmarshalErr := Unmarshal(data, &v)
+		validErr := Valid(data, NetworkLittleEndian)
+		if unmarshalErr == nil || validErr == nil {
+			t.Errorf("%v: expected both to fail, got %v (unmarshal) and %v (valid)", name, unmarshalErr, validErr)
+			continue
+		}
+		if reflect.TypeOf(unmarshalErr) != reflect.TypeOf(validErr) {
+			t.Errorf("%v: expected errors of the same type, got %T (unmarshal) and %T (valid)", name, unmarshalErr, validErr)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package minecraft
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// NetSim holds settings to simulate the conditions of a poor network connection. It may be set to
+// Dialer.Simulate to test how a client handles high latency without connecting to a distant server.
+// The delays are applied to the raw, compressed and encrypted data sent and received over the network.
+// Because Minecraft batches must be decrypted in the order they were sent, NetSim never reorders data: A
+// batch delayed by jitter also delays the batches sent after it.
+type NetSim struct {
+	// Latency is the delay added to data sent and to data received, so that the round trip time of the
+	// connection increases by twice the Latency.
+	Latency time.Duration
+	// Jitter is the maximum random deviation from Latency. Each batch is delayed by Latency plus or minus a
+	// random duration up to Jitter. The delay of a batch is never negative.
+	Jitter time.Duration
+}
+
+// delay returns a random delay for a single batch based on the Latency and Jitter of the NetSim.
+func (sim NetSim) delay() time.Duration {
+	d := sim.Latency
+	if sim.Jitter > 0 {
+		d += rand.N(2*sim.Jitter+1) - sim.Jitter
+	}
+	return max(d, 0)
+}
+
+// simPacket is a batch held back by a simConn until the time it is delivered at.
+type simPacket struct {
+	data []byte
+	at   time.Time
+}
+
+// simConn wraps around a net.Conn and delays the data read from and written to it according to a NetSim.
+type simConn struct {
+	net.Conn
+	sim NetSim
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	readDeadline deadline
+	reads        chan simPacket
+	writes       chan simPacket
+
+	// writeMu guards closed and sending to writes, so that no batches are queued once writes is closed.
+	writeMu sync.Mutex
+	closed  bool
+	// skipDelay is cancelled when the simConn is closed, after which the batches still queued are written
+	// without waiting for their delay. flushed is closed once writeLoop has returned.
+	skipDelay context.Context
+	stopDelay context.CancelFunc
+	flushed   chan struct{}
+
+	errMu             sync.Mutex
+	readErr, writeErr error
+}
+
+// newSimConn wraps the net.Conn passed in a simConn that delays its data according to the NetSim passed.
+func newSimConn(netConn net.Conn, sim NetSim) *simConn {
+	ctx := context.Background()
+	if c, ok := netConn.(interface{ Context() context.Context }); ok {
+		ctx = c.Context()
+	}
+	c := &simConn{Conn: netConn, sim: sim, reads: make(chan simPacket, 256), writes: make(chan simPacket, 256), flushed: make(chan struct{})}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.skipDelay, c.stopDelay = context.WithCancel(c.ctx)
+	go c.readLoop()
+	go c.writeLoop()
+	return c
+}
+
+// readLoop continuously reads batches from the underlying net.Conn and queues them to be returned by
+// ReadPacket once their delay has passed.
+func (c *simConn) readLoop() {
+	var buf []byte
+	pr, ok := c.Conn.(interface{ ReadPacket() ([]byte, error) })
+	if !ok {
+		buf = make([]byte, 1024*1024*3)
+	}
+	// next is the earliest time at which the next batch may be delivered, so that batches are never
+	// reordered by jitter.
+	var next time.Time
+	for {
+		var data []byte
+		var err error
+		if pr != nil {
+			data, err = pr.ReadPacket()
+		} else {
+			var n int
+			n, err = c.Conn.Read(buf)
+			data = append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			c.setErr(&c.readErr, err)
+			close(c.reads)
+			return
+		}
+		next = later(next, time.Now().Add(c.sim.delay()))
+		select {
+		case <-c.ctx.Done():
+			close(c.reads)
+			return
+		case c.reads <- simPacket{data: data, at: next}:
+		}
+	}
+}
+
+// writeLoop continuously writes the batches queued by Write to the underlying net.Conn once their delay has
+// passed. Once the simConn is closed, the batches still queued are written immediately, after which
+// writeLoop returns.
+func (c *simConn) writeLoop() {
+	defer close(c.flushed)
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case pk, ok := <-c.writes:
+			if !ok {
+				return
+			}
+			if c.err(&c.writeErr) != nil {
+				// A previous write failed. The batch is dropped, but the queue is still drained, so that
+				// Write never blocks on a full queue.
+				continue
+			}
+			sleepUntil(c.skipDelay, pk.at)
+			if _, err := c.Conn.Write(pk.data); err != nil {
+				c.setErr(&c.writeErr, err)
+			}
+		}
+	}
+}
+
+// ReadPacket returns the next batch read from the underlying net.Conn once its delay has passed.
+func (c *simConn) ReadPacket() ([]byte, error) {
+	var pk simPacket
+	select {
+	case <-c.ctx.Done():
+		return nil, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return nil, os.ErrDeadlineExceeded
+	case p, ok := <-c.reads:
+		if !ok {
+			if err := c.err(&c.readErr); err != nil {
+				return nil, err
+			}
+			return nil, net.ErrClosed
+		}
+		pk = p
+	}
+	if !sleepUntil(c.ctx, pk.at) {
+		return nil, net.ErrClosed
+	}
+	return pk.data, nil
+}
+
+// Read ...
+func (c *simConn) Read(b []byte) (int, error) {
+	data, err := c.ReadPacket()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < len(data) {
+		return 0, errBufferTooSmall
+	}
+	return copy(b, data), nil
+}
+
+// Write queues the data passed to be written to the underlying net.Conn once its delay has passed. Write
+// returns an error if a previous write to the underlying net.Conn failed.
+func (c *simConn) Write(b []byte) (int, error) {
+	if err := c.err(&c.writeErr); err != nil {
+		return 0, err
+	}
+	// The delay is measured from the moment Write is called, but a batch is never written before the
+	// batches queued before it, so that batches are never reordered by jitter.
+	pk := simPacket{data: append([]byte(nil), b...), at: time.Now().Add(c.sim.delay())}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	select {
+	case <-c.ctx.Done():
+		return 0, net.ErrClosed
+	case c.writes <- pk:
+		return len(b), nil
+	}
+}
+
+// Close writes the batches still queued by Write to the underlying net.Conn without waiting for their delay,
+// so that data written before Close, such as a Disconnect packet, is not lost, and closes the underlying
+// net.Conn.
+func (c *simConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.stopDelay()
+		c.writeMu.Lock()
+		c.closed = true
+		close(c.writes)
+		c.writeMu.Unlock()
+		<-c.flushed
+
+		c.cancel()
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// SetDeadline ...
+func (c *simConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline ...
+func (c *simConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// Context returns the context of the simConn, which is cancelled when it is closed or when the context of
+// the underlying net.Conn, if any, is cancelled.
+func (c *simConn) Context() context.Context {
+	return c.ctx
+}
+
+// Latency returns the latency of the underlying net.Conn, if it measures it, increased by the Latency of the
+// NetSim.
+func (c *simConn) Latency() time.Duration {
+	if l, ok := c.Conn.(interface{ Latency() time.Duration }); ok {
+		return l.Latency() + c.sim.Latency
+	}
+	return c.sim.Latency
+}
+
+// setErr sets the error pointed to by dst to err, if it was not yet set.
+func (c *simConn) setErr(dst *error, err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	if *dst == nil {
+		*dst = err
+	}
+}
+
+// err returns the error pointed to by src.
+func (c *simConn) err(src *error) error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return *src
+}
+
+// later returns the later of the two times passed.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// sleepUntil blocks until the time passed or until ctx is done. It returns false if ctx was done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,42 @@
+package minecraft
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestSimConnCloseFlush checks that batches written to a simConn before it is closed are written to the
+// underlying net.Conn in order, without waiting for their delay.
+func TestSimConnCloseFlush(t *testing.T) {
+	a, b := newPipeConns()
+	defer b.Close()
+	c := newSimConn(a, NetSim{Latency: time.Minute})
+
+	batches := [][]byte{{1}, {2, 3}, {4, 5, 6}}
+	for _, batch := range batches {
+		if _, err := c.Write(batch); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	start := time.Now()
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if d := time.Since(start); d > time.Second*5 {
+		t.Errorf("expected Close to return without waiting for the delay, took %v", d)
+	}
+	if _, err := c.Write([]byte{7}); err == nil {
+		t.Error("expected error writing to closed simConn")
+	}
+
+	for _, want := range batches {
+		got, err := b.ReadPacket()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("expected %x, got %x", want, got)
+		}
+	}
+}
@@ -48,29 +48,39 @@ func (p *packetData) decode(conn *Conn) (pks []packet.Packet, err error) {
 		// No packet with the ID. This may be a custom packet of some sorts.
 		pk = &packet.Unknown{PacketID: p.h.PacketID}
 		if conn.disconnectOnUnknownPacket {
-			_ = conn.Close()
-			return nil, unknownPacketError{id: p.h.PacketID}
+			err := unknownPacketError{id: p.h.PacketID}
+			_ = conn.close(err)
+			return nil, err
 		}
 	} else {
 		pk = pkFunc()
 	}
 
 	defer func() {
-		if recoveredErr := recover(); recoveredErr != nil {
-			err = fmt.Errorf("decode packet %T: %w", pk, recoveredErr.(error))
+		if recovered := recover(); recovered != nil {
+			// Packets panic when they fail to decode. Every packet is framed separately, so the next packet
+			// may still be read.
+			recoveredErr, ok := recovered.(error)
+			if !ok {
+				recoveredErr = fmt.Errorf("panic: %v", recovered)
+			}
+			err = &DecodeError{PacketID: p.h.PacketID, Packet: pk, Err: recoveredErr}
 		}
 		if err != nil && !errors.Is(err, unknownPacketError{}) && conn.disconnectOnInvalidPacket {
-			_ = conn.Close()
+			// The DecodeError is the cause of closing the Conn, so that it is wrapped by the errors returned
+			// by the Conn from now on.
+			_ = conn.close(err)
 		}
 	}()
 
 	r := conn.proto.NewReader(p.payload, conn.shieldID.Load(), conn.readerLimits)
 	pk.Marshal(r)
 	if p.payload.Len() != 0 {
-		err = fmt.Errorf("decode packet %T: %v unread bytes left: 0x%x", pk, p.payload.Len(), p.payload.Bytes())
+		err = &DecodeError{PacketID: p.h.PacketID, Packet: pk, Err: fmt.Errorf("%v unread bytes left: 0x%x", p.payload.Len(), p.payload.Bytes())}
 	}
 	if conn.disconnectOnInvalidPacket && err != nil {
 		return nil, err
 	}
+	conn.observeLatency(pk, false)
 	return conn.proto.ConvertToLatest(pk, conn), err
 }
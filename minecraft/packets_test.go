@@ -0,0 +1,108 @@
+package minecraft_test
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// poolProtocol is a minecraft.Protocol that implements the packets of the pool it holds.
+type poolProtocol struct {
+	minecraft.Protocol
+	pool packet.Pool
+}
+
+// Packets returns the pool of the poolProtocol itself, rather than a copy, so that tests can check it is not
+// modified.
+func (p poolProtocol) Packets(bool) packet.Pool {
+	return p.pool
+}
+
+// customText is a custom packet registered with the ID of packet.Text.
+type customText struct {
+	packet.Text
+}
+
+// TestCustomPacketsProtocolPool checks that the custom packets of a Dialer and ListenConfig are checked
+// against the packets of the protocols that may be negotiated, rather than those of the current protocol.
+func TestCustomPacketsProtocolPool(t *testing.T) {
+	custom := packet.Pool{packet.IDText: func() packet.Packet { return &customText{} }}
+
+	// A protocol that does not implement packet.Text, so that a custom packet may take its ID.
+	pool := packet.NewServerPool()
+	delete(pool, packet.IDText)
+	pro := poolProtocol{Protocol: minecraft.DefaultProtocol, pool: pool}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{Protocol: pro, Packets: custom}, minecraft.ListenConfig{}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("expected custom packet not implemented by the protocol to be accepted, got %v", err)
+	}
+	defer client.Close()
+	defer server.Close()
+	if _, ok := pool[packet.IDText]; ok {
+		t.Error("expected the pool of the protocol not to be modified")
+	}
+	if err := server.WritePacket(&packet.Text{TextType: packet.TextTypeRaw, Message: "custom"}); err != nil {
+		t.Fatalf("write packet: %v", err)
+	}
+	if err := server.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		pk, err := client.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if text, ok := pk.(*customText); ok {
+			if text.Message != "custom" {
+				t.Errorf("expected message custom, got %v", text.Message)
+			}
+			break
+		}
+	}
+
+	// The current protocol does implement packet.Text, so the same packets are rejected for it.
+	_, _, err = minecraft.Pipe(ctx, minecraft.Dialer{Packets: custom}, minecraft.ListenConfig{}, minecraft.GameData{})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("expected dialer to reject custom packet implemented by the current protocol, got %v", err)
+	}
+
+	// A Listener checks the packets of every accepted protocol.
+	extra := maps.Clone(packet.NewClientPool())
+	extra[0x300] = func() packet.Packet { return &packet.Unknown{} }
+	accepted := poolProtocol{Protocol: legacyProtocol{minecraft.DefaultProtocol}, pool: extra}
+	cfg := minecraft.ListenConfig{
+		AcceptedProtocols: []minecraft.Protocol{accepted},
+		Packets:           packet.Pool{0x300: func() packet.Packet { return &packet.Unknown{} }},
+	}
+	_, _, err = minecraft.Pipe(ctx, minecraft.Dialer{}, cfg, minecraft.GameData{})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("expected listener to reject custom packet implemented by an accepted protocol, got %v", err)
+	}
+	cfg.OverridePackets = true
+	client, server, err = minecraft.Pipe(ctx, minecraft.Dialer{}, cfg, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("expected custom packet to be accepted with OverridePackets, got %v", err)
+	}
+	_ = client.Close()
+	_ = server.Close()
+}
+
+// legacyProtocol is a minecraft.Protocol with an ID and version below the current protocol.
+type legacyProtocol struct {
+	minecraft.Protocol
+}
+
+// ID ...
+func (legacyProtocol) ID() int32 { return protocol.CurrentProtocol - 1 }
+
+// Ver ...
+func (legacyProtocol) Ver() string { return "legacy" }
@@ -0,0 +1,268 @@
+package minecraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Pipe creates two connected Conns, a client and a server, without using a network. Similarly to net.Pipe,
+// the Conns are connected in memory, but all data sent between them passes through the same encoding,
+// compression and encryption as data sent over a network, so that Pipe may be used to test packets being
+// sent and received end-to-end.
+// The Dialer and ListenConfig passed are used to configure the client and server Conn respectively, for
+// example to select a different compression using ListenConfig.Compression. Authentication is always
+// disabled for the server Conn. Pipe performs the full login sequence and spawns the client using the
+// GameData passed, after which both Conns are returned. An error is returned if this sequence fails or if the
+// context passed is done before it is completed.
+func Pipe(ctx context.Context, d Dialer, cfg ListenConfig, data GameData) (client, server *Conn, err error) {
+	n := &pipeNetwork{l: newPipeListener()}
+	cfg.network, d.network = n, n
+	cfg.AuthenticationDisabled = true
+	d.TokenSource, d.XBLToken = nil, nil
+
+	l, err := cfg.Listen("pipe", pipeAddr.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	// Closing the Listener does not close the Conns it accepted, so it may be closed once Pipe returns.
+	defer l.Close()
+
+	// If one end fails to connect, the context is cancelled so that the other end stops too.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	dialed, accepted := make(chan result, 1), make(chan result, 1)
+	go func() {
+		c, err := d.DialContext(ctx, "pipe", pipeAddr.String())
+		if err == nil {
+			err = c.DoSpawnContext(ctx)
+		}
+		dialed <- result{conn: c, err: err}
+	}()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			accepted <- result{err: err}
+			return
+		}
+		conn := c.(*Conn)
+		accepted <- result{conn: conn, err: conn.StartGameContext(ctx, data)}
+	}()
+
+	for range 2 {
+		select {
+		case res := <-dialed:
+			client, err = res.conn, errors.Join(err, res.err)
+		case res := <-accepted:
+			server, err = res.conn, errors.Join(err, res.err)
+		}
+		if err != nil {
+			cancel()
+			_ = l.Close()
+		}
+	}
+	if err != nil {
+		if client != nil {
+			_ = client.Close()
+		}
+		if server != nil {
+			_ = server.Close()
+		}
+		return nil, nil, fmt.Errorf("pipe: %w", err)
+	}
+	return client, server, nil
+}
+
+// pipeAddr is the address used for both ends of the connections created using Pipe. The Listener requires it
+// to be a *net.UDPAddr.
+var pipeAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 19132}
+
+// pipeNetwork is a Network used by Pipe. It connects a client and a server in memory using pipeConns.
+type pipeNetwork struct {
+	l *pipeListener
+}
+
+// DialContext ...
+func (n *pipeNetwork) DialContext(ctx context.Context, _ string) (net.Conn, error) {
+	client, server := newPipeConns()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.l.closed:
+		return nil, net.ErrClosed
+	case n.l.incoming <- server:
+		return client, nil
+	}
+}
+
+// PingContext always returns an error, as a pipeNetwork cannot be pinged.
+func (n *pipeNetwork) PingContext(context.Context, string) ([]byte, error) {
+	return nil, errors.New("pipe network cannot be pinged")
+}
+
+// Listen ...
+func (n *pipeNetwork) Listen(string) (NetworkListener, error) {
+	return n.l, nil
+}
+
+// Compression ...
+func (n *pipeNetwork) Compression(net.Conn) packet.Compression {
+	return packet.FlateCompression
+}
+
+// pipeListener is the NetworkListener of a pipeNetwork. It accepts the server ends of pipeConns dialed.
+type pipeListener struct {
+	incoming chan net.Conn
+	once     sync.Once
+	closed   chan struct{}
+}
+
+// newPipeListener returns a new pipeListener ready to accept connections.
+func newPipeListener() *pipeListener {
+	return &pipeListener{incoming: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// Accept ...
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case c := <-l.incoming:
+		return c, nil
+	}
+}
+
+// Close ...
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr ...
+func (l *pipeListener) Addr() net.Addr { return pipeAddr }
+
+// ID ...
+func (l *pipeListener) ID() int64 { return 0 }
+
+// PongData ...
+func (l *pipeListener) PongData([]byte) {}
+
+// pipeConn is one end of an in-memory connection. Unlike net.Pipe, a pipeConn preserves the boundaries of the
+// data written, so that each Write corresponds to exactly one ReadPacket on the other end, like RakNet.
+type pipeConn struct {
+	r <-chan []byte
+	w chan<- []byte
+
+	// closed is closed when this end of the connection is closed, remoteClosed when the other end is.
+	closed, remoteClosed chan struct{}
+	once                 *sync.Once
+
+	readDeadline, writeDeadline deadline
+}
+
+// newPipeConns returns two connected pipeConns.
+func newPipeConns() (*pipeConn, *pipeConn) {
+	// The channels are buffered so that writing does not block until the other end reads, which would
+	// otherwise stall the flushing of packets.
+	a, b := make(chan []byte, 64), make(chan []byte, 64)
+	aClosed, bClosed := make(chan struct{}), make(chan struct{})
+	return &pipeConn{r: a, w: b, closed: aClosed, remoteClosed: bClosed, once: new(sync.Once)},
+		&pipeConn{r: b, w: a, closed: bClosed, remoteClosed: aClosed, once: new(sync.Once)}
+}
+
+// ReadPacket reads the data of a single Write call on the other end of the connection.
+func (c *pipeConn) ReadPacket() ([]byte, error) {
+	select {
+	case <-c.closed:
+		return nil, net.ErrClosed
+	case <-c.readDeadline.wait():
+		return nil, os.ErrDeadlineExceeded
+	case b := <-c.r:
+		return b, nil
+	case <-c.remoteClosed:
+		// Data written before the other end was closed may still be read.
+		select {
+		case b := <-c.r:
+			return b, nil
+		default:
+			return nil, io.EOF
+		}
+	}
+}
+
+// Read ...
+func (c *pipeConn) Read(b []byte) (int, error) {
+	data, err := c.ReadPacket()
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < len(data) {
+		return 0, errBufferTooSmall
+	}
+	return copy(b, data), nil
+}
+
+// Write ...
+func (c *pipeConn) Write(b []byte) (int, error) {
+	// A select picks a random case if several are ready, so an expired deadline is checked first to make
+	// sure writing always fails after it.
+	select {
+	case <-c.writeDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	default:
+	}
+	data := append([]byte(nil), b...)
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.remoteClosed:
+		return 0, io.ErrClosedPipe
+	case <-c.writeDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	case c.w <- data:
+		return len(b), nil
+	}
+}
+
+// Close ...
+func (c *pipeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// LocalAddr ...
+func (c *pipeConn) LocalAddr() net.Addr { return pipeAddr }
+
+// RemoteAddr ...
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr }
+
+// SetDeadline ...
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline ...
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline ...
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
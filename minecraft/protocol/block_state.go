@@ -0,0 +1,183 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// BlockState is a block with a specific set of properties, as found in the block palette of the game, such
+// as the canonical_block_states.nbt file shipped with the game.
+type BlockState struct {
+	// Name is the name of the block, for example 'minecraft:stone'.
+	Name string `nbt:"name"`
+	// Properties holds the properties of the block state, such as 'pillar_axis'. The values of the
+	// properties are of the types byte, int32 or string, like they are found in the block palette.
+	Properties map[string]any `nbt:"states"`
+	// Version is the version of the block state, which is used by the game to upgrade block states saved by
+	// older versions of the game.
+	Version int32 `nbt:"version"`
+}
+
+// ParseBlockStates parses a block palette from the data passed, which holds a sequence of block states
+// encoded as network little endian NBT compounds, like the canonical_block_states.nbt file of the game.
+func ParseBlockStates(data []byte) ([]BlockState, error) {
+	var states []BlockState
+	buf := bytes.NewBuffer(data)
+	dec := nbt.NewDecoder(buf)
+	for buf.Len() > 0 {
+		var s BlockState
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("decode block state %v: %w", len(states), err)
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// SortBlockStates sorts the block states passed in the order in which the game assigns runtime IDs to them
+// if StartGame.UseBlockNetworkIDHashes is false: Block states are sorted by the FNV-1a 64-bit hash of their
+// name, while the block states of a single block keep their order. This may be used to add custom blocks,
+// sent in the StartGame packet, to the block palette of the game before creating a BlockStateRegistry.
+func SortBlockStates(states []BlockState) {
+	sort.SliceStable(states, func(i, j int) bool {
+		return nameHash(states[i].Name) < nameHash(states[j].Name)
+	})
+}
+
+// nameHash returns the FNV-1a 64-bit hash of the name of a block.
+func nameHash(name string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// BlockNetworkIDHash returns the runtime ID of a block state if StartGame.UseBlockNetworkIDHashes is true.
+// This runtime ID is the FNV-1a 32-bit hash of the little endian NBT encoding of the name and properties of
+// the block state, with the properties sorted by their names. False is returned if one of the properties has
+// a value that cannot be encoded as NBT.
+func BlockNetworkIDHash(name string, properties map[string]any) (uint32, bool) {
+	if name == "minecraft:unknown" {
+		// The unknown block always has the same runtime ID, regardless of its properties.
+		return 0xfffffffe, true
+	}
+	key, ok := blockStateKey(name, properties)
+	if !ok {
+		return 0, false
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32(), true
+}
+
+// blockStateKey returns the little endian NBT encoding of the name and properties of a block state, with
+// the properties sorted by their names, so that equal block states always produce the same key.
+func blockStateKey(name string, properties map[string]any) ([]byte, bool) {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var states, m nbt.OrderedMap
+	for _, k := range keys {
+		states.Set(k, properties[k])
+	}
+	m.Set("name", name)
+	m.Set("states", states)
+	key, err := nbt.MarshalEncoding(m, nbt.LittleEndian)
+	return key, err == nil
+}
+
+// BlockStateRegistry maps block states to their runtime IDs and back. A BlockStateRegistry is created for
+// the block palette of one specific version of the game, as runtime IDs change between versions: A proxy
+// translating chunks between versions should create a BlockStateRegistry for the palette of each version.
+// A BlockStateRegistry is safe for concurrent use once created.
+// Gophertunnel does not embed the block palette of the game, as it never encodes or decodes chunks itself.
+// The palette must be loaded from the game files or from a library that ships it, using ParseBlockStates.
+type BlockStateRegistry struct {
+	hashed bool
+	states []BlockState
+	// ids maps the keys of block states, as returned by blockStateKey, to their runtime IDs.
+	ids map[string]uint32
+	// indices maps the runtime IDs of block states to their index in states if hashed is true.
+	indices map[uint32]int
+}
+
+// NewBlockStateRegistry creates a BlockStateRegistry for the block palette passed. If hashed is false, the
+// runtime ID of a block state is its index in the palette, which must therefore be ordered as the game
+// orders it (see SortBlockStates). If hashed is true, the runtime ID of a block state is its hash, as
+// returned by BlockNetworkIDHash. The value of hashed should be equal to StartGame.UseBlockNetworkIDHashes.
+// An error is returned if a block state could not be encoded or if two block states are equal.
+func NewBlockStateRegistry(states []BlockState, hashed bool) (*BlockStateRegistry, error) {
+	reg := &BlockStateRegistry{hashed: hashed, states: slices.Clone(states), ids: make(map[string]uint32, len(states))}
+	if hashed {
+		reg.indices = make(map[uint32]int, len(states))
+	}
+	for i, s := range reg.states {
+		key, ok := blockStateKey(s.Name, s.Properties)
+		if !ok {
+			return nil, fmt.Errorf("block state %v (%v) has properties that cannot be encoded", i, s.Name)
+		}
+		if _, ok := reg.ids[string(key)]; ok {
+			return nil, fmt.Errorf("block state %v (%v) is present more than once", i, s.Name)
+		}
+		rid := uint32(i)
+		if hashed {
+			rid, _ = BlockNetworkIDHash(s.Name, s.Properties)
+			if _, ok := reg.indices[rid]; ok {
+				return nil, fmt.Errorf("block state %v (%v) has the same hash as another block state", i, s.Name)
+			}
+			reg.indices[rid] = i
+		}
+		reg.ids[string(key)] = rid
+	}
+	return reg, nil
+}
+
+// RuntimeID returns the runtime ID of the block state with the name and properties passed. False is
+// returned if the block state is not present in the palette of the BlockStateRegistry. The properties must
+// have values of the same types as those in the palette.
+func (reg *BlockStateRegistry) RuntimeID(name string, properties map[string]any) (uint32, bool) {
+	key, ok := blockStateKey(name, properties)
+	if !ok {
+		return 0, false
+	}
+	rid, ok := reg.ids[string(key)]
+	return rid, ok
+}
+
+// BlockState returns the block state with the runtime ID passed. False is returned if no block state with
+// the runtime ID is present in the palette of the BlockStateRegistry. The Properties of the BlockState
+// returned must not be modified.
+func (reg *BlockStateRegistry) BlockState(rid uint32) (BlockState, bool) {
+	i := int(rid)
+	if reg.hashed {
+		var ok bool
+		if i, ok = reg.indices[rid]; !ok {
+			return BlockState{}, false
+		}
+	} else if i >= len(reg.states) {
+		return BlockState{}, false
+	}
+	return reg.states[i], true
+}
+
+// Len returns the number of block states in the palette of the BlockStateRegistry.
+func (reg *BlockStateRegistry) Len() int {
+	return len(reg.states)
+}
+
+// Version returns the highest version of the block states in the palette of the BlockStateRegistry. It may
+// be used to check which version of the game the palette belongs to.
+func (reg *BlockStateRegistry) Version() int32 {
+	var v int32
+	for _, s := range reg.states {
+		v = max(v, s.Version)
+	}
+	return v
+}
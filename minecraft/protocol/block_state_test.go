@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+func TestBlockNetworkIDHash(t *testing.T) {
+	// The hashes below are the hashed runtime IDs used by the game and by other implementations, such as
+	// Dragonfly and PocketMine-MP, for these block states.
+	tests := []struct {
+		name       string
+		properties map[string]any
+		want       int32
+	}{
+		{name: "minecraft:air", want: -604749536},
+		{name: "minecraft:stone", properties: map[string]any{}, want: -2144268767},
+		{name: "minecraft:unknown", properties: map[string]any{"a": int32(1)}, want: -2},
+	}
+	for _, test := range tests {
+		h, ok := BlockNetworkIDHash(test.name, test.properties)
+		if !ok || int32(h) != test.want {
+			t.Errorf("%v: expected hash %v, got %v (%v)", test.name, test.want, int32(h), ok)
+		}
+	}
+
+	// The order in which properties are added to the map must not matter.
+	a, _ := BlockNetworkIDHash("minecraft:log", map[string]any{"pillar_axis": "y", "old_log_type": "oak"})
+	b, _ := BlockNetworkIDHash("minecraft:log", map[string]any{"old_log_type": "oak", "pillar_axis": "y"})
+	if a != b {
+		t.Errorf("expected equal hashes regardless of property order, got %v and %v", a, b)
+	}
+	if _, ok := BlockNetworkIDHash("minecraft:log", map[string]any{"invalid": make(chan int)}); ok {
+		t.Error("expected hashing a property that cannot be encoded to fail")
+	}
+}
+
+// testBlockStates returns a small block palette used in tests.
+func testBlockStates() []BlockState {
+	return []BlockState{
+		{Name: "minecraft:stone", Properties: map[string]any{}, Version: 1},
+		{Name: "minecraft:wool", Properties: map[string]any{"color": "white"}, Version: 2},
+		{Name: "minecraft:wool", Properties: map[string]any{"color": "red"}, Version: 2},
+		{Name: "minecraft:air", Properties: map[string]any{}, Version: 3},
+	}
+}
+
+func TestParseBlockStates(t *testing.T) {
+	var data []byte
+	for _, s := range testBlockStates() {
+		b, err := nbt.Marshal(s)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		data = append(data, b...)
+	}
+	states, err := ParseBlockStates(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !reflect.DeepEqual(states, testBlockStates()) {
+		t.Errorf("expected %v, got %v", testBlockStates(), states)
+	}
+	if _, err := ParseBlockStates(data[:len(data)-1]); err == nil {
+		t.Error("expected parsing a truncated palette to fail")
+	}
+}
+
+func TestSortBlockStates(t *testing.T) {
+	states := testBlockStates()
+	SortBlockStates(states)
+	for i := 1; i < len(states); i++ {
+		if nameHash(states[i-1].Name) > nameHash(states[i].Name) {
+			t.Fatalf("expected block states to be sorted by name hash, got %v", states)
+		}
+	}
+	// The block states of a single block keep their order.
+	var colours []any
+	for _, s := range states {
+		if s.Name == "minecraft:wool" {
+			colours = append(colours, s.Properties["color"])
+		}
+	}
+	if !reflect.DeepEqual(colours, []any{"white", "red"}) {
+		t.Errorf("expected wool block states to keep their order, got %v", colours)
+	}
+}
+
+func TestBlockStateRegistry(t *testing.T) {
+	for _, hashed := range []bool{false, true} {
+		states := testBlockStates()
+		reg, err := NewBlockStateRegistry(states, hashed)
+		if err != nil {
+			t.Fatalf("hashed=%v: new registry: %v", hashed, err)
+		}
+		if reg.Len() != len(states) || reg.Version() != 3 {
+			t.Errorf("hashed=%v: expected %v block states of version 3, got %v of version %v", hashed, len(states), reg.Len(), reg.Version())
+		}
+		for i, s := range states {
+			rid, ok := reg.RuntimeID(s.Name, s.Properties)
+			if !ok {
+				t.Fatalf("hashed=%v: expected runtime ID for %v", hashed, s)
+			}
+			want := uint32(i)
+			if hashed {
+				want, _ = BlockNetworkIDHash(s.Name, s.Properties)
+			}
+			if rid != want {
+				t.Errorf("hashed=%v: expected runtime ID %v for %v, got %v", hashed, want, s, rid)
+			}
+			if got, ok := reg.BlockState(rid); !ok || !reflect.DeepEqual(got, s) {
+				t.Errorf("hashed=%v: expected %v for runtime ID %v, got %v (%v)", hashed, s, rid, got, ok)
+			}
+		}
+		if _, ok := reg.RuntimeID("minecraft:wool", map[string]any{"color": "blue"}); ok {
+			t.Errorf("hashed=%v: expected no runtime ID for a block state not in the palette", hashed)
+		}
+		if _, ok := reg.BlockState(uint32(len(states))); ok {
+			t.Errorf("hashed=%v: expected no block state for a runtime ID not in the palette", hashed)
+		}
+	}
+
+	if _, err := NewBlockStateRegistry(append(testBlockStates(), testBlockStates()[1]), false); err == nil {
+		t.Error("expected a palette with a duplicate block state to be rejected")
+	}
+}
@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -131,7 +132,25 @@ type CameraInstructionFade struct {
 // Marshal encodes/decodes a CameraInstructionFade.
 func (x *CameraInstructionFade) Marshal(r IO) {
 	OptionalMarshaler(r, &x.TimeData)
-	OptionalFunc(r, &x.Colour, r.RGB)
+	OptionalFuncIO(r, &x.Colour, cameraFadeColour)
+}
+
+// cameraFadeColour reads/writes the colour of a CameraInstructionFade as three float32s, like IO.RGB. Unlike
+// Reader.RGB, the components read are rounded and the colour is read as fully opaque, so that the colour
+// read is equal to the colour written if it was opaque.
+func cameraFadeColour(r IO, x *color.RGBA) {
+	red, green, blue := float32(x.R)/255, float32(x.G)/255, float32(x.B)/255
+	r.Float32(&red)
+	r.Float32(&green)
+	r.Float32(&blue)
+	if _, ok := r.(Reads); ok {
+		*x = color.RGBA{
+			R: uint8(math.Round(float64(red) * 255)),
+			G: uint8(math.Round(float64(green) * 255)),
+			B: uint8(math.Round(float64(blue) * 255)),
+			A: 0xff,
+		}
+	}
 }
 
 // CameraInstructionTarget represents a camera instruction that targets a specific entity.
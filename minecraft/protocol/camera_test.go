@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+// TestCameraFadeColourRoundTrip checks that every opaque colour of a camera fade is read back unchanged.
+// Components are written as float32s divided by 255, which, multiplied by 255 again, are not always whole
+// numbers, so they must be rounded rather than truncated when read.
+func TestCameraFadeColourRoundTrip(t *testing.T) {
+	for v := 0; v <= 0xff; v++ {
+		want := color.RGBA{R: uint8(v), G: uint8(0xff - v), B: uint8(v / 2), A: 0xff}
+		buf := bytes.NewBuffer(nil)
+		written := want
+		cameraFadeColour(NewWriter(buf, 0), &written)
+		if written != want {
+			t.Fatalf("expected colour written to be unchanged, got %v", written)
+		}
+
+		var got color.RGBA
+		cameraFadeColour(NewReader(buf, 0, false), &got)
+		if got != want {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// compressedBiomesPrefix is the prefix of biome definitions compressed using a string dictionary.
+var compressedBiomesPrefix = []byte("COMPRESSED")
+
+// maxBiomeDefinitionsSize is the maximum size of biome definitions once restored from their compressed form.
+// Every index into the dictionary expands to a full string, so without a limit, little data could restore to
+// an amount of data that exhausts the memory available. The biome definitions sent by vanilla servers
+// restore to well below a megabyte.
+const maxBiomeDefinitionsSize = 32 * 1024 * 1024
+
+// DecodeBiomeDefinitions decodes the serialised biome definitions of a CompressedBiomeDefinitionList packet,
+// which was sent instead of the BiomeDefinitionList packet by versions before 1.21.80. The packet has the
+// ID 301 and holds the data passed as a byte slice prefixed with its varuint32 length.
+// The data is an NBT compound in the network little endian encoding, which maps the names of biomes to their
+// definitions. The later of these versions compress it by prefixing it with 'COMPRESSED' and replacing
+// strings in the NBT with indices into a dictionary of strings. Data of both forms may be passed to
+// DecodeBiomeDefinitions: The form is detected using the prefix.
+func DecodeBiomeDefinitions(data []byte) (map[string]map[string]any, error) {
+	if bytes.HasPrefix(data, compressedBiomesPrefix) {
+		var err error
+		if data, err = decompressBiomeDefinitions(data[len(compressedBiomesPrefix):]); err != nil {
+			return nil, fmt.Errorf("decompress biome definitions: %w", err)
+		}
+	}
+	var m map[string]map[string]any
+	if err := nbt.UnmarshalEncoding(data, &m, nbt.NetworkLittleEndian); err != nil {
+		return nil, fmt.Errorf("decode biome definitions: %w", err)
+	}
+	return m, nil
+}
+
+// decompressBiomeDefinitions restores the NBT of biome definitions compressed using a string dictionary. The
+// data starts with the dictionary, which is followed by the NBT in which the byte 0xff introduces the
+// little endian int16 index of a string in the dictionary. An index of -1 stands for the byte 0xff itself.
+// An error is returned if the data restored would exceed maxBiomeDefinitionsSize.
+func decompressBiomeDefinitions(data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(data)
+	var size int16
+	if err := binary.Read(buf, binary.LittleEndian, &size); err != nil {
+		return nil, fmt.Errorf("read dictionary size: %w", err)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("invalid dictionary size %v", size)
+	}
+	dictionary := make([][]byte, size)
+	for i := range dictionary {
+		var length uint32
+		if err := Varuint32(buf, &length); err != nil {
+			return nil, fmt.Errorf("read dictionary string length: %w", err)
+		}
+		if int(length) > buf.Len() {
+			return nil, fmt.Errorf("dictionary string of %v bytes: %w", length, io.ErrUnexpectedEOF)
+		}
+		dictionary[i] = buf.Next(int(length))
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, min(buf.Len()*2, maxBiomeDefinitionsSize)))
+	for buf.Len() != 0 {
+		b, _ := buf.ReadByte()
+		if b != 0xff {
+			out.WriteByte(b)
+			continue
+		}
+		var index int16
+		if err := binary.Read(buf, binary.LittleEndian, &index); err != nil {
+			return nil, fmt.Errorf("read dictionary index: %w", err)
+		}
+		if index == -1 {
+			out.WriteByte(b)
+			continue
+		}
+		if index < 0 || int(index) >= len(dictionary) {
+			return nil, fmt.Errorf("dictionary index %v out of range for dictionary of %v strings", index, len(dictionary))
+		}
+		// Strings are written as they are in the network little endian encoding: Prefixed with a varuint32
+		// length.
+		_ = WriteVaruint32(out, uint32(len(dictionary[index])))
+		out.Write(dictionary[index])
+		if out.Len() > maxBiomeDefinitionsSize {
+			return nil, fmt.Errorf("biome definitions exceed maximum size of %v bytes", maxBiomeDefinitionsSize)
+		}
+	}
+	return out.Bytes(), nil
+}
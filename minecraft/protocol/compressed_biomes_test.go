@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// compressBiomeDefinitions compresses the NBT passed like decompressBiomeDefinitions expects, replacing every
+// occurrence of the strings in the dictionary passed with an index into it.
+func compressBiomeDefinitions(data []byte, dictionary []string) []byte {
+	data = bytes.ReplaceAll(data, []byte{0xff}, []byte{0xff, 0xff, 0xff})
+	buf := bytes.NewBuffer(compressedBiomesPrefix[:len(compressedBiomesPrefix):len(compressedBiomesPrefix)])
+	buf.Write([]byte{byte(len(dictionary)), byte(len(dictionary) >> 8)})
+	for i, s := range dictionary {
+		_ = WriteVaruint32(buf, uint32(len(s)))
+		buf.WriteString(s)
+
+		encoded := bytes.NewBuffer(nil)
+		_ = WriteVaruint32(encoded, uint32(len(s)))
+		encoded.WriteString(s)
+		data = bytes.ReplaceAll(data, encoded.Bytes(), []byte{0xff, byte(i), byte(i >> 8)})
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func TestDecodeBiomeDefinitions(t *testing.T) {
+	definitions := map[string]map[string]any{
+		"plains": {"temperature": float32(0.8), "downfall": float32(0.4), "tags": []any{"plains", "overworld"}},
+		"desert": {"temperature": float32(2), "downfall": float32(0), "tags": []any{"desert", "overworld"}},
+	}
+	data, err := nbt.MarshalEncoding(definitions, nbt.NetworkLittleEndian)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"uncompressed", data},
+		{"compressed without dictionary", compressBiomeDefinitions(data, nil)},
+		{"compressed", compressBiomeDefinitions(data, []string{"overworld", "temperature", "downfall", "tags"})},
+	}
+	for _, test := range tests {
+		m, err := DecodeBiomeDefinitions(test.data)
+		if err != nil {
+			t.Errorf("%v: decode: %v", test.name, err)
+			continue
+		}
+		if len(m) != 2 || m["plains"]["temperature"] != float32(0.8) || m["desert"]["tags"].([]any)[1] != "overworld" {
+			t.Errorf("%v: unexpected biome definitions %v", test.name, m)
+		}
+	}
+}
+
+func TestDecodeBiomeDefinitionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"missing dictionary size", []byte("COMPRESSED\x01")},
+		{"negative dictionary size", []byte("COMPRESSED\xff\xff")},
+		{"truncated dictionary string", []byte("COMPRESSED\x01\x00\x05ab")},
+		{"index out of range", []byte("COMPRESSED\x01\x00\x01a\xff\x01\x00")},
+		{"truncated index", []byte("COMPRESSED\x00\x00\xff\x01")},
+	}
+	for _, test := range tests {
+		if _, err := DecodeBiomeDefinitions(test.data); err == nil {
+			t.Errorf("%v: expected error", test.name)
+		}
+	}
+}
+
+func TestDecodeBiomeDefinitionsSizeLimit(t *testing.T) {
+	// A single string of 64KB referenced repeatedly restores to far more than maxBiomeDefinitionsSize.
+	data := compressBiomeDefinitions(nil, []string{string(make([]byte, 64*1024))})
+	data = append(data, bytes.Repeat([]byte{0xff, 0x00, 0x00}, maxBiomeDefinitionsSize/(64*1024)+1)...)
+	if _, err := decompressBiomeDefinitions(data[len(compressedBiomesPrefix):]); err == nil {
+		t.Fatal("expected error for biome definitions exceeding the maximum size")
+	}
+}
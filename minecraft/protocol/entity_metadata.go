@@ -1,5 +1,7 @@
 package protocol
 
+import "sync"
+
 const (
 	EntityDataKeyFlags = iota
 	EntityDataKeyStructuralIntegrity
@@ -280,6 +282,43 @@ const (
 	EntityDataTypeVec3
 )
 
+// RawMetadata is an entity metadata value of a type not modelled by this package. It may be set to an
+// EntityMetadata map to write a value of a newer type, which is written verbatim as the Type followed by the
+// Data. Because entity metadata values are not prefixed with their length, values of unknown types cannot
+// be skipped when reading unless the type was registered using RegisterEntityDataType, in which case the
+// value is read as RawMetadata, so that it is forwarded unchanged.
+type RawMetadata struct {
+	// Type is the entity data type of the value, which is written before the Data.
+	Type uint32
+	// Data is the encoded value, excluding its key and type.
+	Data []byte
+}
+
+var (
+	// entityDataTypesMu guards entityDataTypes, so that types may be registered while entity metadata is read.
+	entityDataTypesMu sync.RWMutex
+	entityDataTypes   = map[uint32]func(r *Reader){}
+)
+
+// RegisterEntityDataType registers an entity data type not modelled by this package, so that values of the
+// type are read as RawMetadata by Reader.EntityMetadata rather than failing to read the entity metadata
+// altogether. The function passed reads a value of the type from the Reader passed, for example by calling
+// Reader.Varint32, and the bytes it reads are stored in the RawMetadata as they are. Registering one of the
+// types modelled by this package has no effect. RegisterEntityDataType is safe for concurrent use.
+func RegisterEntityDataType(dataType uint32, read func(r *Reader)) {
+	entityDataTypesMu.Lock()
+	defer entityDataTypesMu.Unlock()
+	entityDataTypes[dataType] = read
+}
+
+// entityDataType returns the function registered using RegisterEntityDataType for the data type passed.
+func entityDataType(dataType uint32) (func(r *Reader), bool) {
+	entityDataTypesMu.RLock()
+	defer entityDataTypesMu.RUnlock()
+	read, ok := entityDataTypes[dataType]
+	return read, ok
+}
+
 // EntityMetadata represents a map that holds metadata associated with an entity. The data held in the map depends on
 // the entity and varies on a per-entity basis.
 type EntityMetadata map[uint32]any
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// testEntityDataType is an entity data type not modelled by this package, registered in tests to read a
+// string followed by a varint32.
+const testEntityDataType = 0x40
+
+func TestEntityMetadataRawRoundTrip(t *testing.T) {
+	RegisterEntityDataType(testEntityDataType, func(r *Reader) {
+		var s string
+		var v int32
+		r.String(&s)
+		r.Varint32(&v)
+	})
+	t.Cleanup(func() {
+		entityDataTypesMu.Lock()
+		delete(entityDataTypes, testEntityDataType)
+		entityDataTypesMu.Unlock()
+	})
+
+	want := map[uint32]any{
+		EntityDataKeyFlags:            int64(1 << 5),
+		EntityDataKeyName:             "name",
+		EntityDataKeyVariant:          int32(3),
+		EntityDataKeyScale:            float32(1.5),
+		EntityDataKeyAttachedPosition: mgl32.Vec3{1, 2, 3},
+		EntityDataKeyBedPosition:      BlockPos{4, -5, 6},
+		// A string of 3 bytes followed by the varint32 -2.
+		200: RawMetadata{Type: testEntityDataType, Data: []byte{3, 'a', 'b', 'c', 0x03}},
+	}
+	buf := bytes.NewBuffer(nil)
+	NewWriter(buf, testShieldID).EntityMetadata(&want)
+	data := append([]byte(nil), buf.Bytes()...)
+
+	var got map[uint32]any
+	NewReader(buf, testShieldID, false).EntityMetadata(&got)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%v bytes left after reading entity metadata", buf.Len())
+	}
+
+	// Writing the metadata read again must produce exactly the same data, so that it is forwarded unchanged.
+	buf.Reset()
+	NewWriter(buf, testShieldID).EntityMetadata(&got)
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("expected %x after writing metadata read, got %x", data, buf.Bytes())
+	}
+}
+
+func TestEntityMetadataUnknownType(t *testing.T) {
+	m := map[uint32]any{1: RawMetadata{Type: 0x41, Data: []byte{1, 2, 3}}}
+	buf := bytes.NewBuffer(nil)
+	NewWriter(buf, testShieldID).EntityMetadata(&m)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected reading a value of an unregistered type to fail")
+		}
+	}()
+	var got map[uint32]any
+	NewReader(buf, testShieldID, false).EntityMetadata(&got)
+}
@@ -120,6 +120,13 @@ func FuncSliceUint32Length[T any, S ~*[]T](r IO, x S, f func(*T)) {
 	FuncSliceOfLen(r, count, x, f)
 }
 
+// FuncSliceVarint32Length reads/writes a slice of T using function f with a varint32 length prefix.
+func FuncSliceVarint32Length[T any, S ~*[]T](r IO, x S, f func(*T)) {
+	count := int32(len(*x))
+	r.Varint32(&count)
+	FuncSliceOfLen(r, uint32(count), x, f)
+}
+
 // FuncSlice reads/writes a slice of T using function f with a varuint32 length prefix.
 func FuncSlice[T any, S ~*[]T](r IO, x S, f func(*T)) {
 	count := uint32(len(*x))
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// ItemEncoding is the wire format used by a Reader or Writer to encode item stacks.
+type ItemEncoding uint8
+
+const (
+	// ItemEncodingCurrent is the encoding of item stacks used by the current version of the game, in which
+	// item stacks hold a block runtime ID and their extra data is prefixed with its length. It is the default
+	// encoding of a Reader and Writer.
+	ItemEncodingCurrent ItemEncoding = iota
+	// ItemEncodingLegacy is the encoding of item stacks used before 1.16.220 (protocol 431). Legacy item
+	// stacks combine their count and metadata value into a single varint, hold no block runtime ID and
+	// encode their NBT using the network little endian encoding. Item instances are prefixed with their
+	// stack network ID, regardless of whether it is 0. A Protocol translating packets for older clients may
+	// set this encoding to the Reader and Writer it returns.
+	ItemEncodingLegacy
+)
+
+// SetItemEncoding sets the ItemEncoding used to read item stacks and item instances. By default,
+// ItemEncodingCurrent is used.
+func (r *Reader) SetItemEncoding(e ItemEncoding) {
+	r.itemEncoding = e
+}
+
+// ItemEncoding returns the ItemEncoding used to read item stacks and item instances.
+func (r *Reader) ItemEncoding() ItemEncoding {
+	return r.itemEncoding
+}
+
+// SetItemEncoding sets the ItemEncoding used to write item stacks and item instances. By default,
+// ItemEncodingCurrent is used.
+func (w *Writer) SetItemEncoding(e ItemEncoding) {
+	w.itemEncoding = e
+}
+
+// ItemEncoding returns the ItemEncoding used to write item stacks and item instances.
+func (w *Writer) ItemEncoding() ItemEncoding {
+	return w.itemEncoding
+}
+
+// legacyItem reads an ItemStack x encoded using ItemEncodingLegacy from the underlying buffer.
+func (r *Reader) legacyItem(x *ItemStack) {
+	x.NBTData = make(map[string]any)
+	r.Varint32(&x.NetworkID)
+	if x.NetworkID == 0 {
+		// The item was air, so there is no more data we should read for the item instance.
+		x.MetadataValue, x.Count, x.BlockRuntimeID, x.CanBePlacedOn, x.CanBreak = 0, 0, 0, nil, nil
+		return
+	}
+	var aux int32
+	r.Varint32(&aux)
+	x.MetadataValue, x.Count, x.BlockRuntimeID = uint32(aux>>8), uint16(aux&0xff), 0
+
+	var length int16
+	r.Int16(&length)
+	if length == -1 {
+		var version uint8
+		r.Uint8(&version)
+
+		switch version {
+		case 1:
+			r.NBT(&x.NBTData, nbt.NetworkLittleEndian)
+		default:
+			r.UnknownEnumOption(version, "item user data version")
+			return
+		}
+	} else if length != 0 {
+		r.InvalidValue(length, "item user data length", "must be 0 or -1")
+	}
+
+	FuncSliceVarint32Length(r, &x.CanBePlacedOn, r.String)
+	FuncSliceVarint32Length(r, &x.CanBreak, r.String)
+
+	if x.NetworkID == r.shieldID {
+		var blockingTick int64
+		r.Varint64(&blockingTick)
+	}
+}
+
+// legacyItem writes an ItemStack x using ItemEncodingLegacy to the underlying buffer. The BlockRuntimeID of
+// the ItemStack is not written.
+func (w *Writer) legacyItem(x *ItemStack) {
+	w.Varint32(&x.NetworkID)
+	if x.NetworkID == 0 {
+		// The item was air, so there's no more data to follow. Return immediately.
+		return
+	}
+	aux := int32(x.MetadataValue<<8) | int32(x.Count&0xff)
+	w.Varint32(&aux)
+
+	var length int16
+	if len(x.NBTData) != 0 {
+		length = int16(-1)
+		version := uint8(1)
+
+		w.Int16(&length)
+		w.Uint8(&version)
+		w.NBT(&x.NBTData, nbt.NetworkLittleEndian)
+	} else {
+		w.Int16(&length)
+	}
+
+	FuncSliceVarint32Length(w, &x.CanBePlacedOn, w.String)
+	FuncSliceVarint32Length(w, &x.CanBreak, w.String)
+
+	if x.NetworkID == w.shieldID {
+		var blockingTick int64
+		w.Varint64(&blockingTick)
+	}
+}
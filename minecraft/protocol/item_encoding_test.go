@@ -0,0 +1,109 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// itemEncodingTests holds item stacks that are written and read using both item encodings. Items written
+// using ItemEncodingLegacy do not hold a block runtime ID, so it is only set for items that are not expected
+// to round trip using that encoding.
+var itemEncodingTests = []struct {
+	name string
+	item ItemStack
+}{
+	{"air", ItemStack{NBTData: map[string]any{}}},
+	{"plain", ItemStack{ItemType: ItemType{NetworkID: 5, MetadataValue: 2}, Count: 3, NBTData: map[string]any{}, CanBePlacedOn: []string{}, CanBreak: []string{}}},
+	{"nbt", ItemStack{ItemType: ItemType{NetworkID: -12, MetadataValue: 1}, Count: 64, NBTData: map[string]any{"Damage": int32(4), "display": map[string]any{"Name": "Sword"}}, CanBePlacedOn: []string{}, CanBreak: []string{}}},
+	{"adventure", ItemStack{ItemType: ItemType{NetworkID: 300}, Count: 1, NBTData: map[string]any{}, CanBePlacedOn: []string{"minecraft:stone"}, CanBreak: []string{"minecraft:dirt", "minecraft:grass"}}},
+	{"shield", ItemStack{ItemType: ItemType{NetworkID: testShieldID}, Count: 1, NBTData: map[string]any{}, CanBePlacedOn: []string{}, CanBreak: []string{}}},
+}
+
+// testShieldID is the shield ID passed to Readers and Writers in tests.
+const testShieldID = 355
+
+func TestItemEncodingRoundTrip(t *testing.T) {
+	for _, encoding := range []ItemEncoding{ItemEncodingCurrent, ItemEncodingLegacy} {
+		for _, test := range itemEncodingTests {
+			want := test.item
+			if encoding == ItemEncodingCurrent && want.NetworkID != 0 {
+				want.BlockRuntimeID = 7
+			}
+			buf := bytes.NewBuffer(nil)
+			w := NewWriter(buf, testShieldID)
+			w.SetItemEncoding(encoding)
+			w.Item(&want)
+
+			var got ItemStack
+			r := NewReader(buf, testShieldID, false)
+			r.SetItemEncoding(encoding)
+			r.Item(&got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%v (encoding %v): expected %+v, got %+v", test.name, encoding, want, got)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%v (encoding %v): %v bytes left after reading item", test.name, encoding, buf.Len())
+			}
+		}
+	}
+}
+
+func TestItemInstanceEncodingRoundTrip(t *testing.T) {
+	for _, encoding := range []ItemEncoding{ItemEncodingCurrent, ItemEncodingLegacy} {
+		for _, test := range itemEncodingTests[1:] {
+			for _, stackNetworkID := range []int32{0, 1, 42} {
+				want := ItemInstance{StackNetworkID: stackNetworkID, Stack: test.item}
+				buf := bytes.NewBuffer(nil)
+				w := NewWriter(buf, testShieldID)
+				w.SetItemEncoding(encoding)
+				w.ItemInstance(&want)
+
+				var got ItemInstance
+				r := NewReader(buf, testShieldID, false)
+				r.SetItemEncoding(encoding)
+				r.ItemInstance(&got)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%v (encoding %v): expected %+v, got %+v", test.name, encoding, want, got)
+				}
+			}
+		}
+	}
+}
+
+// TestItemEncodingBytes checks the bytes an item stack is written as using both item encodings. The bytes
+// were derived by hand from the wire format of each encoding.
+func TestItemEncodingBytes(t *testing.T) {
+	item := ItemStack{ItemType: ItemType{NetworkID: 5, MetadataValue: 2}, BlockRuntimeID: 7, Count: 3}
+	tests := []struct {
+		encoding ItemEncoding
+		want     []byte
+	}{
+		{ItemEncodingCurrent, []byte{
+			0x0a,       // Network ID (varint32 5)
+			0x03, 0x00, // Count (uint16 3)
+			0x02,       // Metadata value (varuint32 2)
+			0x0e,       // Block runtime ID (varint32 7)
+			0x0a,       // Length of extra data (varuint32 10)
+			0x00, 0x00, // Item user data length (int16 0)
+			0x00, 0x00, 0x00, 0x00, // Can be placed on count (uint32 0)
+			0x00, 0x00, 0x00, 0x00, // Can break count (uint32 0)
+		}},
+		{ItemEncodingLegacy, []byte{
+			0x0a,       // Network ID (varint32 5)
+			0x86, 0x08, // Metadata value << 8 | count (varint32 515)
+			0x00, 0x00, // Item user data length (int16 0)
+			0x00, // Can be placed on count (varint32 0)
+			0x00, // Can break count (varint32 0)
+		}},
+	}
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		w := NewWriter(buf, testShieldID)
+		w.SetItemEncoding(test.encoding)
+		w.Item(&item)
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Errorf("encoding %v: expected %x, got %x", test.encoding, test.want, buf.Bytes())
+		}
+	}
+}
@@ -59,6 +59,10 @@ func lookupStackRequestActionType(x StackRequestAction, id *uint8) bool {
 		*id = StackRequestActionConsume
 	case *CreateStackRequestAction:
 		*id = StackRequestActionCreate
+	case *PlaceInContainerStackRequestAction:
+		*id = StackRequestActionPlaceInContainer
+	case *TakeOutContainerStackRequestAction:
+		*id = StackRequestActionTakeOutContainer
 	case *LabTableCombineStackRequestAction:
 		*id = StackRequestActionLabTableCombine
 	case *BeaconPaymentStackRequestAction:
@@ -35,6 +35,14 @@ type IdentityData struct {
 	TitleID string `json:"titleId,omitempty"`
 }
 
+// OfflineUUID returns a UUID derived from the display name passed. It may be used as a stable identity for
+// players that are not logged into XBOX Live, as the UUID remains the same for as long as the player uses the
+// same display name. Note that the display name of an unauthenticated player is not verified, so anyone may
+// join using the OfflineUUID of another player.
+func OfflineUUID(displayName string) uuid.UUID {
+	return uuid.NewMD5(uuid.Nil, []byte("OfflinePlayer:"+displayName))
+}
+
 // checkOfflineUsername is used to check if a username is valid for normal Minecraft client,
 // it validates usernames only for unauthenticated clients.
 var checkOfflineUsername = regexp.MustCompile(`[ \p{L}]`).MatchString
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -70,6 +71,32 @@ func init() {
 // mojangKey holds the parsed Mojang ecdsa.PublicKey.
 var mojangKey = new(ecdsa.PublicKey)
 
+var (
+	// ErrChainInvalid is returned by Parse if the certificate chain of a login request is malformed or if
+	// one of its tokens is not signed by the key of the token before it.
+	ErrChainInvalid = errors.New("login chain invalid")
+	// ErrChainUntrusted is returned by Parse if the certificate chain of a login request is valid, but claims
+	// data that only a chain signed by Mojang may hold, such as an XUID. A server requiring XBOX Live
+	// authentication may also return it for chains not signed by Mojang.
+	ErrChainUntrusted = errors.New("login chain untrusted")
+	// ErrTokenExpired is returned by Parse if one of the tokens of a login request has expired or is not yet
+	// valid.
+	ErrTokenExpired = errors.New("login token expired")
+	// ErrClientDataInvalid is returned by Parse if the client data token of a login request could not be
+	// verified or if the client data it holds is invalid.
+	ErrClientDataInvalid = errors.New("client data invalid")
+)
+
+// validateErr returns an error for the validation of token i that failed with the error passed. The error
+// returned wraps ErrTokenExpired if the token was not valid at the current time, or ErrChainInvalid if
+// the token was invalid otherwise.
+func validateErr(i int, err error) error {
+	if errors.Is(err, jwt.ErrExpired) || errors.Is(err, jwt.ErrNotValidYet) || errors.Is(err, jwt.ErrIssuedInTheFuture) {
+		return fmt.Errorf("%w: validate token %v: %w", ErrTokenExpired, i, err)
+	}
+	return fmt.Errorf("%w: validate token %v: %w", ErrChainInvalid, i, err)
+}
+
 // AuthResult is returned by a call to Parse. It holds the ecdsa.PublicKey of the client and a bool that
 // indicates if the player was logged in with XBOX Live.
 type AuthResult struct {
@@ -83,6 +110,8 @@ type AuthResult struct {
 // Parse returns IdentityData and ClientData, of which IdentityData cannot under any circumstance be edited by
 // the client. Rather, it is obtained from an authentication endpoint. The ClientData can, however, be edited
 // freely by the client.
+// If the request could not be verified, the error returned wraps one of ErrChainInvalid, ErrChainUntrusted,
+// ErrTokenExpired or ErrClientDataInvalid, together with the error that caused it.
 func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 	var (
 		iData IdentityData
@@ -92,18 +121,18 @@ func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 	)
 	req, err := parseLoginRequest(request)
 	if err != nil {
-		return iData, cData, res, fmt.Errorf("parse login request: %w", err)
+		return iData, cData, res, fmt.Errorf("%w: parse login request: %w", ErrChainInvalid, err)
 	}
 	tok, err := jwt.ParseSigned(req.Certificate.Chain[0], []jose.SignatureAlgorithm{jose.ES384})
 	if err != nil {
-		return iData, cData, res, fmt.Errorf("parse token 0: %w", err)
+		return iData, cData, res, fmt.Errorf("%w: parse token 0: %w", ErrChainInvalid, err)
 	}
 
 	// The first token holds the client's public key in the x5u (it's self-signed).
 	//lint:ignore S1005 Double assignment is done explicitly to prevent panics.
 	raw, _ := tok.Headers[0].ExtraHeaders["x5u"]
 	if err := parseAsKey(raw, key); err != nil {
-		return iData, cData, res, fmt.Errorf("parse x5u: %w", err)
+		return iData, cData, res, fmt.Errorf("%w: parse x5u: %w", ErrChainInvalid, err)
 	}
 
 	var identityClaims identityClaims
@@ -114,43 +143,43 @@ func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 	case 1:
 		// Player was not authenticated with XBOX Live, meaning the one token in here is self-signed.
 		if err := parseFullClaim(req.Certificate.Chain[0], key, &identityClaims); err != nil {
-			return iData, cData, res, err
+			return iData, cData, res, fmt.Errorf("%w: parse token 0: %w", ErrChainInvalid, err)
 		}
 		if err := identityClaims.Validate(jwt.Expected{Time: t}); err != nil {
-			return iData, cData, res, fmt.Errorf("validate token 0: %w", err)
+			return iData, cData, res, validateErr(0, err)
 		}
 	case 3:
 		// Player was (or should be) authenticated with XBOX Live, meaning the chain is exactly 3 tokens
 		// long.
 		var c jwt.Claims
 		if err := parseFullClaim(req.Certificate.Chain[0], key, &c); err != nil {
-			return iData, cData, res, fmt.Errorf("parse token 0: %w", err)
+			return iData, cData, res, fmt.Errorf("%w: parse token 0: %w", ErrChainInvalid, err)
 		}
 		if err := c.Validate(jwt.Expected{Time: t}); err != nil {
-			return iData, cData, res, fmt.Errorf("validate token 0: %w", err)
+			return iData, cData, res, validateErr(0, err)
 		}
 		authenticated = bytes.Equal(key.X.Bytes(), mojangKey.X.Bytes()) && bytes.Equal(key.Y.Bytes(), mojangKey.Y.Bytes())
 
 		if err := parseFullClaim(req.Certificate.Chain[1], key, &c); err != nil {
-			return iData, cData, res, fmt.Errorf("parse token 1: %w", err)
+			return iData, cData, res, fmt.Errorf("%w: parse token 1: %w", ErrChainInvalid, err)
 		}
 		if err := c.Validate(jwt.Expected{Time: t, Issuer: iss}); err != nil {
-			return iData, cData, res, fmt.Errorf("validate token 1: %w", err)
+			return iData, cData, res, validateErr(1, err)
 		}
 		if err := parseFullClaim(req.Certificate.Chain[2], key, &identityClaims); err != nil {
-			return iData, cData, res, fmt.Errorf("parse token 2: %w", err)
+			return iData, cData, res, fmt.Errorf("%w: parse token 2: %w", ErrChainInvalid, err)
 		}
 		if err := identityClaims.Validate(jwt.Expected{Time: t, Issuer: iss}); err != nil {
-			return iData, cData, res, fmt.Errorf("validate token 2: %w", err)
+			return iData, cData, res, validateErr(2, err)
 		}
 		if authenticated != (identityClaims.ExtraData.XUID != "") {
-			return iData, cData, res, fmt.Errorf("identity data must have an XUID when logged into XBOX Live only")
+			return iData, cData, res, fmt.Errorf("%w: identity data must have an XUID when logged into XBOX Live only", ErrChainUntrusted)
 		}
 	default:
-		return iData, cData, res, fmt.Errorf("unexpected login chain length %v", len(req.Certificate.Chain))
+		return iData, cData, res, fmt.Errorf("%w: unexpected login chain length %v", ErrChainInvalid, len(req.Certificate.Chain))
 	}
 	if err := parseFullClaim(req.RawToken, key, &cData); err != nil {
-		return iData, cData, res, fmt.Errorf("parse client data: %w", err)
+		return iData, cData, res, fmt.Errorf("%w: parse client data: %w", ErrClientDataInvalid, err)
 	}
 	if strings.Count(cData.ServerAddress, ":") > 1 && cData.ServerAddress[0] != '[' {
 		// IPv6: We can't net.ResolveUDPAddr this directly, because Mojang does
@@ -160,7 +189,7 @@ func Parse(request []byte) (IdentityData, ClientData, AuthResult, error) {
 		cData.ServerAddress = "[" + cData.ServerAddress[:ind] + "]" + cData.ServerAddress[ind:]
 	}
 	if err := cData.Validate(); err != nil {
-		return iData, cData, res, fmt.Errorf("validate client data: %w", err)
+		return iData, cData, res, fmt.Errorf("%w: validate client data: %w", ErrClientDataInvalid, err)
 	}
 	return identityClaims.ExtraData, cData, AuthResult{PublicKey: key, XBOXLiveAuthenticated: authenticated}, nil
 }
@@ -185,6 +214,30 @@ func parseLoginRequest(requestData []byte) (*request, error) {
 	}, nil
 }
 
+// SplitRequest splits the login request passed into the raw JSON holding the certificate chain and the raw
+// JWT holding the ClientData, exactly as they were found in the request. Neither of them is verified. The
+// byte slices returned do not alias the request passed.
+func SplitRequest(request []byte) (chainData, clientData []byte, err error) {
+	buf := bytes.NewBuffer(request)
+	var chainLength int32
+	if err := binary.Read(buf, binary.LittleEndian, &chainLength); err != nil {
+		return nil, nil, fmt.Errorf("read chain length: %w", err)
+	}
+	if chainLength <= 0 || int(chainLength) > buf.Len() {
+		return nil, nil, fmt.Errorf("invalid chain length: %d", chainLength)
+	}
+	chainData = bytes.Clone(buf.Next(int(chainLength)))
+
+	var rawLength int32
+	if err := binary.Read(buf, binary.LittleEndian, &rawLength); err != nil {
+		return nil, nil, fmt.Errorf("read raw token length: %w", err)
+	}
+	if rawLength < 0 || int(rawLength) > buf.Len() {
+		return nil, nil, fmt.Errorf("invalid raw token length: %d", rawLength)
+	}
+	return chainData, bytes.Clone(buf.Next(int(rawLength))), nil
+}
+
 // parseFullClaim parses and verifies a full claim using the ecdsa.PublicKey passed. The key passed is updated
 // if the claim holds an identityPublicKey field.
 // The value v passed is decoded into when reading the claims.
@@ -0,0 +1,96 @@
+package login
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// offlineData returns identity and client data that are valid for a client that is not logged into XBOX
+// Live.
+func offlineData() (IdentityData, ClientData) {
+	identity := IdentityData{Identity: uuid.NewString(), DisplayName: "Steve"}
+	client := ClientData{
+		DeviceOS:          protocol.DeviceWin10,
+		GameVersion:       protocol.CurrentVersion,
+		LanguageCode:      "en_GB",
+		SelfSignedID:      uuid.NewString(),
+		ServerAddress:     "127.0.0.1:19132",
+		SkinID:            "skin",
+		SkinImageWidth:    64,
+		SkinImageHeight:   32,
+		SkinData:          base64.StdEncoding.EncodeToString(make([]byte, 64*32*4)),
+		SkinResourcePatch: base64.StdEncoding.EncodeToString([]byte(`{"geometry": {"default": "geometry.humanoid.custom"}}`)),
+	}
+	return identity, client
+}
+
+func TestEncodeOfflineParse(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	identity, client := offlineData()
+	if err := identity.Validate(); err != nil {
+		t.Fatalf("expected identity data to be valid, got %v", err)
+	}
+	for _, legacy := range []bool{false, true} {
+		iData, cData, res, err := Parse(EncodeOffline(identity, client, key, legacy))
+		if err != nil {
+			t.Fatalf("legacy=%v: parse: %v", legacy, err)
+		}
+		if iData != identity {
+			t.Errorf("legacy=%v: expected identity data %+v, got %+v", legacy, identity, iData)
+		}
+		if cData.SelfSignedID != client.SelfSignedID || cData.SkinData != client.SkinData || cData.ServerAddress != client.ServerAddress {
+			t.Errorf("legacy=%v: expected client data %+v, got %+v", legacy, client, cData)
+		}
+		if res.XBOXLiveAuthenticated {
+			t.Errorf("legacy=%v: expected offline request not to be authenticated by XBOX Live", legacy)
+		}
+		if !res.PublicKey.Equal(&key.PublicKey) {
+			t.Errorf("legacy=%v: expected public key of the request to be the key it was signed with", legacy)
+		}
+	}
+}
+
+func TestEncodeOfflineInvalid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	identity, client := offlineData()
+
+	// The client data is validated by Parse.
+	invalid := client
+	invalid.SkinID = ""
+	if _, _, _, err := Parse(EncodeOffline(identity, invalid, key, false)); !errors.Is(err, ErrClientDataInvalid) {
+		t.Errorf("expected ErrClientDataInvalid for invalid client data, got %v", err)
+	}
+
+	// Changing the signed client data must invalidate the request.
+	chainData, clientData, err := SplitRequest(EncodeOffline(identity, client, key, false))
+	if err != nil {
+		t.Fatalf("split request: %v", err)
+	}
+	clientData[len(clientData)-1] ^= 1
+	if _, _, _, err := Parse(joinRequest(chainData, clientData)); !errors.Is(err, ErrClientDataInvalid) {
+		t.Errorf("expected ErrClientDataInvalid for tampered client data, got %v", err)
+	}
+}
+
+// joinRequest joins the chain and client data passed into a login request, like they are split by
+// SplitRequest.
+func joinRequest(chainData, clientData []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(chainData)))
+	b = append(b, chainData...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(clientData)))
+	return append(b, clientData...)
+}
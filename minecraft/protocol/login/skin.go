@@ -0,0 +1,105 @@
+package login
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// Skin assembles a protocol.Skin from the skin fields of the ClientData, decoding the base64 encoded images,
+// geometry and resource patch. The protocol.Skin returned may be sent to other players, for example in a
+// packet.PlayerSkin or packet.PlayerList. An error is returned if one of the fields is not valid base64 or if
+// the size of an image does not match the dimensions declared for it.
+func (data ClientData) Skin() (protocol.Skin, error) {
+	skin := protocol.Skin{
+		SkinID:                   data.SkinID,
+		PlayFabID:                data.PlayFabID,
+		SkinImageWidth:           uint32(data.SkinImageWidth),
+		SkinImageHeight:          uint32(data.SkinImageHeight),
+		CapeImageWidth:           uint32(data.CapeImageWidth),
+		CapeImageHeight:          uint32(data.CapeImageHeight),
+		PremiumSkin:              data.PremiumSkin,
+		PersonaSkin:              data.PersonaSkin,
+		PersonaCapeOnClassicSkin: data.CapeOnClassicSkin,
+		PrimaryUser:              true,
+		CapeID:                   data.CapeID,
+		FullID:                   data.SkinID + data.CapeID,
+		SkinColour:               data.SkinColour,
+		ArmSize:                  data.ArmSize,
+		Trusted:                  data.TrustedSkin,
+		OverrideAppearance:       data.OverrideSkin,
+	}
+	var err error
+	if skin.SkinData, err = decodeImage(data.SkinData, data.SkinImageWidth, data.SkinImageHeight); err != nil {
+		return skin, fmt.Errorf("decode SkinData: %w", err)
+	}
+	if skin.CapeData, err = decodeImage(data.CapeData, data.CapeImageWidth, data.CapeImageHeight); err != nil {
+		return skin, fmt.Errorf("decode CapeData: %w", err)
+	}
+	if skin.SkinResourcePatch, err = base64.StdEncoding.DecodeString(data.SkinResourcePatch); err != nil {
+		return skin, fmt.Errorf("decode SkinResourcePatch: %w", err)
+	}
+	if skin.SkinGeometry, err = base64.StdEncoding.DecodeString(data.SkinGeometry); err != nil {
+		return skin, fmt.Errorf("decode SkinGeometry: %w", err)
+	}
+	if skin.GeometryDataEngineVersion, err = base64.StdEncoding.DecodeString(data.SkinGeometryVersion); err != nil {
+		return skin, fmt.Errorf("decode SkinGeometryVersion: %w", err)
+	}
+	if skin.AnimationData, err = base64.StdEncoding.DecodeString(data.SkinAnimationData); err != nil {
+		return skin, fmt.Errorf("decode SkinAnimationData: %w", err)
+	}
+
+	skin.Animations = make([]protocol.SkinAnimation, 0, len(data.AnimatedImageData))
+	for i, anim := range data.AnimatedImageData {
+		if anim.Type < 0 || anim.Type > 3 {
+			return skin, fmt.Errorf("animation %v: invalid animation type %v", i, anim.Type)
+		}
+		img, err := decodeImage(anim.Image, anim.ImageWidth, anim.ImageHeight)
+		if err != nil {
+			return skin, fmt.Errorf("animation %v: decode Image: %w", i, err)
+		}
+		skin.Animations = append(skin.Animations, protocol.SkinAnimation{
+			ImageWidth:     uint32(anim.ImageWidth),
+			ImageHeight:    uint32(anim.ImageHeight),
+			ImageData:      img,
+			AnimationType:  uint32(anim.Type),
+			FrameCount:     float32(anim.Frames),
+			ExpressionType: uint32(anim.AnimationExpression),
+		})
+	}
+	skin.PersonaPieces = make([]protocol.PersonaPiece, 0, len(data.PersonaPieces))
+	for _, piece := range data.PersonaPieces {
+		skin.PersonaPieces = append(skin.PersonaPieces, protocol.PersonaPiece{
+			PieceID:   piece.PieceID,
+			PieceType: piece.PieceType,
+			PackID:    piece.PackID,
+			Default:   piece.Default,
+			ProductID: piece.ProductID,
+		})
+	}
+	skin.PieceTintColours = make([]protocol.PersonaPieceTintColour, 0, len(data.PieceTintColours))
+	for _, tint := range data.PieceTintColours {
+		skin.PieceTintColours = append(skin.PieceTintColours, protocol.PersonaPieceTintColour{
+			PieceType: tint.PieceType,
+			Colours:   tint.Colours[:],
+		})
+	}
+	return skin, nil
+}
+
+// decodeImage decodes the base64 encoded RGBA image passed and checks if its size matches the width and
+// height passed.
+func decodeImage(image string, width, height int) ([]byte, error) {
+	if width < 0 || height < 0 {
+		return nil, fmt.Errorf("invalid dimensions %vx%v", width, height)
+	}
+	b, err := base64.StdEncoding.DecodeString(image)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64 data: %w", err)
+	}
+	if len(b) != width*height*4 {
+		return nil, fmt.Errorf("image of %v bytes does not match dimensions %vx%v (%v bytes)", len(b), width, height, width*height*4)
+	}
+	return b, nil
+}
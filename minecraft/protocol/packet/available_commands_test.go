@@ -0,0 +1,97 @@
+package packet
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestAvailableCommands(t *testing.T) {
+	testRoundTrip(t, "command tree", &AvailableCommands{
+		EnumValues:              []string{"survival", "creative", "adventure", "spectator", "true", "false"},
+		ChainedSubcommandValues: []string{"as", "at", "run"},
+		Suffixes:                []string{"L"},
+		Enums: []protocol.CommandEnum{
+			{Type: "GameMode", ValueIndices: []uint{0, 1, 2, 3}},
+			{Type: "Boolean", ValueIndices: []uint{4, 5}},
+		},
+		ChainedSubcommands: []protocol.ChainedSubcommand{{Name: "ExecuteChainedOption_0", Values: []protocol.ChainedSubcommandValue{
+			{Index: 0, Value: protocol.CommandArgTypeTarget},
+			{Index: 2, Value: protocol.CommandArgTypeCommand},
+		}}},
+		// The enum, suffixed and soft enum parameters below refer to the first of the Enums, Suffixes and
+		// DynamicEnums respectively, as the index is OR'd with the flags.
+		Commands: []protocol.Command{
+			{
+				Name: "gamemode", Description: "Sets a player's game mode.", PermissionLevel: 1, AliasesOffset: math.MaxUint32,
+				ChainedSubcommandOffsets: []uint16{},
+				Overloads: []protocol.CommandOverload{{Parameters: []protocol.CommandParameter{
+					{Name: "gameMode", Type: protocol.CommandArgValid | protocol.CommandArgEnum, Options: protocol.ParamOptionCollapseEnum},
+					{Name: "player", Type: protocol.CommandArgValid | protocol.CommandArgTypeTarget, Optional: true},
+				}}},
+			},
+			{
+				Name: "xp", Description: "Adds experience.", PermissionLevel: 1, AliasesOffset: math.MaxUint32,
+				ChainedSubcommandOffsets: []uint16{},
+				Overloads: []protocol.CommandOverload{{Parameters: []protocol.CommandParameter{
+					{Name: "amount", Type: protocol.CommandArgValid | protocol.CommandArgSuffixed},
+				}}},
+			},
+			{
+				Name: "execute", Description: "Executes a command.", PermissionLevel: 1, AliasesOffset: math.MaxUint32,
+				ChainedSubcommandOffsets: []uint16{0},
+				Overloads: []protocol.CommandOverload{{Chaining: true, Parameters: []protocol.CommandParameter{
+					{Name: "function", Type: protocol.CommandArgValid | protocol.CommandArgSoftEnum},
+				}}},
+			},
+		},
+		DynamicEnums: []protocol.DynamicEnum{{Type: "Functions", Values: []string{"a", "b"}}},
+		Constraints:  []protocol.CommandEnumConstraint{{EnumValueIndex: 1, EnumIndex: 0, Constraints: []byte{protocol.CommandEnumConstraintCheatsEnabled}}},
+	})
+}
+
+// TestAvailableCommandsEnumIndices checks that the value indices of enums are written as bytes, uint16s or
+// uint32s depending on the number of enum values, and that indices at the edges of each size round trip.
+func TestAvailableCommandsEnumIndices(t *testing.T) {
+	tests := []struct {
+		values, size int
+	}{
+		{1, 1},
+		{math.MaxUint8, 1},
+		{math.MaxUint8 + 1, 2},
+		{math.MaxUint16, 2},
+		{math.MaxUint16 + 1, 4},
+		{100000, 4},
+	}
+	for _, test := range tests {
+		name := strconv.Itoa(test.values) + " values"
+		values := make([]string, test.values)
+		for i := range values {
+			values[i] = strconv.Itoa(i)
+		}
+		indices := []uint{0, uint(test.values / 2), uint(test.values - 1)}
+		pk := &AvailableCommands{
+			EnumValues:              values,
+			ChainedSubcommandValues: []string{},
+			Suffixes:                []string{},
+			Enums:                   []protocol.CommandEnum{{Type: "Values", ValueIndices: indices}, {Type: "Empty", ValueIndices: []uint{}}},
+			ChainedSubcommands:      []protocol.ChainedSubcommand{},
+			Commands:                []protocol.Command{},
+			DynamicEnums:            []protocol.DynamicEnum{},
+			Constraints:             []protocol.CommandEnumConstraint{},
+		}
+		testRoundTrip(t, name, pk)
+
+		// Writing the enum without indices shrinks the packet by the size of the indices left out.
+		withIndices, withoutIndices := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+		pk.Marshal(protocol.NewWriter(withIndices, 0))
+		pk.Enums[0].ValueIndices = []uint{}
+		pk.Marshal(protocol.NewWriter(withoutIndices, 0))
+		if size := (withIndices.Len() - withoutIndices.Len()) / len(indices); size != test.size {
+			t.Errorf("%v: expected indices of %v bytes, got %v", name, test.size, size)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package packet
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestCameraInstructionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		pk   *CameraInstruction
+	}{
+		{"absent", &CameraInstruction{}},
+		{"present", &CameraInstruction{
+			Set: protocol.Option(protocol.CameraInstructionSet{
+				Preset:                        3,
+				Ease:                          protocol.Option(protocol.CameraEase{Type: protocol.EasingTypeInOutCubic, Duration: 1.5}),
+				Position:                      protocol.Option(mgl32.Vec3{1, 2, 3}),
+				Rotation:                      protocol.Option(mgl32.Vec2{45, 90}),
+				Facing:                        protocol.Option(mgl32.Vec3{4, 5, 6}),
+				ViewOffset:                    protocol.Option(mgl32.Vec2{0.5, 1}),
+				EntityOffset:                  protocol.Option(mgl32.Vec3{0, 1.62, 0}),
+				Default:                       protocol.Option(true),
+				IgnoreStartingValuesComponent: true,
+			}),
+			Clear: protocol.Option(true),
+			Fade: protocol.Option(protocol.CameraInstructionFade{
+				TimeData: protocol.Option(protocol.CameraFadeTimeData{FadeInDuration: 1, WaitDuration: 2, FadeOutDuration: 3}),
+				Colour:   protocol.Option(color.RGBA{R: 0x12, G: 0x80, B: 0xfe, A: 0xff}),
+			}),
+			Target:       protocol.Option(protocol.CameraInstructionTarget{CenterOffset: protocol.Option(mgl32.Vec3{0, 1, 0}), EntityUniqueID: -5}),
+			RemoveTarget: protocol.Option(false),
+			FieldOfView:  protocol.Option(protocol.CameraInstructionFieldOfView{FieldOfView: 70, EaseTime: 0.5, EaseType: protocol.EasingTypeLinear, Clear: true}),
+		}},
+		{"nested absent", &CameraInstruction{
+			Set:    protocol.Option(protocol.CameraInstructionSet{Preset: 1}),
+			Fade:   protocol.Option(protocol.CameraInstructionFade{}),
+			Target: protocol.Option(protocol.CameraInstructionTarget{EntityUniqueID: 1}),
+		}},
+	}
+	for _, test := range tests {
+		testRoundTrip(t, test.name, test.pk)
+	}
+}
+
+func TestCameraPresetsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		pk   *CameraPresets
+	}{
+		{"empty", &CameraPresets{Presets: []protocol.CameraPreset{}}},
+		{"absent", &CameraPresets{Presets: []protocol.CameraPreset{{Name: "minecraft:free"}}}},
+		{"present", &CameraPresets{Presets: []protocol.CameraPreset{{
+			Name:                    "example:orbit",
+			Parent:                  "minecraft:follow_orbit",
+			PosX:                    protocol.Option[float32](1),
+			PosY:                    protocol.Option[float32](2),
+			PosZ:                    protocol.Option[float32](3),
+			RotX:                    protocol.Option[float32](10),
+			RotY:                    protocol.Option[float32](20),
+			RotationSpeed:           protocol.Option[float32](0.5),
+			SnapToTarget:            protocol.Option(true),
+			HorizontalRotationLimit: protocol.Option(mgl32.Vec2{-90, 90}),
+			VerticalRotationLimit:   protocol.Option(mgl32.Vec2{-45, 45}),
+			ContinueTargeting:       protocol.Option(true),
+			TrackingRadius:          protocol.Option[float32](8),
+			ViewOffset:              protocol.Option(mgl32.Vec2{1, 1}),
+			EntityOffset:            protocol.Option(mgl32.Vec3{0, 2, 0}),
+			Radius:                  protocol.Option[float32](5),
+			MinYawLimit:             protocol.Option[float32](-180),
+			MaxYawLimit:             protocol.Option[float32](180),
+			AudioListener:           protocol.Option[byte](protocol.AudioListenerPlayer),
+			PlayerEffects:           protocol.Option(false),
+			AimAssist: protocol.Option(protocol.CameraPresetAimAssist{
+				Preset:     protocol.Option("example:aim"),
+				TargetMode: protocol.Option[int32](protocol.AimAssistTargetModeAngle),
+				Angle:      protocol.Option(mgl32.Vec2{30, 30}),
+				Distance:   protocol.Option[float32](16),
+			}),
+			ControlScheme: protocol.Option[byte](1),
+		}}}},
+	}
+	for _, test := range tests {
+		testRoundTrip(t, test.name, test.pk)
+	}
+}
@@ -2,14 +2,23 @@ package packet
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"math"
+	"runtime"
+	"slices"
+	"strconv"
 	"sync"
 
 	"github.com/golang/snappy"
 	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
 	"github.com/sandertv/gophertunnel/minecraft/internal"
+	"github.com/sandertv/gophertunnel/minecraft/internal/cpu"
 )
 
 // Compression represents a compression algorithm that can compress and decompress data.
@@ -18,10 +27,36 @@ type Compression interface {
 	EncodeCompression() uint16
 	// Compress compresses the given data and returns the compressed data.
 	Compress(decompressed []byte) ([]byte, error)
-	// Decompress decompresses the given data and returns the decompressed data.
+	// Decompress decompresses the given data and returns the decompressed data. If the decompressed data
+	// would exceed limit bytes, a *CompressionError wrapping ErrDecompressedTooLarge is returned.
 	Decompress(compressed []byte, limit int) ([]byte, error)
 }
 
+// StreamDecompressor is implemented by Compressions that are able to decompress data directly into an
+// io.Writer, rather than allocating a new byte slice for the decompressed data. All Compressions in this
+// package implement StreamDecompressor. DecompressTo may be used to decompress data into an io.Writer using
+// any Compression.
+type StreamDecompressor interface {
+	// DecompressTo decompresses the given data and writes the decompressed data to dst. If the decompressed
+	// data would exceed limit bytes, a *CompressionError wrapping ErrDecompressedTooLarge is returned. Note
+	// that data may already have been written to dst when an error is returned.
+	DecompressTo(dst io.Writer, compressed []byte, limit int) error
+}
+
+// AlgorithmDecompressor is implemented by Compressions that resolve the compression algorithm used for every
+// batch individually, such as the Compression returned by NewOnTheFlyCompression.
+type AlgorithmDecompressor interface {
+	// DecompressWithAlgorithm decompresses the given data and returns the decompressed data, together with
+	// the Compression that was used to compress the data. NopCompression is returned if the data was not
+	// compressed.
+	DecompressWithAlgorithm(compressed []byte, limit int) ([]byte, Compression, error)
+}
+
+// ErrDecompressedTooLarge is returned (wrapped in a *CompressionError) by Compression.Decompress if the size
+// of the decompressed data exceeds the limit passed. It may be used to distinguish oversized payloads, such as
+// decompression bombs, from corrupt data.
+var ErrDecompressedTooLarge = errors.New("decompressed size exceeds limit")
+
 var (
 	// NopCompression is an empty implementation that does not compress data.
 	NopCompression nopCompression
@@ -29,25 +64,129 @@ var (
 	// algorithm. This is used by default.
 	FlateCompression flateCompression
 	// SnappyCompression is the implementation of the Snappy compression
-	// algorithm. Snappy currently crashes devices without `avx2`.
+	// algorithm. It uses the Snappy block format, in which the data is
+	// prefixed with its decoded length, without the stream identifier and
+	// framing of the Snappy stream format. This is the format used by the
+	// game. Use NewSnappyStreamCompression to interoperate with peers using
+	// the stream format. Snappy currently crashes devices without `avx2`. Use
+	// SnappyAvailable to check if Snappy may be used.
 	SnappyCompression snappyCompression
+	// ZstdCompression is the implementation of the Zstandard compression
+	// algorithm, using the default encoder level. Vanilla clients do not
+	// support Zstandard, so it should only be used between peers that both
+	// implement it.
+	ZstdCompression = NewZstdCompression(int(zstd.SpeedDefault))
+	// LZ4Compression is the implementation of the LZ4 compression algorithm,
+	// using the LZ4 frame format. Like Zstandard, LZ4 is not supported by
+	// vanilla clients.
+	LZ4Compression lz4Compression
 
 	DefaultCompression Compression = FlateCompression
 )
 
+// NewFlateCompression returns a Flate Compression that compresses data using
+// the compression level passed. The level must be in the range of
+// flate.BestSpeed to flate.BestCompression, or an error is returned.
+// FlateCompression uses a level of 6.
+func NewFlateCompression(level int) (Compression, error) {
+	return NewFlateCompressionWithDict(level, nil)
+}
+
+// NewFlateCompressionWithDict returns a Flate Compression that compresses
+// data using the compression level and preset dictionary passed. A dictionary
+// holding byte sequences common in the data compressed, such as those found
+// in chunk data, may improve the compression ratio. Both ends of a connection
+// must use the same dictionary, or decompression fails. The level must be in
+// the range of flate.BestSpeed to flate.BestCompression, or an error is
+// returned.
+func NewFlateCompressionWithDict(level int, dict []byte) (Compression, error) {
+	if level < flate.BestSpeed || level > flate.BestCompression {
+		return nil, fmt.Errorf("new flate compression: invalid level %v: must be between %v and %v", level, flate.BestSpeed, flate.BestCompression)
+	}
+	dict = slices.Clone(dict)
+	return flateCompression{dict: &dict, compressPool: &sync.Pool{
+		New: func() any {
+			w, _ := flate.NewWriterDict(io.Discard, level, dict)
+			return w
+		},
+	}}, nil
+}
+
+// NewZstdCompression returns a Zstandard Compression that compresses data
+// using the encoder level passed. The level is converted using
+// zstd.EncoderLevelFromZstd, so it follows the levels of the zstd command line
+// tool (1-22), which are mapped to the closest level supported.
+func NewZstdCompression(level int) Compression {
+	l := zstd.EncoderLevelFromZstd(level)
+	return zstdCompression{encodePool: &sync.Pool{
+		New: func() any {
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(l), zstd.WithEncoderConcurrency(1))
+			return w
+		},
+	}}
+}
+
+// NewSnappyStreamCompression returns a Compression that compresses data using
+// the framed Snappy stream format, as opposed to SnappyCompression, which
+// uses the block format. Decoding data of one format using the other fails
+// with a corrupt input error. The Compression returned is registered under
+// CompressionAlgorithmSnappyStream. Like Zstandard, it is not supported by
+// vanilla clients.
+func NewSnappyStreamCompression() Compression {
+	return snappyStreamCompression{}
+}
+
+// NewOnTheFlyCompression returns a Compression that prefixes compressed data with the ID of the algorithm
+// used, as done by the protocol from 1.20.60 onwards. Data is compressed using the Compression passed, while
+// decompression resolves the algorithm from the prefix of each batch. Only batches compressed using the
+// Compression passed, which is the one negotiated with the other end, and batches that are not compressed at
+// all are accepted, so that the other end cannot make use of algorithms that were never negotiated. The
+// Compression returned implements AlgorithmDecompressor, so that the algorithm used by the other end may be
+// obtained.
 func NewOnTheFlyCompression(underlyingCompression Compression) Compression {
-	return onTheFlyCompression{underlyingCompression}
+	return onTheFlyCompression{c: underlyingCompression}
+}
+
+// NewOnTheFlyCompressionWithFallback returns a Compression like NewOnTheFlyCompression, but that attempts
+// to decode batches that cannot be decoded using the algorithm found in their prefix as if they lacked the
+// prefix, as some servers send batches that do. Such batches are decompressed as a whole using the
+// Compression passed, and then decoded without decompression. Because every attempt may decompress up to
+// the limit passed, a batch that fails to decode may cost up to three times as much to decompress. The
+// fallback should therefore only be used to decode data sent by servers, never by a Listener decoding data
+// sent by untrusted clients. Unlike the Compression returned by NewOnTheFlyCompression, batches are not
+// streamed by DecompressTo, as the decompressed data must be validated before it is written.
+func NewOnTheFlyCompressionWithFallback(underlyingCompression Compression) Compression {
+	return onTheFlyCompression{c: underlyingCompression, fallback: true}
 }
 
 type (
 	// nopCompression is an empty implementation that does not compress data.
 	nopCompression struct{}
-	// flateCompression is the implementation of the Flate compression algorithm.
-	flateCompression struct{}
+	// flateCompression is the implementation of the Flate compression algorithm. If compressPool is nil,
+	// flateCompressPool is used, which holds writers with a compression level of 6. The writers in the
+	// compressPool are created with dict as preset dictionary, which is also used for decompression. The
+	// dictionary is held by pointer so that flateCompression remains comparable.
+	flateCompression struct {
+		compressPool *sync.Pool
+		dict         *[]byte
+	}
 	// snappyCompression is the implementation of the Snappy compression algorithm.
 	snappyCompression struct{}
+	// snappyStreamCompression is the implementation of the Snappy compression algorithm using the stream
+	// format.
+	snappyStreamCompression struct{}
+	// zstdCompression is the implementation of the Zstandard compression algorithm. Each instance holds
+	// its own pool of encoders, as the encoder level cannot be changed after creation.
+	zstdCompression struct{ encodePool *sync.Pool }
+	// lz4Compression is the implementation of the LZ4 compression algorithm.
+	lz4Compression struct{}
 	// onTheFlyCompression is the implementation of the both compression algorithms. This is used by default for decoding.
-	onTheFlyCompression struct{ c Compression }
+	// If fallback is true, batches that cannot be decoded using the algorithm found in their prefix are
+	// decoded as if they lacked the prefix.
+	onTheFlyCompression struct {
+		c        Compression
+		fallback bool
+	}
 )
 
 var (
@@ -62,6 +201,25 @@ var (
 			return w
 		},
 	}
+	// zstdDecompressPools holds a *sync.Pool of zstd decoders for every decompression limit used, as
+	// returned by zstdDecompressPool.
+	zstdDecompressPools sync.Map
+	// lz4DecompressPool is a sync.Pool for lz4 frame readers. These are pooled for connections.
+	lz4DecompressPool = sync.Pool{
+		New: func() any { return lz4.NewReader(nil) },
+	}
+	// snappyStreamDecompressPool is a sync.Pool for snappy stream readers. These are pooled for connections.
+	snappyStreamDecompressPool = sync.Pool{
+		New: func() any { return snappy.NewReader(nil) },
+	}
+	// snappyStreamCompressPool is a sync.Pool for snappy stream writers. These are pooled for connections.
+	snappyStreamCompressPool = sync.Pool{
+		New: func() any { return snappy.NewBufferedWriter(nil) },
+	}
+	// lz4CompressPool is a sync.Pool for lz4 frame writers. These are pooled for connections.
+	lz4CompressPool = sync.Pool{
+		New: func() any { return lz4.NewWriter(nil) },
+	}
 )
 
 // EncodeCompression ...
@@ -77,26 +235,41 @@ func (nopCompression) Compress(decompressed []byte) ([]byte, error) {
 // Decompress ...
 func (nopCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
 	if len(compressed) > limit {
-		return nil, fmt.Errorf("nop decompression: size %d exceeds limit %d", len(compressed), limit)
+		return nil, decompressedTooLarge(CompressionAlgorithmNone, len(compressed), limit)
 	}
 	return compressed, nil
 }
 
+// DecompressTo ...
+func (nopCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	if len(compressed) > limit {
+		return decompressedTooLarge(CompressionAlgorithmNone, len(compressed), limit)
+	}
+	if _, err := dst.Write(compressed); err != nil {
+		return fmt.Errorf("write decompressed nop: %w", err)
+	}
+	return nil
+}
+
 // EncodeCompression ...
 func (flateCompression) EncodeCompression() uint16 {
 	return CompressionAlgorithmFlate
 }
 
 // Compress ...
-func (flateCompression) Compress(decompressed []byte) ([]byte, error) {
+func (c flateCompression) Compress(decompressed []byte) ([]byte, error) {
+	pool := c.compressPool
+	if pool == nil {
+		pool = &flateCompressPool
+	}
 	compressed := internal.BufferPool.Get().(*bytes.Buffer)
-	w := flateCompressPool.Get().(*flate.Writer)
+	w := pool.Get().(*flate.Writer)
 
 	defer func() {
 		// Reset the buffer, so we can return it to the buffer pool safely.
 		compressed.Reset()
 		internal.BufferPool.Put(compressed)
-		flateCompressPool.Put(w)
+		pool.Put(w)
 	}()
 
 	w.Reset(compressed)
@@ -113,22 +286,39 @@ func (flateCompression) Compress(decompressed []byte) ([]byte, error) {
 }
 
 // Decompress ...
-func (flateCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
+func (c flateCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
+	// Guess an uncompressed size of 2*len(compressed).
+	decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
+	if err := c.DecompressTo(decompressed, compressed, limit); err != nil {
+		return nil, err
+	}
+	return decompressed.Bytes(), nil
+}
+
+// DecompressTo ...
+func (f flateCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
 	buf := bytes.NewReader(compressed)
 	c := flateDecompressPool.Get().(io.ReadCloser)
 	defer flateDecompressPool.Put(c)
 
-	if err := c.(flate.Resetter).Reset(buf, nil); err != nil {
-		return nil, fmt.Errorf("reset flate: %w", err)
+	var dict []byte
+	if f.dict != nil {
+		dict = *f.dict
+	}
+	// Readers don't keep the dictionary between resets, so the pool may be
+	// shared with compressions that use a different dictionary.
+	if err := c.(flate.Resetter).Reset(buf, dict); err != nil {
+		return fmt.Errorf("reset flate: %w", err)
 	}
 	_ = c.Close()
 
-	// Guess an uncompressed size of 2*len(compressed).
-	decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
-	if _, err := io.Copy(decompressed, io.LimitReader(c, int64(limit))); err != nil {
-		return nil, fmt.Errorf("decompress flate: %w", err)
+	if _, err := io.Copy(dst, io.LimitReader(c, int64(limit))); err != nil {
+		return fmt.Errorf("decompress flate: %w", err)
 	}
-	return decompressed.Bytes(), nil
+	if exceedsLimit(c) {
+		return decompressedTooLarge(CompressionAlgorithmFlate, len(compressed), limit)
+	}
+	return nil
 }
 
 // EncodeCompression ...
@@ -136,6 +326,18 @@ func (snappyCompression) EncodeCompression() uint16 {
 	return CompressionAlgorithmSnappy
 }
 
+// SnappyAvailable reports if SnappyCompression may safely be used on the CPU that the program is running on.
+// Snappy crashes x86 devices that do not support AVX2, so SnappyAvailable returns false for such CPUs, in
+// which case a different compression, such as FlateCompression, should be used instead.
+func SnappyAvailable() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86HasAVX2
+	default:
+		return true
+	}
+}
+
 // Compress ...
 func (snappyCompression) Compress(decompressed []byte) ([]byte, error) {
 	// Because Snappy allocates a slice only once, it is less important to have
@@ -155,7 +357,8 @@ func (snappyCompression) Decompress(compressed []byte, limit int) ([]byte, error
 		return nil, fmt.Errorf("snappy decoded length: %w", err)
 	}
 	if decodedLen > limit {
-		return nil, fmt.Errorf("snappy decoded size %d exceeds limit %d", decodedLen, limit)
+		// The length prefix is checked before decoding, so that nothing is allocated for oversized payloads.
+		return nil, decompressedTooLarge(CompressionAlgorithmSnappy, len(compressed), limit)
 	}
 	decompressed, err := snappy.Decode(nil, compressed)
 	if err != nil {
@@ -164,6 +367,254 @@ func (snappyCompression) Decompress(compressed []byte, limit int) ([]byte, error
 	return decompressed, nil
 }
 
+// DecompressTo ...
+func (c snappyCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	// Snappy's block format cannot be decoded in a streaming fashion, so the
+	// data is decoded at once and written to dst after.
+	decompressed, err := c.Decompress(compressed, limit)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(decompressed); err != nil {
+		return fmt.Errorf("write decompressed snappy: %w", err)
+	}
+	return nil
+}
+
+// EncodeCompression ...
+func (snappyStreamCompression) EncodeCompression() uint16 {
+	return CompressionAlgorithmSnappyStream
+}
+
+// Compress ...
+func (snappyStreamCompression) Compress(decompressed []byte) ([]byte, error) {
+	compressed := internal.BufferPool.Get().(*bytes.Buffer)
+	w := snappyStreamCompressPool.Get().(*snappy.Writer)
+
+	defer func() {
+		// Reset the buffer, so we can return it to the buffer pool safely.
+		compressed.Reset()
+		internal.BufferPool.Put(compressed)
+		w.Reset(nil)
+		snappyStreamCompressPool.Put(w)
+	}()
+
+	w.Reset(compressed)
+	if _, err := w.Write(decompressed); err != nil {
+		return nil, fmt.Errorf("compress snappy stream: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("close snappy stream writer: %w", err)
+	}
+	return append([]byte(nil), compressed.Bytes()...), nil
+}
+
+// Decompress ...
+func (c snappyStreamCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
+	// Guess an uncompressed size of 2*len(compressed).
+	decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
+	if err := c.DecompressTo(decompressed, compressed, limit); err != nil {
+		return nil, err
+	}
+	return decompressed.Bytes(), nil
+}
+
+// DecompressTo ...
+func (snappyStreamCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	r := snappyStreamDecompressPool.Get().(*snappy.Reader)
+	defer func() {
+		r.Reset(nil)
+		snappyStreamDecompressPool.Put(r)
+	}()
+
+	r.Reset(bytes.NewReader(compressed))
+	if _, err := io.Copy(dst, io.LimitReader(r, int64(limit))); err != nil {
+		return fmt.Errorf("decompress snappy stream: %w", err)
+	}
+	if exceedsLimit(r) {
+		return decompressedTooLarge(CompressionAlgorithmSnappyStream, len(compressed), limit)
+	}
+	return nil
+}
+
+// EncodeCompression ...
+func (zstdCompression) EncodeCompression() uint16 {
+	return CompressionAlgorithmZstd
+}
+
+// Compress ...
+func (c zstdCompression) Compress(decompressed []byte) ([]byte, error) {
+	w := c.encodePool.Get().(*zstd.Encoder)
+	defer c.encodePool.Put(w)
+
+	// EncodeAll writes a complete frame including the content size, so the
+	// decoding end is able to allocate the right size straight away.
+	return w.EncodeAll(decompressed, make([]byte, 0, len(decompressed)/2)), nil
+}
+
+// Decompress ...
+func (c zstdCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
+	// Guess an uncompressed size of 2*len(compressed).
+	decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
+	if err := c.DecompressTo(decompressed, compressed, limit); err != nil {
+		return nil, err
+	}
+	return decompressed.Bytes(), nil
+}
+
+// DecompressTo ...
+func (zstdCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	pool := zstdDecompressPool(limit)
+	r := pool.Get().(*zstd.Decoder)
+	defer func() {
+		// Release the reference to the compressed data so that it may be
+		// garbage collected while the decoder sits in the pool.
+		_ = r.Reset(nil)
+		pool.Put(r)
+	}()
+
+	if err := r.Reset(bytes.NewReader(compressed)); err != nil {
+		return fmt.Errorf("reset zstd: %w", err)
+	}
+	if _, err := io.Copy(dst, io.LimitReader(r, int64(limit))); err != nil {
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			// The decoder rejects frames holding a content size larger than the limit before decoding them.
+			return decompressedTooLarge(CompressionAlgorithmZstd, len(compressed), limit)
+		}
+		return fmt.Errorf("decompress zstd: %w", err)
+	}
+	if exceedsLimit(r) {
+		return decompressedTooLarge(CompressionAlgorithmZstd, len(compressed), limit)
+	}
+	return nil
+}
+
+// zstdDecompressPool returns a sync.Pool of zstd decoders that decode data up to the limit passed. The window
+// and memory used by the decoders are limited to the limit, so that a frame announcing a large window cannot
+// make the decoder allocate more memory than the data it may decompress to. Decoders are pooled per limit,
+// as the limits of a decoder cannot be changed after it is created.
+func zstdDecompressPool(limit int) *sync.Pool {
+	if pool, ok := zstdDecompressPools.Load(limit); ok {
+		return pool.(*sync.Pool)
+	}
+	size := uint64(max(limit, zstd.MinWindowSize))
+	pool, _ := zstdDecompressPools.LoadOrStore(limit, &sync.Pool{
+		New: func() any {
+			r, _ := zstd.NewReader(nil,
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderLowmem(true),
+				zstd.WithDecoderMaxWindow(size),
+				zstd.WithDecoderMaxMemory(size),
+			)
+			return r
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
+// EncodeCompression ...
+func (lz4Compression) EncodeCompression() uint16 {
+	return CompressionAlgorithmLZ4
+}
+
+// Compress ...
+func (lz4Compression) Compress(decompressed []byte) ([]byte, error) {
+	compressed := internal.BufferPool.Get().(*bytes.Buffer)
+	w := lz4CompressPool.Get().(*lz4.Writer)
+
+	defer func() {
+		// Reset the buffer, so we can return it to the buffer pool safely.
+		compressed.Reset()
+		internal.BufferPool.Put(compressed)
+		w.Reset(nil)
+		lz4CompressPool.Put(w)
+	}()
+
+	w.Reset(compressed)
+	// Write the content size in the frame header, so that the decoding end
+	// can allocate the right size straight away.
+	if err := w.Apply(lz4.SizeOption(uint64(len(decompressed)))); err != nil {
+		return nil, fmt.Errorf("apply lz4 size: %w", err)
+	}
+	if _, err := w.Write(decompressed); err != nil {
+		return nil, fmt.Errorf("compress lz4: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("close lz4 writer: %w", err)
+	}
+	return append([]byte(nil), compressed.Bytes()...), nil
+}
+
+// Decompress ...
+func (c lz4Compression) Decompress(compressed []byte, limit int) ([]byte, error) {
+	size, ok := lz4ContentSize(compressed)
+	if !ok {
+		// Guess an uncompressed size of 2*len(compressed).
+		decompressed := bytes.NewBuffer(make([]byte, 0, len(compressed)*2))
+		if err := c.DecompressTo(decompressed, compressed, limit); err != nil {
+			return nil, err
+		}
+		return decompressed.Bytes(), nil
+	}
+	if size > uint64(limit) {
+		// The content size is checked before decoding, so that nothing is allocated for oversized payloads.
+		return nil, decompressedTooLarge(CompressionAlgorithmLZ4, len(compressed), limit)
+	}
+	r := lz4DecompressPool.Get().(*lz4.Reader)
+	defer func() {
+		r.Reset(nil)
+		lz4DecompressPool.Put(r)
+	}()
+
+	r.Reset(bytes.NewReader(compressed))
+	// The content size is known, so the data is read into a slice of exactly that size, rather than a
+	// bytes.Buffer, which grows past the size when reading until io.EOF.
+	decompressed := make([]byte, size)
+	if _, err := io.ReadFull(r, decompressed); err != nil {
+		return nil, fmt.Errorf("decompress lz4: %w", err)
+	}
+	// Read until the end of the frame, so that its checksum is verified.
+	var b [1]byte
+	if n, err := r.Read(b[:]); n > 0 {
+		return nil, fmt.Errorf("decompress lz4: decompressed data exceeds content size %v", size)
+	} else if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decompress lz4: %w", err)
+	}
+	return decompressed, nil
+}
+
+// DecompressTo ...
+func (lz4Compression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	if size, ok := lz4ContentSize(compressed); ok && size > uint64(limit) {
+		return decompressedTooLarge(CompressionAlgorithmLZ4, len(compressed), limit)
+	}
+	r := lz4DecompressPool.Get().(*lz4.Reader)
+	defer func() {
+		r.Reset(nil)
+		lz4DecompressPool.Put(r)
+	}()
+
+	r.Reset(bytes.NewReader(compressed))
+	if _, err := io.Copy(dst, io.LimitReader(r, int64(limit))); err != nil {
+		return fmt.Errorf("decompress lz4: %w", err)
+	}
+	if exceedsLimit(r) {
+		return decompressedTooLarge(CompressionAlgorithmLZ4, len(compressed), limit)
+	}
+	return nil
+}
+
+// lz4ContentSize reads the content size from the header of the LZ4 frame passed. If the frame header does
+// not have the content size set, false is returned.
+func lz4ContentSize(compressed []byte) (uint64, bool) {
+	// The frame header exists out of a 4 byte magic number, a FLG byte, a BD
+	// byte and an optional 8 byte content size if bit 3 of FLG is set.
+	if len(compressed) < 14 || compressed[4]&0x08 == 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(compressed[6:14]), true
+}
+
 // EncodeCompression ...
 func (onTheFlyCompression) EncodeCompression() uint16 {
 	return math.MaxUint16
@@ -180,51 +631,261 @@ func (c onTheFlyCompression) Compress(decompressed []byte) ([]byte, error) {
 }
 
 // Decompress ...
-func (onTheFlyCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
-	var compression Compression
-	if compressed[0] != 0xff {
-		var ok bool
-		compression, ok = CompressionByID(uint16(compressed[0]))
-		if !ok {
-			return nil, fmt.Errorf("error decompressing packet: unknown compression algorithm %v", compressed[0])
+func (c onTheFlyCompression) Decompress(compressed []byte, limit int) ([]byte, error) {
+	decompressed, _, err := c.DecompressWithAlgorithm(compressed, limit)
+	return decompressed, err
+}
+
+// DecompressWithAlgorithm decompresses the data passed using the algorithm found in its prefix. Batches
+// prefixed with onTheFlyNone are not compressed, in which case the data following the prefix must hold
+// complete packets. If the Compression was obtained using NewOnTheFlyCompressionWithFallback and the data
+// cannot be decoded using the algorithm found in its prefix, the data is assumed to lack the prefix and
+// decoding the data as a whole is attempted using the underlying Compression, and then without compression.
+// The Compression returned is the one that decoded the data successfully. If none of the attempts succeed,
+// the error returned holds the error of each attempt. Data exceeding the limit is never decoded again.
+func (c onTheFlyCompression) DecompressWithAlgorithm(compressed []byte, limit int) ([]byte, Compression, error) {
+	compression, err := c.algorithm(compressed)
+	if err == nil {
+		var decompressed []byte
+		if decompressed, err = c.decompressPrefixed(compression, compressed[1:], limit); err == nil {
+			return decompressed, compression, nil
 		}
 	}
-	compressed = compressed[1:]
-	if compression != nil {
-		return compression.Decompress(compressed, limit)
+	if !c.fallback || len(compressed) == 0 || errors.Is(err, ErrDecompressedTooLarge) {
+		return nil, nil, err
+	}
+	errs := []error{fmt.Errorf("prefixed: %w", err)}
+	for i, fallback := range []Compression{c.c, NopCompression} {
+		if i == 1 && fallback == c.c {
+			// The underlying Compression was NopCompression, which was already attempted.
+			continue
+		}
+		decompressed, err := decompressBatch(fallback, compressed, limit)
+		if err == nil {
+			return decompressed, fallback, nil
+		}
+		if errors.Is(err, ErrDecompressedTooLarge) {
+			return nil, nil, err
+		}
+		errs = append(errs, fmt.Errorf("unprefixed %v: %w", fallback.EncodeCompression(), err))
+	}
+	return nil, nil, &CompressionError{Op: "decompress", Err: errors.Join(errs...), Algorithm: math.MaxUint16, InputLen: len(compressed)}
+}
+
+// decompressPrefixed decompresses the data following the prefix of a batch using the Compression resolved
+// from the prefix. Uncompressed data is checked to hold complete packets. Decompressed data is only checked
+// if the fallback is enabled, as data decompressed using the wrong Compression must not be accepted then.
+func (c onTheFlyCompression) decompressPrefixed(compression Compression, compressed []byte, limit int) ([]byte, error) {
+	if compression == NopCompression || c.fallback {
+		return decompressBatch(compression, compressed, limit)
+	}
+	decompressed, err := compression.Decompress(compressed, limit)
+	return decompressed, decompressError(compression, len(compressed), err)
+}
+
+// DecompressTo ...
+func (c onTheFlyCompression) DecompressTo(dst io.Writer, compressed []byte, limit int) error {
+	if c.fallback {
+		decompressed, _, err := c.DecompressWithAlgorithm(compressed, limit)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(decompressed); err != nil {
+			return fmt.Errorf("write decompressed data: %w", err)
+		}
+		return nil
+	}
+	compression, err := c.algorithm(compressed)
+	if err != nil {
+		return err
+	}
+	if compression == NopCompression {
+		// Uncompressed data is written as is, so it is checked to hold complete packets first.
+		if _, err := decompressBatch(compression, compressed[1:], limit); err != nil {
+			return err
+		}
+	}
+	return decompressError(compression, len(compressed)-1, DecompressTo(compression, dst, compressed[1:], limit))
+}
+
+// onTheFlyNone is the algorithm ID that batches are prefixed with by on-the-fly compression if they are not
+// compressed. It is the lowest byte of CompressionAlgorithmNone.
+const onTheFlyNone = 0xff
+
+// algorithm resolves the Compression used for the compressed data passed from the algorithm ID that the data
+// is prefixed with. NopCompression is returned if the data was not compressed. Any algorithm other than the
+// underlying Compression is rejected, even if it is registered, as it was not negotiated with the other end.
+func (c onTheFlyCompression) algorithm(compressed []byte) (Compression, error) {
+	if len(compressed) == 0 {
+		return nil, &CompressionError{Op: "decompress", Err: errMissingAlgorithmID, Algorithm: CompressionAlgorithmNone}
+	}
+	switch compressed[0] {
+	case onTheFlyNone:
+		return NopCompression, nil
+	case byte(c.c.EncodeCompression()):
+		return c.c, nil
+	}
+	return nil, &CompressionError{Op: "decompress", Err: fmt.Errorf("compression algorithm %v was not negotiated", compressed[0]), Algorithm: uint16(compressed[0]), InputLen: len(compressed)}
+}
+
+// decompressBatch decompresses the data passed using the Compression passed and checks if the decompressed
+// data holds complete packets, so that data decompressed using the wrong Compression is not accepted.
+func decompressBatch(compression Compression, compressed []byte, limit int) ([]byte, error) {
+	decompressed, err := compression.Decompress(compressed, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBatch(decompressed); err != nil {
+		return nil, &CompressionError{Op: "decompress", Err: err, Algorithm: compression.EncodeCompression(), InputLen: len(compressed)}
+	}
+	return decompressed, nil
+}
+
+// decompressError wraps the error passed in a *CompressionError for the Compression passed, unless the error
+// is nil or already a *CompressionError.
+func decompressError(compression Compression, inputLen int, err error) error {
+	var compressionErr *CompressionError
+	if err == nil || errors.As(err, &compressionErr) {
+		return err
+	}
+	return &CompressionError{Op: "decompress", Err: err, Algorithm: compression.EncodeCompression(), InputLen: inputLen}
+}
+
+// checkBatch checks if the decompressed batch passed consists of complete packets, each prefixed with their
+// length, without any data left over.
+func checkBatch(batch []byte) error {
+	for len(batch) != 0 {
+		length, n := binary.Uvarint(batch)
+		if n <= 0 || n > 5 {
+			return errInvalidBatch
+		}
+		if batch = batch[n:]; length > uint64(len(batch)) {
+			return errInvalidBatch
+		}
+		batch = batch[length:]
+	}
+	return nil
+}
+
+// DecompressTo decompresses the compressed data passed using the Compression passed and writes the
+// decompressed data to dst. If the Compression implements StreamDecompressor, the data is streamed directly
+// into dst. Otherwise, Compression.Decompress is called and the data returned is written to dst.
+func DecompressTo(c Compression, dst io.Writer, compressed []byte, limit int) error {
+	if s, ok := c.(StreamDecompressor); ok {
+		return s.DecompressTo(dst, compressed, limit)
+	}
+	decompressed, err := c.Decompress(compressed, limit)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(decompressed); err != nil {
+		return fmt.Errorf("write decompressed data: %w", err)
+	}
+	return nil
+}
+
+// exceedsLimit checks if the io.Reader passed, which was previously read from up to a limit, still has data
+// left to be read, meaning the decompressed data exceeds the limit.
+func exceedsLimit(r io.Reader) bool {
+	var b [1]byte
+	n, _ := r.Read(b[:])
+	return n > 0
+}
+
+// errMissingAlgorithmID is returned when decompressing a batch using on-the-fly compression that does not
+// have the compression algorithm ID it should be prefixed with.
+var errMissingAlgorithmID = errors.New("batch is missing compression algorithm ID")
+
+// errInvalidBatch is returned when a decompressed batch does not consist of complete packets.
+var errInvalidBatch = errors.New("batch does not hold complete packets")
+
+// decompressedTooLarge returns a *CompressionError wrapping ErrDecompressedTooLarge for the limit passed.
+func decompressedTooLarge(algorithm uint16, inputLen, limit int) error {
+	return &CompressionError{
+		Op:        "decompress",
+		Err:       fmt.Errorf("%w (limit=%v)", ErrDecompressedTooLarge, limit),
+		Algorithm: algorithm,
+		InputLen:  inputLen,
 	}
-	return compressed, nil
 }
 
 // init registers all valid compressions with the protocol.
 func init() {
 	RegisterCompression(flateCompression{})
 	RegisterCompression(snappyCompression{})
+	RegisterCompression(ZstdCompression)
+	RegisterCompression(lz4Compression{})
+	RegisterCompression(snappyStreamCompression{})
+	RegisterCompression(nopCompression{})
 }
 
-var compressions = map[uint16]Compression{}
+var (
+	// compressionsMu guards compressions, so that compressions may be registered while connections look up
+	// compressions concurrently.
+	compressionsMu sync.RWMutex
+	compressions   = map[uint16]Compression{}
+)
 
-// RegisterCompression registers a compression so that it can be used by the protocol.
+// RegisterCompression registers a compression so that it can be used by the protocol. If a compression was
+// already registered with the same ID, it is replaced. RegisterCompression is safe for concurrent use.
 func RegisterCompression(compression Compression) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
 	compressions[compression.EncodeCompression()] = compression
 }
 
+// RegisteredCompressions returns the IDs of all compressions registered using RegisterCompression, sorted in
+// ascending order. The slice returned is a snapshot and is not updated by later registrations.
+func RegisteredCompressions() []uint16 {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	return slices.Sorted(maps.Keys(compressions))
+}
+
 // CompressionByID attempts to return a compression by the ID it was registered with. If found, the compression found
-// is returned and the bool is true.
+// is returned and the bool is true. If no compression was registered with the ID, DefaultCompression is returned
+// and the bool is false. Callers that must not fall back to DefaultCompression should check the bool returned,
+// or use LookupCompression instead.
 func CompressionByID(id uint16) (Compression, bool) {
-	c, ok := compressions[id]
+	c, ok := LookupCompression(id)
 	if !ok {
 		c = DefaultCompression
 	}
 	return c, ok
 }
 
+// LookupCompression returns the compression registered with the ID passed. Unlike CompressionByID, it does not
+// fall back to DefaultCompression: If no compression was registered with the ID, nil and false are returned.
+func LookupCompression(id uint16) (Compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+	c, ok := compressions[id]
+	return c, ok
+}
+
+// CompressionError is an error returned by an Encoder, Decoder or Compression if encoding or decoding a batch
+// failed. It holds the operation that failed, together with the compression algorithm and the length of the
+// input of the operation, so that the cause may be traced.
 type CompressionError struct {
 	// Op is the operation which caused the error.
 	Op string
 	// Err is the error that occurred during the operation.
 	// The Error method panics if the error is nil.
 	Err error
+	// Algorithm is the ID of the compression algorithm used during the
+	// operation, such as CompressionAlgorithmFlate. It is
+	// CompressionAlgorithmNone if no compression was used.
+	Algorithm uint16
+	// InputLen is the length of the input of the operation in bytes.
+	InputLen int
+	// Written is the number of bytes of the input written before the
+	// operation failed. It is only set if writing a batch failed.
+	Written int
+}
+
+// Reset clears all fields of the CompressionError, so that it may be reused.
+func (e *CompressionError) Reset() {
+	*e = CompressionError{}
 }
 
 func (e *CompressionError) Unwrap() error { return e.Err }
@@ -233,5 +894,5 @@ func (e *CompressionError) Error() string {
 	if e == nil {
 		return "<nil>"
 	}
-	return e.Op + ": " + e.Err.Error()
+	return e.Op + " (algorithm=" + strconv.Itoa(int(e.Algorithm)) + ", len=" + strconv.Itoa(e.InputLen) + "): " + e.Err.Error()
 }
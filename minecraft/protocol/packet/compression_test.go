@@ -0,0 +1,453 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// testBatch is a decompressed batch holding two packets, each prefixed with their length.
+var testBatch = []byte{3, 0x01, 0x02, 0x03, 2, 0x04, 0x05}
+
+func TestOnTheFlyDecompress(t *testing.T) {
+	flateData, err := FlateCompression.Compress(testBatch)
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	tests := []struct {
+		name       string
+		data       []byte
+		fallback   bool
+		want       Compression
+		shouldFail bool
+	}{
+		{name: "uncompressed", data: append([]byte{onTheFlyNone}, testBatch...), want: NopCompression},
+		{name: "uncompressed incomplete", data: []byte{onTheFlyNone, 5, 0x01}, shouldFail: true},
+		{name: "uncompressed empty", data: []byte{onTheFlyNone}, want: NopCompression},
+		{name: "flate", data: append([]byte{byte(CompressionAlgorithmFlate)}, flateData...), want: FlateCompression},
+		{name: "empty", data: nil, shouldFail: true},
+		{name: "unknown algorithm", data: []byte{0x7f, 0x01}, shouldFail: true},
+		{name: "unprefixed flate", data: flateData, shouldFail: true},
+		{name: "unprefixed flate with fallback", data: flateData, fallback: true, want: FlateCompression},
+		{name: "unprefixed uncompressed with fallback", data: testBatch, fallback: true, want: NopCompression},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewOnTheFlyCompression(FlateCompression)
+			if test.fallback {
+				c = NewOnTheFlyCompressionWithFallback(FlateCompression)
+			}
+			decompressed, compression, err := c.(AlgorithmDecompressor).DecompressWithAlgorithm(test.data, 1<<20)
+			if test.shouldFail {
+				if err == nil {
+					t.Fatalf("expected error, got %x", decompressed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			}
+			if compression.EncodeCompression() != test.want.EncodeCompression() {
+				t.Errorf("expected compression %v, got %v", test.want.EncodeCompression(), compression.EncodeCompression())
+			}
+			if len(decompressed) != 0 && !bytes.Equal(decompressed, testBatch) {
+				t.Errorf("expected %x, got %x", testBatch, decompressed)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if err := DecompressTo(c, buf, test.data, 1<<20); err != nil {
+				t.Fatalf("decompress to: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), decompressed) {
+				t.Errorf("DecompressTo: expected %x, got %x", decompressed, buf.Bytes())
+			}
+		})
+	}
+}
+
+func TestOnTheFlyDecompressLimit(t *testing.T) {
+	data := append([]byte{onTheFlyNone}, testBatch...)
+	for _, c := range []Compression{NewOnTheFlyCompression(FlateCompression), NewOnTheFlyCompressionWithFallback(FlateCompression)} {
+		if _, err := c.Decompress(data, len(testBatch)-1); !errors.Is(err, ErrDecompressedTooLarge) {
+			t.Errorf("expected ErrDecompressedTooLarge, got %v", err)
+		}
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	for _, c := range []Compression{NopCompression, FlateCompression, ZstdCompression, LZ4Compression, NewSnappyStreamCompression()} {
+		compressed, err := c.Compress(testBatch)
+		if err != nil {
+			t.Fatalf("%v: compress: %v", c.EncodeCompression(), err)
+		}
+		decompressed, err := c.Decompress(compressed, 1<<20)
+		if err != nil {
+			t.Fatalf("%v: decompress: %v", c.EncodeCompression(), err)
+		}
+		if !bytes.Equal(decompressed, testBatch) {
+			t.Errorf("%v: expected %x, got %x", c.EncodeCompression(), testBatch, decompressed)
+		}
+	}
+}
+
+// testPayloads returns payloads of sizes up to 4MB to compress, holding both random and repetitive data.
+func testPayloads() [][]byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	var payloads [][]byte
+	for _, size := range []int{0, 1, 100, 4096, 64 * 1024, 1024 * 1024, 4 * 1024 * 1024} {
+		random := make([]byte, size)
+		for i := range random {
+			random[i] = byte(r.Uint32())
+		}
+		repetitive := bytes.Repeat([]byte("minecraft:stone "), size/16+1)[:size]
+		payloads = append(payloads, random, repetitive)
+	}
+	return payloads
+}
+
+func TestZstdCompression(t *testing.T) {
+	c, ok := CompressionByID(CompressionAlgorithmZstd)
+	if !ok || c.EncodeCompression() != CompressionAlgorithmZstd {
+		t.Fatalf("expected zstd compression to be registered, got %v", c)
+	}
+	for _, c := range []Compression{ZstdCompression, NewZstdCompression(1), NewZstdCompression(19)} {
+		for _, payload := range testPayloads() {
+			compressed, err := c.Compress(payload)
+			if err != nil {
+				t.Fatalf("compress %v bytes: %v", len(payload), err)
+			}
+			decompressed, err := ZstdCompression.Decompress(compressed, len(payload))
+			if err != nil {
+				t.Fatalf("decompress %v bytes: %v", len(payload), err)
+			}
+			if !bytes.Equal(decompressed, payload) {
+				t.Fatalf("payload of %v bytes changed after round trip", len(payload))
+			}
+			if len(payload) > 0 {
+				if _, err := c.Decompress(compressed, len(payload)-1); !errors.Is(err, ErrDecompressedTooLarge) {
+					t.Fatalf("expected ErrDecompressedTooLarge for payload of %v bytes, got %v", len(payload), err)
+				}
+			}
+		}
+	}
+}
+
+// TestZstdUnsupportedPeer checks that data compressed using zstd is rejected with an error, rather than a
+// panic, by a peer that only supports flate, like vanilla clients, and that a batch prefixed with zstd is
+// only accepted by a peer that negotiated zstd.
+func TestZstdUnsupportedPeer(t *testing.T) {
+	compressed, err := ZstdCompression.Compress(bytes.Repeat([]byte{1, 2, 3}, 1000))
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	if _, err := FlateCompression.Decompress(compressed, 1<<20); err == nil {
+		t.Fatal("expected flate to fail decompressing zstd data")
+	}
+	prefixed := append([]byte{CompressionAlgorithmZstd}, compressed...)
+	for _, c := range []Compression{NewOnTheFlyCompression(FlateCompression), NewOnTheFlyCompressionWithFallback(FlateCompression)} {
+		var compressionErr *CompressionError
+		if _, err := c.Decompress(prefixed, 1<<20); !errors.As(err, &compressionErr) {
+			t.Fatalf("expected *CompressionError for zstd data sent to a flate peer, got %v", err)
+		}
+		if err := DecompressTo(c, bytes.NewBuffer(nil), prefixed, 1<<20); err == nil {
+			t.Fatal("expected DecompressTo to reject zstd data sent to a flate peer")
+		}
+	}
+	if _, err := NewOnTheFlyCompression(ZstdCompression).Decompress(prefixed, 1<<20); err != nil {
+		t.Fatalf("expected prefixed zstd data to be decompressed by a zstd peer, got %v", err)
+	}
+}
+
+// TestZstdWindowLimit checks that a zstd frame announcing a window far larger than the decompression limit
+// is rejected without allocating the window.
+func TestZstdWindowLimit(t *testing.T) {
+	// A frame header announcing a window of 512MB, followed by a single RLE block.
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x98, 0xfb, 0xff, 0x0f, 0x00}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := ZstdCompression.Decompress(frame, 1<<20)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatal("expected frame with oversized window to be rejected")
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 64<<20 {
+		t.Fatalf("expected decoder to allocate less than 64MB, allocated %vMB", alloc>>20)
+	}
+}
+
+// TestOnTheFlyDecompressShort checks that decompressing batches too short to hold compressed data using
+// on-the-fly compression does not panic, and that any error returned is a *CompressionError.
+func TestOnTheFlyDecompressShort(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		shouldFail bool
+	}{
+		{name: "nil", data: nil, shouldFail: true},
+		{name: "empty", data: []byte{}, shouldFail: true},
+		{name: "unknown algorithm", data: []byte{0x7f}, shouldFail: true},
+		{name: "flate prefix only", data: []byte{byte(CompressionAlgorithmFlate)}},
+		{name: "zstd prefix only", data: []byte{byte(CompressionAlgorithmZstd)}, shouldFail: true},
+		{name: "uncompressed prefix only", data: []byte{onTheFlyNone}},
+	}
+	for _, test := range tests {
+		for _, c := range []Compression{NewOnTheFlyCompression(FlateCompression), NewOnTheFlyCompressionWithFallback(FlateCompression)} {
+			_, err := c.Decompress(test.data, 1<<20)
+			errTo := DecompressTo(c, bytes.NewBuffer(nil), test.data, 1<<20)
+			for _, err := range []error{err, errTo} {
+				var compressionErr *CompressionError
+				if test.shouldFail && err == nil {
+					t.Errorf("%v: expected error", test.name)
+				} else if err != nil && !errors.As(err, &compressionErr) {
+					t.Errorf("%v: expected *CompressionError, got %v", test.name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestLZ4Compression(t *testing.T) {
+	for _, payload := range testPayloads()[2:] {
+		compressed, err := LZ4Compression.Compress(payload)
+		if err != nil {
+			t.Fatalf("compress %v bytes: %v", len(payload), err)
+		}
+		// The content size is not written for empty payloads, as a content size of 0 means it is not set, so
+		// those payloads are skipped.
+		if size, ok := lz4ContentSize(compressed); !ok || size != uint64(len(payload)) {
+			t.Fatalf("expected content size %v in frame header, got %v (%v)", len(payload), size, ok)
+		}
+		decompressed, err := LZ4Compression.Decompress(compressed, len(payload))
+		if err != nil {
+			t.Fatalf("decompress %v bytes: %v", len(payload), err)
+		}
+		if !bytes.Equal(decompressed, payload) {
+			t.Fatalf("payload of %v bytes changed after round trip", len(payload))
+		}
+		// The content size in the frame header is used to allocate exactly the size needed.
+		if cap(decompressed) != len(payload) {
+			t.Errorf("expected capacity %v for decompressed payload, got %v", len(payload), cap(decompressed))
+		}
+		if _, err := LZ4Compression.Decompress(compressed, len(payload)-1); !errors.Is(err, ErrDecompressedTooLarge) {
+			t.Fatalf("expected ErrDecompressedTooLarge for payload of %v bytes, got %v", len(payload), err)
+		}
+	}
+}
+
+// TestLZ4UnsupportedPeer checks that a batch prefixed with lz4 is rejected by peers that did not negotiate
+// lz4, and that frames announcing a content size over the limit are rejected before they are decoded.
+func TestLZ4UnsupportedPeer(t *testing.T) {
+	compressed, err := LZ4Compression.Compress(bytes.Repeat([]byte{1, 2, 3}, 1000))
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	prefixed := append([]byte{CompressionAlgorithmLZ4}, compressed...)
+	for _, underlying := range []Compression{FlateCompression, ZstdCompression, NopCompression} {
+		c := NewOnTheFlyCompression(underlying)
+		var compressionErr *CompressionError
+		if _, err := c.Decompress(prefixed, 1<<20); !errors.As(err, &compressionErr) {
+			t.Fatalf("expected *CompressionError for lz4 data sent to a %v peer, got %v", underlying.EncodeCompression(), err)
+		}
+		if err := DecompressTo(c, bytes.NewBuffer(nil), prefixed, 1<<20); err == nil {
+			t.Fatalf("expected DecompressTo to reject lz4 data sent to a %v peer", underlying.EncodeCompression())
+		}
+		// With the fallback, the batch may still happen to decode as an unprefixed batch, but never using lz4.
+		fallback := NewOnTheFlyCompressionWithFallback(underlying).(AlgorithmDecompressor)
+		if _, compression, err := fallback.DecompressWithAlgorithm(prefixed, 1<<20); err == nil && compression.EncodeCompression() == CompressionAlgorithmLZ4 {
+			t.Fatalf("expected lz4 data sent to a %v peer with fallback not to be decoded using lz4", underlying.EncodeCompression())
+		}
+	}
+	if _, err := NewOnTheFlyCompression(LZ4Compression).Decompress(prefixed, 1<<20); err != nil {
+		t.Fatalf("expected prefixed lz4 data to be decompressed by an lz4 peer, got %v", err)
+	}
+
+	// Announce a content size far over the limit in the frame header.
+	oversized := append([]byte(nil), compressed...)
+	binary.LittleEndian.PutUint64(oversized[6:14], 1<<40)
+	if _, err := LZ4Compression.Decompress(oversized, 1<<20); !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("expected ErrDecompressedTooLarge for oversized content size, got %v", err)
+	}
+	if err := LZ4Compression.DecompressTo(bytes.NewBuffer(nil), oversized, 1<<20); !errors.Is(err, ErrDecompressedTooLarge) {
+		t.Errorf("expected ErrDecompressedTooLarge for oversized content size from DecompressTo, got %v", err)
+	}
+}
+
+// TestSnappyStreamUnsupportedPeer checks that a batch prefixed with the Snappy stream format is only accepted
+// by peers that negotiated it, including peers that negotiated the Snappy block format.
+func TestSnappyStreamUnsupportedPeer(t *testing.T) {
+	stream := NewSnappyStreamCompression()
+	compressed, err := stream.Compress(testBatch)
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+	prefixed := append([]byte{CompressionAlgorithmSnappyStream}, compressed...)
+	for _, underlying := range []Compression{FlateCompression, SnappyCompression, NopCompression} {
+		c := NewOnTheFlyCompression(underlying)
+		var compressionErr *CompressionError
+		if _, err := c.Decompress(prefixed, 1<<20); !errors.As(err, &compressionErr) {
+			t.Fatalf("expected *CompressionError for snappy stream data sent to a %v peer, got %v", underlying.EncodeCompression(), err)
+		}
+		if err := DecompressTo(c, bytes.NewBuffer(nil), prefixed, 1<<20); err == nil {
+			t.Fatalf("expected DecompressTo to reject snappy stream data sent to a %v peer", underlying.EncodeCompression())
+		}
+	}
+	decompressed, err := NewOnTheFlyCompression(stream).Decompress(prefixed, 1<<20)
+	if err != nil {
+		t.Fatalf("expected prefixed snappy stream data to be decompressed by a snappy stream peer, got %v", err)
+	}
+	if !bytes.Equal(decompressed, testBatch) {
+		t.Errorf("expected %x, got %x", testBatch, decompressed)
+	}
+}
+
+// TestLZ4Interop checks that peers resolving a Compression using CompressionByID fail with an error, rather
+// than a panic, when decompressing data compressed by a peer using a different algorithm.
+func TestLZ4Interop(t *testing.T) {
+	payload := bytes.Repeat([]byte{1, 2, 3}, 1000)
+	lz4, ok := CompressionByID(CompressionAlgorithmLZ4)
+	if !ok || lz4.EncodeCompression() != CompressionAlgorithmLZ4 {
+		t.Fatalf("expected lz4 compression to be registered, got %v", lz4)
+	}
+	flate, ok := CompressionByID(CompressionAlgorithmFlate)
+	if !ok || flate.EncodeCompression() != CompressionAlgorithmFlate {
+		t.Fatalf("expected flate compression to be registered, got %v", flate)
+	}
+	lz4Data, err := lz4.Compress(payload)
+	if err != nil {
+		t.Fatalf("compress lz4: %v", err)
+	}
+	flateData, err := flate.Compress(payload)
+	if err != nil {
+		t.Fatalf("compress flate: %v", err)
+	}
+	if _, err := flate.Decompress(lz4Data, 1<<20); err == nil {
+		t.Error("expected flate to fail decompressing lz4 data")
+	}
+	if _, err := lz4.Decompress(flateData, 1<<20); err == nil {
+		t.Error("expected lz4 to fail decompressing flate data")
+	}
+
+	const unknown = 0x1234
+	if c, ok := CompressionByID(unknown); ok || c != DefaultCompression {
+		t.Errorf("expected DefaultCompression and false for unknown ID, got %v (%v)", c, ok)
+	}
+	if c, ok := LookupCompression(unknown); ok || c != nil {
+		t.Errorf("expected nil and false for unknown ID, got %v (%v)", c, ok)
+	}
+}
+
+// idCompression is a Compression that does not compress data, with a custom ID.
+type idCompression struct {
+	nopCompression
+	id uint16
+}
+
+// EncodeCompression ...
+func (c idCompression) EncodeCompression() uint16 { return c.id }
+
+// TestRegisterCompressionConcurrent registers compressions while they are being looked up from other
+// goroutines. It is intended to be run with -race.
+func TestRegisterCompressionConcurrent(t *testing.T) {
+	const base, n = 0x2000, 64
+	t.Cleanup(func() {
+		compressionsMu.Lock()
+		defer compressionsMu.Unlock()
+		for id := uint16(base); id < base+n; id++ {
+			delete(compressions, id)
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for id := uint16(base + i); id < base+n; id += 8 {
+				RegisterCompression(idCompression{id: id})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for id := uint16(base); id < base+n; id++ {
+				if c, ok := LookupCompression(id); ok && c.EncodeCompression() != id {
+					t.Errorf("expected compression with ID %v, got %v", id, c.EncodeCompression())
+				}
+				_, _ = CompressionByID(id)
+				_ = RegisteredCompressions()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := uint16(base); id < base+n; id++ {
+		if _, ok := LookupCompression(id); !ok {
+			t.Errorf("expected compression with ID %v to be registered", id)
+		}
+	}
+}
+
+// benchmarkPayload returns a batch-like payload of 64KB that holds both repetitive and random data, so that
+// the compression levels benchmarked produce different ratios.
+func benchmarkPayload() []byte {
+	r := rand.New(rand.NewPCG(3, 4))
+	payload := make([]byte, 0, 64*1024)
+	for len(payload) < cap(payload)-64 {
+		payload = append(payload, "minecraft:stone"...)
+		for range 16 {
+			payload = append(payload, byte(r.Uint32()%8))
+		}
+	}
+	return payload
+}
+
+// benchmarkCompression benchmarks compressing and decompressing the benchmark payload using the Compression
+// passed, reporting the compression ratio as the size of the compressed data relative to the payload.
+func benchmarkCompression(b *testing.B, c Compression) {
+	payload := benchmarkPayload()
+	compressed, err := c.Compress(payload)
+	if err != nil {
+		b.Fatalf("compress: %v", err)
+	}
+	b.Run("compress", func(b *testing.B) {
+		b.SetBytes(int64(len(payload)))
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := c.Compress(payload); err != nil {
+				b.Fatalf("compress: %v", err)
+			}
+		}
+		b.ReportMetric(float64(len(compressed))/float64(len(payload)), "ratio")
+	})
+	b.Run("decompress", func(b *testing.B) {
+		b.SetBytes(int64(len(payload)))
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := c.Decompress(compressed, len(payload)); err != nil {
+				b.Fatalf("decompress: %v", err)
+			}
+		}
+	})
+}
+
+// benchmarkFlateLevel benchmarks a Flate Compression with the level passed.
+func benchmarkFlateLevel(b *testing.B, level int) {
+	c, err := NewFlateCompression(level)
+	if err != nil {
+		b.Fatalf("new flate compression: %v", err)
+	}
+	benchmarkCompression(b, c)
+}
+
+func BenchmarkFlateLevel1(b *testing.B) { benchmarkFlateLevel(b, 1) }
+func BenchmarkFlateLevel6(b *testing.B) { benchmarkFlateLevel(b, 6) }
+func BenchmarkFlateLevel9(b *testing.B) { benchmarkFlateLevel(b, 9) }
+
+func BenchmarkZstdLevel1(b *testing.B)  { benchmarkCompression(b, NewZstdCompression(1)) }
+func BenchmarkZstdLevel3(b *testing.B)  { benchmarkCompression(b, NewZstdCompression(3)) }
+func BenchmarkZstdLevel9(b *testing.B)  { benchmarkCompression(b, NewZstdCompression(9)) }
+func BenchmarkZstdLevel19(b *testing.B) { benchmarkCompression(b, NewZstdCompression(19)) }
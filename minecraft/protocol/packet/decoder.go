@@ -25,7 +25,9 @@ type Decoder struct {
 
 	maxDecompressedLen int
 
-	checkPacketLimit bool
+	// maxPacketsPerBatch is the maximum amount of packets that may be found in a single batch. If 0, the
+	// amount of packets is not limited.
+	maxPacketsPerBatch int
 }
 
 // packetReader is used to read packets immediately instead of copying them in a buffer first. This is a
@@ -38,12 +40,12 @@ type packetReader interface {
 // assumed to consume an entire packet.
 func NewDecoder(reader io.Reader) *Decoder {
 	if pr, ok := reader.(packetReader); ok {
-		return &Decoder{checkPacketLimit: true, pr: pr}
+		return &Decoder{maxPacketsPerBatch: maximumInBatch, pr: pr}
 	}
 	return &Decoder{
-		r:                reader,
-		buf:              make([]byte, 1024*1024*3),
-		checkPacketLimit: true,
+		r:                  reader,
+		buf:                make([]byte, 1024*1024*3),
+		maxPacketsPerBatch: maximumInBatch,
 	}
 }
 
@@ -62,7 +64,14 @@ func (decoder *Decoder) EnableCompression(compression Compression, maxDecompress
 // DisableBatchPacketLimit disables the check that limits the number of packets allowed in a single packet
 // batch. This should typically be called for Decoders decoding from a server connection.
 func (decoder *Decoder) DisableBatchPacketLimit() {
-	decoder.checkPacketLimit = false
+	decoder.maxPacketsPerBatch = 0
+}
+
+// SetMaxPacketsPerBatch sets the maximum amount of packets that may be found in a single packet batch. If a
+// batch holds more packets, decoding it fails before the excess packets are split off. A value of 0 or less
+// disables the limit. By default, at most 812 packets may be found in a batch.
+func (decoder *Decoder) SetMaxPacketsPerBatch(n int) {
+	decoder.maxPacketsPerBatch = max(n, 0)
 }
 
 const (
@@ -76,6 +85,18 @@ const (
 // Decode decodes one 'packet' from the io.Reader passed in NewDecoder(), producing a slice of packets that it
 // held and an error if not successful.
 func (decoder *Decoder) Decode() (packets [][]byte, err error) {
+	batch, err := decoder.ReadBatch()
+	if err != nil {
+		return nil, err
+	}
+	return decoder.DecodeBatch(batch)
+}
+
+// ReadBatch reads one batch from the io.Reader passed in NewDecoder() and decrypts it if encryption is
+// enabled. Unlike Decode, the batch returned is not decompressed: It may be decoded into packets using
+// DecodeBatch, or be sent as-is using Encoder.EncodeBatch. ReadBatch returns a nil slice if an empty batch
+// was read. The slice returned may be reused by the Decoder in the next call to ReadBatch or Decode.
+func (decoder *Decoder) ReadBatch() (batch []byte, err error) {
 	var data []byte
 	if decoder.pr == nil {
 		var n int
@@ -85,7 +106,7 @@ func (decoder *Decoder) Decode() (packets [][]byte, err error) {
 		data, err = decoder.pr.ReadPacket()
 	}
 	if err != nil {
-		return nil, &CompressionError{Op: "read batch", Err: err}
+		return nil, decoder.wrap("read batch", err, 0)
 	}
 	if len(data) == 0 {
 		return nil, nil
@@ -98,28 +119,51 @@ func (decoder *Decoder) Decode() (packets [][]byte, err error) {
 		decoder.encryption.Decrypt(data)
 		if err := decoder.encryption.Verify(data); err != nil {
 			// The packet did not have a correct checksum.
-			return nil, &CompressionError{Op: "verify batch", Err: err}
+			return nil, decoder.wrap("verify batch", err, len(data))
 		}
 		data = data[:len(data)-8]
 	}
+	return data, nil
+}
 
+// DecodeBatch decompresses a batch obtained using ReadBatch and splits it into the packets that it held.
+func (decoder *Decoder) DecodeBatch(batch []byte) (packets [][]byte, err error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	data := batch
 	if decoder.compression != nil {
 		data, err = decoder.compression.Decompress(data, decoder.maxDecompressedLen)
 		if err != nil {
-			return nil, &CompressionError{Op: "decompress batch", Err: err}
+			return nil, decoder.wrap("decompress batch", err, len(batch))
 		}
 	}
 
 	b := bytes.NewBuffer(data)
 	for b.Len() != 0 {
+		if decoder.maxPacketsPerBatch != 0 && len(packets) == decoder.maxPacketsPerBatch {
+			return nil, fmt.Errorf("decode batch: number of packets exceeds max=%v", decoder.maxPacketsPerBatch)
+		}
 		var length uint32
 		if err := protocol.Varuint32(b, &length); err != nil {
-			return nil, &CompressionError{Op: "decode batch: read packet length", Err: err}
+			return nil, decoder.wrap("decode batch: read packet length", err, len(data))
 		}
 		packets = append(packets, b.Next(int(length)))
 	}
-	if len(packets) > maximumInBatch && decoder.checkPacketLimit {
-		return nil, fmt.Errorf("decode batch: number of packets %v exceeds max=%v", len(packets), maximumInBatch)
-	}
 	return packets, nil
 }
+
+// Compression returns the Compression enabled for the Decoder, or nil if compression is not enabled.
+func (decoder *Decoder) Compression() Compression {
+	return decoder.compression
+}
+
+// wrap returns a *CompressionError for the op, error and input length passed, with the algorithm set to that
+// of the compression enabled for the Decoder.
+func (decoder *Decoder) wrap(op string, err error, inputLen int) error {
+	algorithm := uint16(CompressionAlgorithmNone)
+	if decoder.compression != nil {
+		algorithm = decoder.compression.EncodeCompression()
+	}
+	return &CompressionError{Op: op, Err: err, Algorithm: algorithm, InputLen: inputLen}
+}
@@ -12,8 +12,9 @@ import (
 type Encoder struct {
 	w io.Writer
 
-	compression Compression
-	encryption  Encryption
+	compression          Compression
+	compressionThreshold int
+	encryption           Encryption
 }
 
 // NewEncoder returns a new Encoder for the io.Writer passed. Each final packet produced by the Encoder is
@@ -33,9 +34,31 @@ func (encoder *Encoder) EnableCompression(compression Compression) {
 	encoder.compression = compression
 }
 
+// SetCompressionThreshold sets the minimum size in bytes of a batch for it to be compressed. Batches smaller
+// than the threshold are sent uncompressed. The threshold only has effect if the Compression passed to
+// EnableCompression was obtained using NewOnTheFlyCompression, as only those batches record whether they
+// were compressed. A threshold of 0 or lower results in all batches being compressed.
+func (encoder *Encoder) SetCompressionThreshold(threshold int) {
+	encoder.compressionThreshold = threshold
+}
+
 // Encode encodes the packets passed. It writes all of them as a single packet which is  compressed and
 // optionally encrypted.
 func (encoder *Encoder) Encode(packets [][]byte) error {
+	return encoder.encode(packets, false)
+}
+
+// EncodeUncompressed encodes the packets passed like Encode, but does not compress the batch if the
+// Compression enabled was obtained using NewOnTheFlyCompression, as only those batches record whether they
+// were compressed. If another Compression is enabled, the other end of the connection expects every batch to
+// be compressed, so the batch is compressed regardless.
+func (encoder *Encoder) EncodeUncompressed(packets [][]byte) error {
+	return encoder.encode(packets, true)
+}
+
+// encode encodes the packets passed as a single batch. If uncompressed is true, the batch is sent without
+// compression if possible.
+func (encoder *Encoder) encode(packets [][]byte, uncompressed bool) error {
 	buf := internal.BufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		// Reset the buffer, so we can return it to the buffer pool safely.
@@ -47,19 +70,24 @@ func (encoder *Encoder) Encode(packets [][]byte) error {
 	for _, packet := range packets {
 		// Each packet is prefixed with a varuint32 specifying the length of the packet.
 		if err := writeVaruint32(buf, uint32(len(packet)), l); err != nil {
-			return &CompressionError{Op: "encode batch: write packet length", Err: err}
+			return encoder.wrap("encode batch: write packet length", err, buf.Len())
 		}
 		if _, err := buf.Write(packet); err != nil {
-			return &CompressionError{Op: "encode batch: write packet payload", Err: err}
+			return encoder.wrap("encode batch: write packet payload", err, buf.Len())
 		}
 	}
 
 	data := buf.Bytes()
-	if encoder.compression != nil {
+	if compression := encoder.compression; compression != nil {
+		if _, ok := compression.(onTheFlyCompression); ok && (uncompressed || len(data) < encoder.compressionThreshold) {
+			// The batch is below the compression threshold or must not be compressed, so we send it
+			// uncompressed. The on-the-fly compression prefixes it with the ID of NopCompression so that
+			// the other end knows.
+			compression = onTheFlyCompression{c: NopCompression}
+		}
 		var err error
-		data, err = encoder.compression.Compress(data)
-		if err != nil {
-			return &CompressionError{Op: "compress batch", Err: err}
+		if data, err = compression.Compress(data); err != nil {
+			return encoder.wrap("compress batch", err, buf.Len())
 		}
 	}
 
@@ -69,12 +97,46 @@ func (encoder *Encoder) Encode(packets [][]byte) error {
 		// compressed data of this packet.
 		data = encoder.encryption.Encrypt(data)
 	}
-	if _, err := encoder.w.Write(data); err != nil {
-		return &CompressionError{Op: "write batch", Err: err}
+	if n, err := encoder.w.Write(data); err != nil {
+		e := encoder.wrap("write batch", err, len(data))
+		e.Written = n
+		return e
 	}
 	return nil
 }
 
+// EncodeBatch writes a batch that was already compressed, such as one obtained using Decoder.ReadBatch,
+// without compressing it again. The batch is encrypted if encryption is enabled. The batch must have been
+// compressed using the same Compression as the one enabled for the Encoder, as the other end is otherwise
+// unable to decompress it.
+func (encoder *Encoder) EncodeBatch(batch []byte) error {
+	data := append([]byte{header}, batch...)
+	if encoder.encryption != nil {
+		data = encoder.encryption.Encrypt(data)
+	}
+	if n, err := encoder.w.Write(data); err != nil {
+		e := encoder.wrap("write batch", err, len(data))
+		e.Written = n
+		return e
+	}
+	return nil
+}
+
+// Compression returns the Compression enabled for the Encoder, or nil if compression is not enabled.
+func (encoder *Encoder) Compression() Compression {
+	return encoder.compression
+}
+
+// wrap returns a *CompressionError for the op, error and input length passed, with the algorithm set to that
+// of the compression enabled for the Encoder.
+func (encoder *Encoder) wrap(op string, err error, inputLen int) *CompressionError {
+	algorithm := uint16(CompressionAlgorithmNone)
+	if encoder.compression != nil {
+		algorithm = encoder.compression.EncodeCompression()
+	}
+	return &CompressionError{Op: op, Err: err, Algorithm: algorithm, InputLen: inputLen}
+}
+
 // writeVaruint32 writes a uint32 to the destination buffer passed with a size of 1-5 bytes. It uses byte
 // slice b in order to prevent allocations.
 func writeVaruint32(dst io.Writer, x uint32, b []byte) error {
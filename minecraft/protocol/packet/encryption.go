@@ -40,6 +40,11 @@ func NewCTREncryption(keyBytes []byte) Encryption {
 	return &ctr{keyBytes: keyBytes, stream: stream}
 }
 
+// String returns the name of the cipher used, which depends on the size of the key.
+func (c *ctr) String() string {
+	return fmt.Sprintf("AES-%v-CTR", len(c.keyBytes)*8)
+}
+
 // Encrypt ...
 func (c *ctr) Encrypt(data []byte) []byte {
 	// We first write the current send counter to a buffer and use it to produce a packet checksum.
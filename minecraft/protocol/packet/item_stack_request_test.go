@@ -0,0 +1,93 @@
+package packet
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+func TestItemStackRequestActions(t *testing.T) {
+	source := protocol.StackRequestSlotInfo{Container: protocol.FullContainerName{ContainerID: 12}, Slot: 3, StackNetworkID: 41}
+	destination := protocol.StackRequestSlotInfo{
+		Container: protocol.FullContainerName{ContainerID: 28, DynamicContainerID: protocol.Option(uint32(7))},
+		Slot:      9,
+	}
+
+	tests := []struct {
+		name   string
+		action protocol.StackRequestAction
+	}{
+		{"take", &protocol.TakeStackRequestAction{}},
+		{"place", &protocol.PlaceStackRequestAction{}},
+		{"swap", &protocol.SwapStackRequestAction{Source: source, Destination: destination}},
+		{"drop", &protocol.DropStackRequestAction{Count: 5, Source: source, Randomly: true}},
+		{"destroy", &protocol.DestroyStackRequestAction{Count: 64, Source: source}},
+		{"consume", &protocol.ConsumeStackRequestAction{DestroyStackRequestAction: protocol.DestroyStackRequestAction{Count: 1, Source: destination}}},
+		{"create", &protocol.CreateStackRequestAction{ResultsSlot: 50}},
+		{"place in container", &protocol.PlaceInContainerStackRequestAction{}},
+		{"take out container", &protocol.TakeOutContainerStackRequestAction{}},
+		{"lab table combine", &protocol.LabTableCombineStackRequestAction{}},
+		{"beacon payment", &protocol.BeaconPaymentStackRequestAction{PrimaryEffect: 1, SecondaryEffect: 10}},
+		{"mine block", &protocol.MineBlockStackRequestAction{HotbarSlot: 4, PredictedDurability: 1500, StackNetworkID: -2}},
+		{"craft recipe", &protocol.CraftRecipeStackRequestAction{RecipeNetworkID: 300, NumberOfCrafts: 2}},
+		{"auto craft recipe", &protocol.AutoCraftRecipeStackRequestAction{RecipeNetworkID: 301, NumberOfCrafts: 3, TimesCrafted: 3, Ingredients: []protocol.ItemDescriptorCount{
+			{Descriptor: &protocol.DefaultItemDescriptor{NetworkID: 5, MetadataValue: 1}, Count: 4},
+			{Descriptor: &protocol.ItemTagItemDescriptor{Tag: "minecraft:planks"}, Count: 1},
+		}}},
+		{"craft creative", &protocol.CraftCreativeStackRequestAction{CreativeItemNetworkID: 1024, NumberOfCrafts: 1}},
+		{"craft recipe optional", &protocol.CraftRecipeOptionalStackRequestAction{RecipeNetworkID: 302, FilterStringIndex: 0}},
+		{"craft grindstone", &protocol.CraftGrindstoneRecipeStackRequestAction{RecipeNetworkID: 303, NumberOfCrafts: 1, Cost: -5}},
+		{"craft loom", &protocol.CraftLoomRecipeStackRequestAction{Pattern: "bri", TimesCrafted: 1}},
+		{"craft non implemented", &protocol.CraftNonImplementedStackRequestAction{}},
+		{"craft results deprecated", &protocol.CraftResultsDeprecatedStackRequestAction{ResultItems: []protocol.ItemStack{
+			{ItemType: protocol.ItemType{NetworkID: 5}, BlockRuntimeID: 2, Count: 4, NBTData: map[string]any{}, CanBePlacedOn: []string{}, CanBreak: []string{}},
+		}, TimesCrafted: 1}},
+	}
+	for _, test := range tests {
+		// The transfer actions share their fields through an embedded struct, which is set separately.
+		switch a := test.action.(type) {
+		case *protocol.TakeStackRequestAction:
+			a.Count, a.Source, a.Destination = 32, source, destination
+		case *protocol.PlaceStackRequestAction:
+			a.Count, a.Source, a.Destination = 1, destination, source
+		case *protocol.PlaceInContainerStackRequestAction:
+			a.Count, a.Source, a.Destination = 16, source, destination
+		case *protocol.TakeOutContainerStackRequestAction:
+			a.Count, a.Source, a.Destination = 8, destination, source
+		}
+		testRoundTrip(t, test.name, &ItemStackRequest{Requests: []protocol.ItemStackRequest{{
+			RequestID:     -7,
+			Actions:       []protocol.StackRequestAction{test.action},
+			FilterStrings: []string{"Renamed", "Sword"},
+			FilterCause:   protocol.FilterCauseAnvilText,
+		}}})
+	}
+
+	// All actions in a single request, in the order they are listed.
+	var actions []protocol.StackRequestAction
+	for _, test := range tests {
+		actions = append(actions, test.action)
+	}
+	testRoundTrip(t, "all actions", &ItemStackRequest{Requests: []protocol.ItemStackRequest{
+		{RequestID: -1, Actions: actions, FilterStrings: []string{}},
+		{RequestID: -3, Actions: []protocol.StackRequestAction{}, FilterStrings: []string{"a"}, FilterCause: protocol.FilterCauseSignText},
+	}})
+}
+
+func TestItemStackResponse(t *testing.T) {
+	testRoundTrip(t, "ok", &ItemStackResponse{Responses: []protocol.ItemStackResponse{{
+		Status:    protocol.ItemStackResponseStatusOK,
+		RequestID: -7,
+		ContainerInfo: []protocol.StackResponseContainerInfo{{
+			Container: protocol.FullContainerName{ContainerID: 12, DynamicContainerID: protocol.Option(uint32(3))},
+			SlotInfo: []protocol.StackResponseSlotInfo{{
+				Slot: 3, HotbarSlot: 3, Count: 32, StackNetworkID: 41, CustomName: "Sword", FilteredCustomName: "S***d", DurabilityCorrection: 100,
+			}},
+		}},
+	}}})
+	// Responses that are not OK do not hold any container info.
+	testRoundTrip(t, "error", &ItemStackResponse{Responses: []protocol.ItemStackResponse{{
+		Status:    protocol.ItemStackResponseStatusError,
+		RequestID: -9,
+	}}})
+}
@@ -0,0 +1,72 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// TestPoolsImplementAllPackets checks that no packet in the pools is decoded into an Unknown, and that every
+// packet is registered with its own ID.
+func TestPoolsImplementAllPackets(t *testing.T) {
+	for name, pool := range map[string]Pool{"client": NewClientPool(), "server": NewServerPool()} {
+		for id, f := range pool {
+			pk := f()
+			if _, ok := pk.(*Unknown); ok {
+				t.Errorf("%v pool: packet %v is decoded into an Unknown", name, id)
+			} else if pk.ID() != id {
+				t.Errorf("%v pool: %T is registered with ID %v, but has ID %v", name, pk, id, pk.ID())
+			}
+		}
+	}
+}
+
+// TestLegacyPacketRoundTrip checks that rarely used packets, such as those of Education Edition, are encoded
+// and decoded into the same packet.
+func TestLegacyPacketRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		pk   Packet
+	}{
+		{name: "photo transfer", pk: &PhotoTransfer{PhotoName: "photo", PhotoData: []byte{1, 2, 3}, BookID: "book", PhotoType: 1, SourceType: 2, OwnerEntityUniqueID: -5, NewPhotoName: "new"}},
+		{name: "empty photo transfer", pk: &PhotoTransfer{PhotoData: []byte{}}},
+		{name: "photo info request", pk: &PhotoInfoRequest{PhotoID: 1 << 40}},
+		{name: "create photo", pk: &CreatePhoto{EntityUniqueID: 7, PhotoName: "photo", ItemName: "minecraft:portfolio"}},
+		{name: "lab table", pk: &LabTable{ActionType: 1, Position: protocol.BlockPos{1, -64, 3}, ReactionType: 9}},
+		{name: "code builder", pk: &CodeBuilder{URL: "https://example.com", ShouldOpenCodeBuilder: true}},
+		{name: "education settings", pk: &EducationSettings{
+			CodeBuilderDefaultURI: "uri",
+			CodeBuilderTitle:      "title",
+			CanResizeCodeBuilder:  true,
+			CanModifyBlocks:       protocol.Option(true),
+			OverrideURI:           protocol.Option("override"),
+			HasQuiz:               true,
+			ExternalLinkSettings:  protocol.Option(protocol.EducationExternalLinkSettings{URL: "url", DisplayName: "name"}),
+		}},
+		{name: "multi player settings", pk: &MultiPlayerSettings{ActionType: 2}},
+		{name: "simple event", pk: &SimpleEvent{EventType: 1}},
+	}
+	for _, test := range tests {
+		testRoundTrip(t, test.name, test.pk)
+	}
+}
+
+// TestPhotoTransferLayout checks that PhotoTransfer is encoded using the wire layout of the game.
+func TestPhotoTransferLayout(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	pk := &PhotoTransfer{PhotoName: "a", PhotoData: []byte{0xff}, BookID: "b", PhotoType: 2, SourceType: 3, OwnerEntityUniqueID: 1, NewPhotoName: "c"}
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	want := []byte{
+		1, 'a', // PhotoName
+		1, 0xff, // PhotoData
+		1, 'b', // BookID
+		2,                      // PhotoType
+		3,                      // SourceType
+		1, 0, 0, 0, 0, 0, 0, 0, // OwnerEntityUniqueID
+		1, 'c', // NewPhotoName
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("expected %x, got %x", want, buf.Bytes())
+	}
+}
@@ -7,6 +7,9 @@ import (
 const (
 	CompressionAlgorithmFlate = iota
 	CompressionAlgorithmSnappy
+	CompressionAlgorithmZstd
+	CompressionAlgorithmLZ4
+	CompressionAlgorithmSnappyStream
 	CompressionAlgorithmNone = 0xffff
 )
 
@@ -19,6 +19,18 @@ type Packet interface {
 	Marshal(io protocol.IO)
 }
 
+// Immediate is implemented by packets that should be sent as soon as they are written, rather than buffered
+// until the next flush. An Immediate packet is sent in a batch of its own that is not compressed, which
+// reduces the latency of small, frequently sent packets such as PlayerAuthInput. Packets written before an
+// Immediate packet are always sent before it.
+// None of the packets in this package implement Immediate. Instead, a packet may be marked as Immediate by
+// embedding it in a struct that implements the Immediate method.
+type Immediate interface {
+	Packet
+	// Immediate returns true if the packet should be sent immediately and uncompressed.
+	Immediate() bool
+}
+
 // Header is the header of a packet. It exists out of a single varuint32 which is composed of a packet ID and
 // a sender and target sub client ID. These IDs are used for split screen functionality.
 type Header struct {
@@ -1,5 +1,7 @@
 package packet
 
+import "fmt"
+
 // RegisterPacketFromClient registers a function that returns a packet for a
 // specific ID. Packets with this ID coming in from connections will resolve to
 // the packet returned by the function passed. noinspection
@@ -43,6 +45,17 @@ func NewServerPool() Pool {
 	return p
 }
 
+// Register registers a function that returns a packet for a specific ID in the Pool. If the Pool already
+// holds a packet with the ID, Register returns an error, unless override is true, in which case the packet
+// already registered is replaced.
+func (p Pool) Register(id uint32, pk func() Packet, override bool) error {
+	if existing, ok := p[id]; ok && !override {
+		return fmt.Errorf("register packet: ID %v is already registered to %T", id, existing())
+	}
+	p[id] = pk
+	return nil
+}
+
 func init() {
 	// TODO: Remove packets from this list that are not sent by the server.
 	serverOriginating := map[uint32]func() Packet{
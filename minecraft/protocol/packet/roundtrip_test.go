@@ -0,0 +1,34 @@
+package packet
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// testRoundTrip encodes the packet passed, decodes it into a new packet of the same type and checks if the
+// packet decoded is equal to the packet passed and if all data written was read.
+func testRoundTrip(t *testing.T, name string, pk Packet) {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	pk.Marshal(protocol.NewWriter(buf, 0))
+	data := buf.Bytes()
+
+	decoded := reflect.New(reflect.TypeOf(pk).Elem()).Interface().(Packet)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("%v: decode %T: %v", name, pk, r)
+			}
+		}()
+		decoded.Marshal(protocol.NewReader(buf, 0, false))
+	}()
+	if buf.Len() != 0 {
+		t.Errorf("%v: %v unread bytes left after decoding %T", name, buf.Len(), pk)
+	}
+	if !reflect.DeepEqual(pk, decoded) {
+		t.Errorf("%v: %T changed after round trip (data %x):\nwant %+v\ngot  %+v", name, pk, data, pk, decoded)
+	}
+}
@@ -26,6 +26,11 @@ type Reader struct {
 	}
 	shieldID      int32
 	limitsEnabled bool
+	// sliceLimit is the maximum length of length-prefixed slices and maps read, set using LimitSlice. If 0,
+	// only the default limits apply.
+	sliceLimit uint32
+	// itemEncoding is the wire format of item stacks read, set using SetItemEncoding.
+	itemEncoding ItemEncoding
 }
 
 // NewReader creates a new Reader using the io.ByteReader passed as underlying source to read bytes from.
@@ -49,6 +54,14 @@ func (r *Reader) LimitsEnabled() bool {
 	return r.limitsEnabled
 }
 
+// LimitSlice sets the maximum length of length-prefixed slices and maps read by the Reader. If a slice or map
+// read has a length that exceeds max, the Reader panics before the slice or map is allocated. Unlike the
+// default limits, which only apply if limits are enabled, the limit set using LimitSlice applies regardless.
+// Passing 0 removes the limit.
+func (r *Reader) LimitSlice(max uint32) {
+	r.sliceLimit = max
+}
+
 // Uint8 reads a uint8 from the underlying buffer.
 func (r *Reader) Uint8(x *uint8) {
 	var err error
@@ -333,6 +346,7 @@ func (r *Reader) EntityMetadata(x *map[uint32]any) {
 
 	var count uint32
 	r.Varuint32(&count)
+	r.SliceLimit(count, maxSliceLength)
 	for i := uint32(0); i < count; i++ {
 		var key, dataType uint32
 		r.Varuint32(&key)
@@ -375,11 +389,51 @@ func (r *Reader) EntityMetadata(x *map[uint32]any) {
 			r.Vec3(&v)
 			(*x)[key] = v
 		default:
-			r.UnknownEnumOption(dataType, "entity metadata")
+			read, ok := entityDataType(dataType)
+			if !ok {
+				// The length of a value of an unknown type is not known, so it cannot be skipped or read as
+				// RawMetadata unless the type was registered.
+				r.UnknownEnumOption(dataType, "entity metadata")
+			}
+			(*x)[key] = RawMetadata{Type: dataType, Data: r.record(read)}
 		}
 	}
 }
 
+// record calls the function passed and returns the bytes it read from the underlying buffer.
+func (r *Reader) record(read func(r *Reader)) []byte {
+	rec := &recordingReader{r: r.r}
+	r.r = rec
+	defer func() { r.r = rec.r }()
+	read(r)
+	return rec.buf.Bytes()
+}
+
+// recordingReader records the bytes read from the underlying reader.
+type recordingReader struct {
+	r interface {
+		io.Reader
+		io.ByteReader
+	}
+	buf bytes.Buffer
+}
+
+// Read ...
+func (r *recordingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+// ReadByte ...
+func (r *recordingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf.WriteByte(b)
+	}
+	return b, err
+}
+
 // ItemDescriptorCount reads an ItemDescriptorCount i from the underlying buffer.
 func (r *Reader) ItemDescriptorCount(i *ItemDescriptorCount) {
 	var id uint8
@@ -409,6 +463,11 @@ func (r *Reader) ItemDescriptorCount(i *ItemDescriptorCount) {
 
 // ItemInstance reads an ItemInstance i from the underlying buffer.
 func (r *Reader) ItemInstance(i *ItemInstance) {
+	if r.itemEncoding == ItemEncodingLegacy {
+		r.Varint32(&i.StackNetworkID)
+		r.legacyItem(&i.Stack)
+		return
+	}
 	x := &i.Stack
 	x.NBTData = make(map[string]any)
 	r.Varint32(&x.NetworkID)
@@ -436,6 +495,7 @@ func (r *Reader) ItemInstance(i *ItemInstance) {
 
 	buf := bytes.NewBuffer(extraData)
 	bufReader := NewReader(buf, r.shieldID, r.limitsEnabled)
+	bufReader.sliceLimit = r.sliceLimit
 
 	var length int16
 	bufReader.Int16(&length)
@@ -466,6 +526,10 @@ func (r *Reader) ItemInstance(i *ItemInstance) {
 
 // Item reads an ItemStack x from the underlying buffer.
 func (r *Reader) Item(x *ItemStack) {
+	if r.itemEncoding == ItemEncodingLegacy {
+		r.legacyItem(x)
+		return
+	}
 	x.NBTData = make(map[string]any)
 	r.Varint32(&x.NetworkID)
 	if x.NetworkID == 0 {
@@ -484,6 +548,7 @@ func (r *Reader) Item(x *ItemStack) {
 
 	buf := bytes.NewBuffer(extraData)
 	bufReader := NewReader(buf, r.shieldID, r.limitsEnabled)
+	bufReader.sliceLimit = r.sliceLimit
 
 	var length int16
 	bufReader.Int16(&length)
@@ -596,9 +661,12 @@ func (r *Reader) Bitset(x *Bitset, size int) {
 	r.panic(errBitsetOverflow)
 }
 
-// SliceLimit checks if the value passed is lower than the limit passed. If
-// not, the Reader panics.
+// SliceLimit checks if the value passed is lower than the limit passed and
+// the limit set using LimitSlice. If not, the Reader panics.
 func (r *Reader) SliceLimit(value uint32, max uint32) {
+	if r.sliceLimit != 0 && value > r.sliceLimit {
+		r.panicf("slice length was too long: length of %v (max %v)", value, r.sliceLimit)
+	}
 	if value > max && r.limitsEnabled {
 		r.panicf("slice length was too long: length of %v (max %v)", value, max)
 	}
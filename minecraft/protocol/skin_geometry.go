@@ -0,0 +1,207 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// maxSkinGeometries is the maximum amount of geometries that the geometry of a skin may hold.
+	maxSkinGeometries = 32
+	// maxSkinGeometryBones is the maximum amount of bones that a single geometry may hold.
+	maxSkinGeometryBones = 256
+	// maxSkinGeometryCubes is the maximum amount of cubes that the bones of a single geometry may hold
+	// together.
+	maxSkinGeometryCubes = 2048
+	// maxSkinGeometryCoordinate is the maximum absolute value of the coordinates, sizes and rotations found
+	// in a geometry.
+	maxSkinGeometryCoordinate = 4096
+	// maxSkinGeometryTextureSize is the maximum width and height of the texture of a geometry.
+	maxSkinGeometryTextureSize = 4096
+)
+
+// ValidateSkinGeometry checks if the JSON encoded skin geometry passed, as found in the SkinGeometry field of a
+// Skin, is well-formed and within sane limits. It checks the amount of geometries, bones and cubes, the
+// bounds of the values of bones and cubes, and the parents of bones, which must exist and must not form a
+// cycle. ValidateSkinGeometry may be used to prevent forwarding a skin sent by a modified client that would
+// crash other clients.
+// Both the geometry format of format_version 1.12.0 and later, which holds a 'minecraft:geometry' list, and
+// the legacy format, which holds an object for every geometry keyed by its 'geometry.' prefixed identifier,
+// are accepted. Empty data is valid and means the skin does not have custom geometry.
+func ValidateSkinGeometry(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("decode skin geometry: %w", err)
+	}
+	geometries := make(map[string]skinGeometry)
+	if raw, ok := m["minecraft:geometry"]; ok {
+		var list []struct {
+			Description struct {
+				Identifier    string  `json:"identifier"`
+				TextureWidth  float64 `json:"texture_width"`
+				TextureHeight float64 `json:"texture_height"`
+			} `json:"description"`
+			Bones []skinGeometryBone `json:"bones"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("decode minecraft:geometry: %w", err)
+		}
+		if len(list) > maxSkinGeometries {
+			return fmt.Errorf("skin geometry holds %v geometries, but at most %v are allowed", len(list), maxSkinGeometries)
+		}
+		for i, g := range list {
+			if g.Description.Identifier == "" {
+				return fmt.Errorf("geometry %v has no identifier", i)
+			}
+			geometries[g.Description.Identifier] = skinGeometry{TextureWidth: g.Description.TextureWidth, TextureHeight: g.Description.TextureHeight, Bones: g.Bones}
+		}
+	} else {
+		for key, raw := range m {
+			if !strings.HasPrefix(key, "geometry.") {
+				// Other fields, such as format_version, hold no geometry.
+				continue
+			}
+			if len(geometries) == maxSkinGeometries {
+				return fmt.Errorf("skin geometry holds more than %v geometries", maxSkinGeometries)
+			}
+			var g skinGeometry
+			if err := json.Unmarshal(raw, &g); err != nil {
+				return fmt.Errorf("decode %v: %w", key, err)
+			}
+			geometries[key] = g
+		}
+	}
+	for identifier, g := range geometries {
+		if err := g.validate(); err != nil {
+			return fmt.Errorf("geometry %v: %w", identifier, err)
+		}
+	}
+	return nil
+}
+
+// skinGeometry is a single geometry found in the geometry of a skin. The JSON tags are those of the legacy
+// format: The fields of the format of format_version 1.12.0 and later are decoded separately.
+type skinGeometry struct {
+	TextureWidth  float64            `json:"texturewidth"`
+	TextureHeight float64            `json:"textureheight"`
+	Bones         []skinGeometryBone `json:"bones"`
+}
+
+// skinGeometryBone is a bone of a skinGeometry. The fields of bones are the same in all formats.
+type skinGeometryBone struct {
+	Name     string             `json:"name"`
+	Parent   string             `json:"parent"`
+	Pivot    []float64          `json:"pivot"`
+	Rotation []float64          `json:"rotation"`
+	Cubes    []skinGeometryCube `json:"cubes"`
+}
+
+// skinGeometryCube is a cube of a skinGeometryBone. The UV of a cube is not validated, as it may either be a
+// list or an object holding the UV of every face.
+type skinGeometryCube struct {
+	Origin   []float64 `json:"origin"`
+	Size     []float64 `json:"size"`
+	Pivot    []float64 `json:"pivot"`
+	Rotation []float64 `json:"rotation"`
+	Inflate  float64   `json:"inflate"`
+}
+
+// validate checks the bones and cubes of the skinGeometry, returning an error if one of them is not valid.
+func (g skinGeometry) validate() error {
+	if err := checkGeometryValues("texture size", g.TextureWidth, g.TextureHeight); err != nil {
+		return err
+	}
+	if g.TextureWidth < 0 || g.TextureHeight < 0 || g.TextureWidth > maxSkinGeometryTextureSize || g.TextureHeight > maxSkinGeometryTextureSize {
+		return fmt.Errorf("invalid texture size %vx%v", g.TextureWidth, g.TextureHeight)
+	}
+	if len(g.Bones) > maxSkinGeometryBones {
+		return fmt.Errorf("geometry holds %v bones, but at most %v are allowed", len(g.Bones), maxSkinGeometryBones)
+	}
+	parents, cubes := make(map[string]string, len(g.Bones)), 0
+	for i, bone := range g.Bones {
+		if bone.Name == "" {
+			return fmt.Errorf("bone %v has no name", i)
+		}
+		if _, ok := parents[bone.Name]; ok {
+			return fmt.Errorf("bone %v: duplicate bone name", bone.Name)
+		}
+		parents[bone.Name] = bone.Parent
+
+		if err := checkGeometryVector("pivot", bone.Pivot); err != nil {
+			return fmt.Errorf("bone %v: %w", bone.Name, err)
+		}
+		if err := checkGeometryVector("rotation", bone.Rotation); err != nil {
+			return fmt.Errorf("bone %v: %w", bone.Name, err)
+		}
+		if cubes += len(bone.Cubes); cubes > maxSkinGeometryCubes {
+			return fmt.Errorf("geometry holds more than %v cubes", maxSkinGeometryCubes)
+		}
+		for j, cube := range bone.Cubes {
+			if err := cube.validate(); err != nil {
+				return fmt.Errorf("bone %v: cube %v: %w", bone.Name, j, err)
+			}
+		}
+	}
+	for name := range parents {
+		// Follow the parents of the bone up to the root. Every bone may be visited at most once: If more
+		// bones are visited than the geometry holds, the parents form a cycle.
+		for current, depth := name, 0; parents[current] != ""; depth++ {
+			parent := parents[current]
+			if _, ok := parents[parent]; !ok {
+				return fmt.Errorf("bone %v: parent %v does not exist", current, parent)
+			}
+			if depth == len(parents) {
+				return fmt.Errorf("bone %v: parents form a cycle", name)
+			}
+			current = parent
+		}
+	}
+	return nil
+}
+
+// validate checks the vectors of the skinGeometryCube, returning an error if one of them is not valid.
+func (c skinGeometryCube) validate() error {
+	if err := checkGeometryVector("origin", c.Origin); err != nil {
+		return err
+	}
+	if err := checkGeometryVector("size", c.Size); err != nil {
+		return err
+	}
+	for _, v := range c.Size {
+		if v < 0 {
+			return fmt.Errorf("negative size %v", c.Size)
+		}
+	}
+	if err := checkGeometryVector("pivot", c.Pivot); err != nil {
+		return err
+	}
+	if err := checkGeometryVector("rotation", c.Rotation); err != nil {
+		return err
+	}
+	return checkGeometryValues("inflate", c.Inflate)
+}
+
+// checkGeometryVector checks if the vector passed is either absent or holds three values within the bounds
+// of a geometry.
+func checkGeometryVector(name string, v []float64) error {
+	if v != nil && len(v) != 3 {
+		return fmt.Errorf("%v must hold 3 values, but got %v", name, len(v))
+	}
+	return checkGeometryValues(name, v...)
+}
+
+// checkGeometryValues checks if all values passed are within the bounds of a geometry.
+func checkGeometryValues(name string, values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || math.Abs(v) > maxSkinGeometryCoordinate {
+			return fmt.Errorf("%v %v exceeds the maximum of %v", name, values, maxSkinGeometryCoordinate)
+		}
+	}
+	return nil
+}
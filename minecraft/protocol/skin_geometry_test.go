@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// modernGeometry is a skin geometry of format_version 1.12.0 with a head bone attached to a body bone.
+const modernGeometry = `{
+	"format_version": "1.12.0",
+	"minecraft:geometry": [{
+		"description": {"identifier": "geometry.humanoid.custom", "texture_width": 64, "texture_height": 64},
+		"bones": [
+			{"name": "body", "pivot": [0, 24, 0], "cubes": [{"origin": [-4, 12, -2], "size": [8, 12, 4], "uv": [16, 16]}]},
+			{"name": "head", "parent": "body", "pivot": [0, 24, 0], "rotation": [0, 0, 0], "cubes": [
+				{"origin": [-4, 24, -4], "size": [8, 8, 8], "inflate": 0.5, "uv": {"north": {"uv": [8, 8], "uv_size": [8, 8]}}}
+			]}
+		]
+	}]
+}`
+
+// legacyGeometry is a skin geometry of the legacy format, which holds an object for every geometry.
+const legacyGeometry = `{
+	"format_version": "1.8.0",
+	"geometry.humanoid.custom": {
+		"texturewidth": 64,
+		"textureheight": 64,
+		"bones": [
+			{"name": "body", "pivot": [0, 24, 0], "cubes": [{"origin": [-4, 12, -2], "size": [8, 12, 4], "uv": [16, 16]}]},
+			{"name": "head", "parent": "body", "pivot": [0, 24, 0]}
+		]
+	}
+}`
+
+func TestSkinGeometryRoundTrip(t *testing.T) {
+	for name, geometry := range map[string]string{"modern": modernGeometry, "legacy": legacyGeometry} {
+		if err := ValidateSkinGeometry([]byte(geometry)); err != nil {
+			t.Fatalf("%v: expected geometry to be valid, got %v", name, err)
+		}
+
+		// Decoding and encoding the geometry again must keep it valid and unchanged.
+		var v any
+		if err := json.Unmarshal([]byte(geometry), &v); err != nil {
+			t.Fatalf("%v: parse: %v", name, err)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%v: marshal: %v", name, err)
+		}
+		if err := ValidateSkinGeometry(data); err != nil {
+			t.Errorf("%v: expected geometry to be valid after encoding it again, got %v", name, err)
+		}
+		var again any
+		if err := json.Unmarshal(data, &again); err != nil || !reflect.DeepEqual(again, v) {
+			t.Errorf("%v: expected geometry to be unchanged after encoding it again, got %v (%v)", name, again, err)
+		}
+
+		// The geometry sent in a Skin must be read back unchanged.
+		skin := Skin{SkinID: "id", SkinImageWidth: 1, SkinImageHeight: 1, SkinData: make([]byte, 4), SkinGeometry: data}
+		buf := bytes.NewBuffer(nil)
+		skin.Marshal(NewWriter(buf, 0))
+		var got Skin
+		got.Marshal(NewReader(buf, 0, false))
+		if !bytes.Equal(got.SkinGeometry, data) {
+			t.Errorf("%v: expected skin geometry %s, got %s", name, data, got.SkinGeometry)
+		}
+		if err := ValidateSkinGeometry(got.SkinGeometry); err != nil {
+			t.Errorf("%v: expected skin geometry read to be valid, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateSkinGeometry(t *testing.T) {
+	// geometry returns a modern skin geometry holding the bones passed.
+	geometry := func(bones string) string {
+		return `{"minecraft:geometry": [{"description": {"identifier": "geometry.test", "texture_width": 64, "texture_height": 64}, "bones": [` + bones + `]}]}`
+	}
+	many := func(n int, format string) string {
+		s := make([]string, n)
+		for i := range s {
+			s[i] = fmt.Sprintf(format, i)
+		}
+		return strings.Join(s, ",")
+	}
+	tests := map[string]struct {
+		geometry string
+		valid    bool
+	}{
+		"empty":                 {geometry: " ", valid: true},
+		"no geometry":           {geometry: `{"format_version": "1.8.0"}`, valid: true},
+		"malformed":             {geometry: `{"minecraft:geometry": [`},
+		"no identifier":         {geometry: `{"minecraft:geometry": [{"bones": []}]}`},
+		"too many geometries":   {geometry: `{"minecraft:geometry": [` + many(maxSkinGeometries+1, `{"description": {"identifier": "geometry.%v"}}`) + `]}`},
+		"texture too large":     {geometry: `{"minecraft:geometry": [{"description": {"identifier": "geometry.test", "texture_width": 8192, "texture_height": 64}}]}`},
+		"too many bones":        {geometry: geometry(many(maxSkinGeometryBones+1, `{"name": "bone%v"}`))},
+		"too many cubes":        {geometry: geometry(`{"name": "body", "cubes": [` + many(maxSkinGeometryCubes+1, `{"size": [%v, 1, 1]}`) + `]}`)},
+		"bone without name":     {geometry: geometry(`{"pivot": [0, 0, 0]}`)},
+		"duplicate bone":        {geometry: geometry(`{"name": "a"}, {"name": "a"}`)},
+		"missing parent":        {geometry: geometry(`{"name": "a", "parent": "b"}`)},
+		"parent cycle":          {geometry: geometry(`{"name": "a", "parent": "b"}, {"name": "b", "parent": "a"}`)},
+		"self parent":           {geometry: geometry(`{"name": "a", "parent": "a"}`)},
+		"short pivot":           {geometry: geometry(`{"name": "a", "pivot": [0, 0]}`)},
+		"coordinate too large":  {geometry: geometry(`{"name": "a", "rotation": [0, 100000, 0]}`)},
+		"negative cube size":    {geometry: geometry(`{"name": "a", "cubes": [{"size": [1, -1, 1]}]}`)},
+		"inflate too large":     {geometry: geometry(`{"name": "a", "cubes": [{"inflate": 5000}]}`)},
+		"legacy malformed bone": {geometry: `{"geometry.test": {"bones": [{"name": 1}]}}`},
+		"legacy cycle":          {geometry: `{"geometry.test": {"bones": [{"name": "a", "parent": "b"}, {"name": "b", "parent": "a"}]}}`},
+	}
+	for name, test := range tests {
+		err := ValidateSkinGeometry([]byte(test.geometry))
+		if test.valid && err != nil {
+			t.Errorf("%v: expected geometry to be valid, got %v", name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v: expected geometry to be invalid", name)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// SubChunkData is a decoded sub-chunk: A 16x16x16 area of blocks, as sent in a SubChunk packet.
+type SubChunkData struct {
+	// Position is the absolute position of the sub-chunk.
+	Position SubChunkPos
+	// Layers holds the layers of blocks of the sub-chunk. The first layer holds the regular blocks, while
+	// the second layer, if present, typically holds liquids that are in the same position as the blocks of
+	// the first layer. Layers is empty if the sub-chunk is all air.
+	Layers []BlockStorage
+	// BlockEntities holds the NBT of the block entities, such as chests and signs, in the sub-chunk.
+	BlockEntities []map[string]any
+}
+
+// RuntimeID returns the runtime ID of the block at the position passed in the layer passed. The coordinates
+// are relative to the sub-chunk and must be lower than 16. False is returned if the sub-chunk has no layer
+// with the index passed, in which case the block should be considered air.
+func (s *SubChunkData) RuntimeID(x, y, z uint8, layer int) (uint32, bool) {
+	if layer >= len(s.Layers) {
+		return 0, false
+	}
+	return s.Layers[layer].RuntimeID(x, y, z), true
+}
+
+// BlockStorage is a layer of 16x16x16 blocks of a sub-chunk. The blocks are stored as indices into a palette
+// of block runtime IDs, packed into 32-bit words. A BlockStorage is created using NewBlockStorage, or, if it
+// holds only one type of block, as a BlockStorage with only a Palette holding its runtime ID.
+type BlockStorage struct {
+	// Palette holds the runtime IDs of the blocks in the BlockStorage.
+	Palette []uint32
+
+	bitsPerBlock int
+	words        []uint32
+}
+
+// RuntimeID returns the runtime ID of the block at the position passed. The coordinates are relative to the
+// sub-chunk and must be lower than 16.
+func (s BlockStorage) RuntimeID(x, y, z uint8) uint32 {
+	if s.bitsPerBlock == 0 {
+		return s.Palette[0]
+	}
+	// Blocks are ordered by X, then Z, then Y, and a block never spans two words.
+	index := int(x&15)<<8 | int(z&15)<<4 | int(y&15)
+	perWord := 32 / s.bitsPerBlock
+	word := s.words[index/perWord]
+	offset := (index % perWord) * s.bitsPerBlock
+	if i := int(word>>offset) & (1<<s.bitsPerBlock - 1); i < len(s.Palette) {
+		return s.Palette[i]
+	}
+	// The index is out of the range of the palette, which is invalid, but should not lead to a panic.
+	return s.Palette[0]
+}
+
+// DecodeSubChunk decodes a sub-chunk at the position passed from the data passed, which is the RawPayload of
+// a SubChunkEntry with the result SubChunkResultSuccess. The data holds the sub-chunk serialised using
+// network runtime IDs, followed by the NBT of the block entities in the sub-chunk. If the blob cache is
+// enabled, the sub-chunk is not present in the RawPayload, but in the blob with the BlobHash of the entry.
+func DecodeSubChunk(pos SubChunkPos, data []byte) (*SubChunkData, error) {
+	buf := bytes.NewBuffer(data)
+	s := &SubChunkData{Position: pos}
+
+	version, err := buf.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read sub-chunk version: %w", err)
+	}
+	layers := byte(1)
+	switch version {
+	case 1:
+		// Version 1 sub-chunks have only one layer, the count of which is not written.
+	case 8, 9:
+		if layers, err = buf.ReadByte(); err != nil {
+			return nil, fmt.Errorf("read sub-chunk layer count: %w", err)
+		}
+		if version == 9 {
+			// Version 9 sub-chunks hold their Y index, which is equal to that in the position passed.
+			if _, err := buf.ReadByte(); err != nil {
+				return nil, fmt.Errorf("read sub-chunk index: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown sub-chunk version %v", version)
+	}
+	for i := byte(0); i < layers; i++ {
+		storage, err := decodeBlockStorage(buf)
+		if err != nil {
+			return nil, fmt.Errorf("decode layer %v: %w", i, err)
+		}
+		s.Layers = append(s.Layers, storage)
+	}
+
+	dec := nbt.NewDecoder(buf)
+	for buf.Len() != 0 {
+		var blockEntity map[string]any
+		if err := dec.Decode(&blockEntity); err != nil {
+			return nil, fmt.Errorf("decode block entity: %w", err)
+		}
+		s.BlockEntities = append(s.BlockEntities, blockEntity)
+	}
+	return s, nil
+}
+
+// decodeBlockStorage decodes a single BlockStorage with a palette of network runtime IDs from the buffer.
+func decodeBlockStorage(buf *bytes.Buffer) (BlockStorage, error) {
+	header, err := buf.ReadByte()
+	if err != nil {
+		return BlockStorage{}, fmt.Errorf("read storage header: %w", err)
+	}
+	if header&1 == 0 {
+		return BlockStorage{}, fmt.Errorf("storage palette does not hold runtime IDs")
+	}
+	s := BlockStorage{bitsPerBlock: int(header >> 1)}
+	switch s.bitsPerBlock {
+	case 0, 1, 2, 3, 4, 5, 6, 8, 16:
+	default:
+		return BlockStorage{}, fmt.Errorf("invalid bits per block %v", s.bitsPerBlock)
+	}
+	if s.bitsPerBlock != 0 {
+		perWord := 32 / s.bitsPerBlock
+		s.words = make([]uint32, (4096+perWord-1)/perWord)
+		if buf.Len() < len(s.words)*4 {
+			return BlockStorage{}, fmt.Errorf("storage words: %v bytes needed, %v remaining", len(s.words)*4, buf.Len())
+		}
+		for i := range s.words {
+			s.words[i] = binary.LittleEndian.Uint32(buf.Next(4))
+		}
+	}
+
+	// A storage with 0 bits per block holds exactly one block, the palette size of which is not written.
+	paletteSize := int32(1)
+	if s.bitsPerBlock != 0 {
+		if err := Varint32(buf, &paletteSize); err != nil {
+			return BlockStorage{}, fmt.Errorf("read palette size: %w", err)
+		}
+		if paletteSize <= 0 || paletteSize > 1<<s.bitsPerBlock {
+			return BlockStorage{}, fmt.Errorf("invalid palette size %v for %v bits per block", paletteSize, s.bitsPerBlock)
+		}
+	}
+	s.Palette = make([]uint32, paletteSize)
+	for i := range s.Palette {
+		var rid int32
+		if err := Varint32(buf, &rid); err != nil {
+			return BlockStorage{}, fmt.Errorf("read palette entry: %w", err)
+		}
+		s.Palette[i] = uint32(rid)
+	}
+	return s, nil
+}
+
+// NewBlockStorage creates a BlockStorage holding the runtime IDs returned by the function passed for every
+// position in a sub-chunk. The palette of the BlockStorage holds every runtime ID returned once, and the
+// smallest number of bits per block able to index the palette is used.
+func NewBlockStorage(runtimeID func(x, y, z uint8) uint32) BlockStorage {
+	var s BlockStorage
+	indices := make([]uint32, 4096)
+	paletteIndices := make(map[uint32]uint32)
+	for index := range indices {
+		rid := runtimeID(uint8(index>>8), uint8(index)&15, uint8(index>>4)&15)
+		i, ok := paletteIndices[rid]
+		if !ok {
+			i = uint32(len(s.Palette))
+			paletteIndices[rid] = i
+			s.Palette = append(s.Palette, rid)
+		}
+		indices[index] = i
+	}
+	for _, bits := range []int{0, 1, 2, 3, 4, 5, 6, 8, 16} {
+		if 1<<bits >= len(s.Palette) {
+			s.bitsPerBlock = bits
+			break
+		}
+	}
+	if s.bitsPerBlock == 0 {
+		return s
+	}
+	perWord := 32 / s.bitsPerBlock
+	s.words = make([]uint32, (4096+perWord-1)/perWord)
+	for index, i := range indices {
+		s.words[index/perWord] |= i << ((index % perWord) * s.bitsPerBlock)
+	}
+	return s
+}
+
+// EncodeSubChunk encodes the sub-chunk passed into the data of a SubChunkEntry with the result
+// SubChunkResultSuccess, holding the sub-chunk serialised using network runtime IDs, followed by the NBT of
+// its block entities. EncodeSubChunk is the inverse of DecodeSubChunk. The sub-chunk is encoded using the
+// current version of the sub-chunk format, which holds the Y index of the sub-chunk.
+func EncodeSubChunk(s *SubChunkData) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := encodeSubChunk(buf, s); err != nil {
+		return nil, err
+	}
+	if err := encodeBlockEntities(buf, s.BlockEntities); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeLevelChunk encodes the sub-chunks and biomes passed into the RawPayload of a LevelChunk packet with
+// the blob cache disabled. It returns the payload and the number of sub-chunks written, which should be set
+// as the SubChunkCount of the packet. The sub-chunks must be ordered from the bottom of the dimension
+// upwards and may not be nil, while sub-chunks above them may be left out. Biomes holds the biomes of every
+// sub-chunk of the dimension from the bottom upwards, which is 24 for the overworld, 8 for the nether and 16
+// for the end, using biome IDs rather than block runtime IDs as palette. The block entities of all
+// sub-chunks are written at the end of the payload.
+func EncodeLevelChunk(subChunks []*SubChunkData, biomes []BlockStorage) ([]byte, int, error) {
+	buf := bytes.NewBuffer(nil)
+	var blockEntities []map[string]any
+	for i, s := range subChunks {
+		if s == nil {
+			return nil, 0, fmt.Errorf("sub-chunk %v is nil", i)
+		}
+		if err := encodeSubChunk(buf, s); err != nil {
+			return nil, 0, fmt.Errorf("encode sub-chunk %v: %w", i, err)
+		}
+		blockEntities = append(blockEntities, s.BlockEntities...)
+	}
+	for i, biome := range biomes {
+		if err := encodeBlockStorage(buf, biome); err != nil {
+			return nil, 0, fmt.Errorf("encode biomes %v: %w", i, err)
+		}
+	}
+	// The number of border blocks, which are only used in education edition.
+	buf.WriteByte(0)
+	if err := encodeBlockEntities(buf, blockEntities); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(subChunks), nil
+}
+
+// encodeSubChunk writes the sub-chunk passed without its block entities to the buffer.
+func encodeSubChunk(buf *bytes.Buffer, s *SubChunkData) error {
+	if len(s.Layers) > 255 {
+		return fmt.Errorf("sub-chunk has %v layers, at most 255 are allowed", len(s.Layers))
+	}
+	buf.WriteByte(9)
+	buf.WriteByte(byte(len(s.Layers)))
+	buf.WriteByte(byte(s.Position[1]))
+	for i, storage := range s.Layers {
+		if err := encodeBlockStorage(buf, storage); err != nil {
+			return fmt.Errorf("encode layer %v: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// encodeBlockStorage writes a single BlockStorage with a palette of network runtime IDs to the buffer.
+func encodeBlockStorage(buf *bytes.Buffer, s BlockStorage) error {
+	if len(s.Palette) == 0 {
+		return fmt.Errorf("storage palette is empty")
+	}
+	if s.bitsPerBlock == 0 {
+		buf.WriteByte(1)
+		return WriteVarint32(buf, int32(s.Palette[0]))
+	}
+	if len(s.Palette) > 1<<s.bitsPerBlock {
+		return fmt.Errorf("invalid palette size %v for %v bits per block", len(s.Palette), s.bitsPerBlock)
+	}
+	buf.WriteByte(byte(s.bitsPerBlock<<1) | 1)
+	for _, word := range s.words {
+		buf.Write(binary.LittleEndian.AppendUint32(nil, word))
+	}
+	if err := WriteVarint32(buf, int32(len(s.Palette))); err != nil {
+		return err
+	}
+	for _, rid := range s.Palette {
+		if err := WriteVarint32(buf, int32(rid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeBlockEntities writes the NBT of the block entities passed to the buffer.
+func encodeBlockEntities(buf *bytes.Buffer, blockEntities []map[string]any) error {
+	enc := nbt.NewEncoder(buf)
+	for _, blockEntity := range blockEntities {
+		if err := enc.Encode(blockEntity); err != nil {
+			return fmt.Errorf("encode block entity: %w", err)
+		}
+	}
+	return nil
+}
+
+// ChunkColumn is a column of sub-chunks at a chunk position, assembled from the SubChunkEntries of one or
+// more SubChunk packets using AssembleChunkColumns.
+type ChunkColumn struct {
+	// Position is the position of the chunk column.
+	Position ChunkPos
+	// SubChunks holds the sub-chunks received for the column, indexed by their absolute Y index. Sub-chunks
+	// that were reported as all air are present without any layers.
+	SubChunks map[int32]*SubChunkData
+	// Missing holds the absolute Y indices of the sub-chunks requested that the server could not provide, for
+	// example because the chunk was not yet generated. These sub-chunks may be requested again later.
+	Missing []int32
+}
+
+// AssembleChunkColumns decodes the SubChunkEntries passed, which are positioned relative to the center
+// passed, and groups them into ChunkColumns by their chunk position. The center and entries are typically
+// the Position and SubChunkEntries of a SubChunk packet. Columns already assembled may be passed in the map
+// to add the entries of another SubChunk packet to them. If the map passed is nil, a new map is created.
+// Entries with the result SubChunkResultSuccessAllAir are added as sub-chunks without layers, while entries
+// with any other result than SubChunkResultSuccess are added to the Missing sub-chunks of their column.
+// AssembleChunkColumns requires the blob cache to be disabled, as the sub-chunks are otherwise not part of
+// the entries. An error is returned if one of the sub-chunks could not be decoded.
+func AssembleChunkColumns(columns map[ChunkPos]*ChunkColumn, center SubChunkPos, entries []SubChunkEntry) (map[ChunkPos]*ChunkColumn, error) {
+	if columns == nil {
+		columns = make(map[ChunkPos]*ChunkColumn)
+	}
+	for _, entry := range entries {
+		pos := SubChunkPos{
+			center[0] + int32(entry.Offset[0]),
+			center[1] + int32(entry.Offset[1]),
+			center[2] + int32(entry.Offset[2]),
+		}
+		chunkPos := ChunkPos{pos[0], pos[2]}
+		column, ok := columns[chunkPos]
+		if !ok {
+			column = &ChunkColumn{Position: chunkPos, SubChunks: make(map[int32]*SubChunkData)}
+			columns[chunkPos] = column
+		}
+		switch entry.Result {
+		case SubChunkResultSuccess:
+			s, err := DecodeSubChunk(pos, entry.RawPayload)
+			if err != nil {
+				return columns, fmt.Errorf("decode sub-chunk at %v: %w", pos, err)
+			}
+			column.SubChunks[pos[1]] = s
+		case SubChunkResultSuccessAllAir:
+			column.SubChunks[pos[1]] = &SubChunkData{Position: pos}
+		default:
+			if !slices.Contains(column.Missing, pos[1]) {
+				column.Missing = append(column.Missing, pos[1])
+			}
+			continue
+		}
+		// The sub-chunk may have been missing in an earlier SubChunk packet.
+		column.Missing = slices.DeleteFunc(column.Missing, func(y int32) bool { return y == pos[1] })
+	}
+	return columns, nil
+}
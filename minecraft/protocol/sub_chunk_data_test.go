@@ -0,0 +1,111 @@
+package protocol
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// testSubChunk returns a sub-chunk at the position passed, holding a block with a runtime ID depending on its
+// position and a block entity.
+func testSubChunk(pos SubChunkPos) *SubChunkData {
+	return &SubChunkData{
+		Position: pos,
+		Layers: []BlockStorage{
+			NewBlockStorage(func(x, y, z uint8) uint32 { return uint32(x+y+z) % 5 }),
+			NewBlockStorage(func(x, y, z uint8) uint32 { return 0 }),
+		},
+		BlockEntities: []map[string]any{{"id": "Chest", "x": pos[0] * 16, "y": pos[1] * 16, "z": pos[2] * 16}},
+	}
+}
+
+func TestSubChunkRoundTrip(t *testing.T) {
+	want := testSubChunk(SubChunkPos{1, -4, 2})
+	data, err := EncodeSubChunk(want)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got, err := DecodeSubChunk(want.Position, data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDecodeSubChunk(t *testing.T) {
+	// A version 8 sub-chunk with one layer of a single block with runtime ID 10, holding no block entities.
+	s, err := DecodeSubChunk(SubChunkPos{}, []byte{8, 1, 0x01, 0x14})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rid, ok := s.RuntimeID(3, 4, 5, 0); !ok || rid != 10 {
+		t.Errorf("expected runtime ID 10, got %v (%v)", rid, ok)
+	}
+	if _, ok := s.RuntimeID(3, 4, 5, 1); ok {
+		t.Error("expected no second layer")
+	}
+
+	for _, data := range [][]byte{nil, {7}, {8}, {8, 1}, {8, 1, 0x00, 0x14}, {8, 1, 0x0f}, {8, 1, 0x03, 0x00}} {
+		if _, err := DecodeSubChunk(SubChunkPos{}, data); err == nil {
+			t.Errorf("expected error decoding %x", data)
+		}
+	}
+}
+
+func TestAssembleChunkColumns(t *testing.T) {
+	center := SubChunkPos{4, 2, -3}
+	data, err := EncodeSubChunk(testSubChunk(SubChunkPos{4, 2, -3}))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	entries := []SubChunkEntry{
+		{Offset: SubChunkOffset{0, 0, 0}, Result: SubChunkResultSuccess, RawPayload: data},
+		{Offset: SubChunkOffset{0, 1, 0}, Result: SubChunkResultSuccessAllAir},
+		{Offset: SubChunkOffset{0, -1, 0}, Result: SubChunkResultChunkNotFound},
+		{Offset: SubChunkOffset{1, 0, 0}, Result: SubChunkResultChunkNotFound},
+		{Offset: SubChunkOffset{1, 0, 0}, Result: SubChunkResultChunkNotFound},
+		{Offset: SubChunkOffset{0, 20, 0}, Result: SubChunkResultIndexOutOfBounds},
+	}
+	columns, err := AssembleChunkColumns(nil, center, entries)
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if len(columns) != 2 {
+		t.Fatalf("expected 2 columns, got %v", len(columns))
+	}
+
+	column := columns[ChunkPos{4, -3}]
+	if column == nil || column.Position != (ChunkPos{4, -3}) {
+		t.Fatalf("expected column at 4, -3, got %+v", column)
+	}
+	if len(column.SubChunks) != 2 {
+		t.Fatalf("expected 2 sub-chunks, got %v", len(column.SubChunks))
+	}
+	if rid, ok := column.SubChunks[2].RuntimeID(1, 2, 3, 0); !ok || rid != 1 {
+		t.Errorf("expected runtime ID 1, got %v (%v)", rid, ok)
+	}
+	if air := column.SubChunks[3]; air.Position != (SubChunkPos{4, 3, -3}) || len(air.Layers) != 0 {
+		t.Errorf("expected all air sub-chunk at 4, 3, -3 without layers, got %+v", air)
+	}
+	if !slices.Equal(column.Missing, []int32{1, 22}) {
+		t.Errorf("expected missing sub-chunks [1 22], got %v", column.Missing)
+	}
+	if other := columns[ChunkPos{5, -3}]; len(other.SubChunks) != 0 || !slices.Equal(other.Missing, []int32{2}) {
+		t.Errorf("expected column at 5, -3 with only missing sub-chunk 2, got %+v", other)
+	}
+
+	// A later SubChunk packet provides a sub-chunk that was missing before.
+	columns, err = AssembleChunkColumns(columns, SubChunkPos{5, 2, -3}, []SubChunkEntry{{Result: SubChunkResultSuccessAllAir}})
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	if other := columns[ChunkPos{5, -3}]; len(other.SubChunks) != 1 || len(other.Missing) != 0 {
+		t.Errorf("expected column at 5, -3 with one sub-chunk and none missing, got %+v", other)
+	}
+
+	if _, err := AssembleChunkColumns(nil, center, []SubChunkEntry{{Result: SubChunkResultSuccess, RawPayload: []byte{7}}}); err == nil {
+		t.Error("expected error assembling invalid sub-chunk")
+	}
+}
@@ -25,6 +25,8 @@ type Writer struct {
 		io.ByteWriter
 	}
 	shieldID int32
+	// itemEncoding is the wire format of item stacks written, set using SetItemEncoding.
+	itemEncoding ItemEncoding
 }
 
 // NewWriter creates a new initialised Writer with an underlying io.ByteWriter to write to.
@@ -35,6 +37,26 @@ func NewWriter(w interface {
 	return &Writer{w: w, shieldID: shieldID}
 }
 
+// Reset resets the Writer to write to the underlying writer passed, using the shield ID passed, as if it
+// were newly created using NewWriter. Reset replaces the underlying writer and the shield ID of the Writer
+// and restores the default ItemEncoding, so that a Writer may be reused for encoding packets, for example
+// using a sync.Pool:
+//
+//	w := writerPool.Get().(*protocol.Writer)
+//	w.Reset(buf, shieldID)
+//	pk.Marshal(w)
+//	w.Reset(nil, 0)
+//	writerPool.Put(w)
+//
+// Resetting the Writer does not reset the underlying writer: A *bytes.Buffer passed must be reset
+// separately before it is reused.
+func (w *Writer) Reset(writer interface {
+	io.Writer
+	io.ByteWriter
+}, shieldID int32) {
+	w.w, w.shieldID, w.itemEncoding = writer, shieldID, ItemEncodingCurrent
+}
+
 // Uint8 writes a uint8 to the underlying buffer.
 func (w *Writer) Uint8(x *uint8) {
 	_ = w.w.WriteByte(*x)
@@ -268,6 +290,9 @@ func (w *Writer) EntityMetadata(x *map[uint32]any) {
 			entityDataTypeVec3 := EntityDataTypeVec3
 			w.Varuint32(&entityDataTypeVec3)
 			w.Vec3(&v)
+		case RawMetadata:
+			w.Varuint32(&v.Type)
+			w.Bytes(&v.Data)
 		default:
 			w.UnknownEnumOption(reflect.TypeOf(value), "entity metadata")
 		}
@@ -302,6 +327,11 @@ func (w *Writer) ItemDescriptorCount(i *ItemDescriptorCount) {
 
 // ItemInstance writes an ItemInstance i to the underlying buffer.
 func (w *Writer) ItemInstance(i *ItemInstance) {
+	if w.itemEncoding == ItemEncodingLegacy {
+		w.Varint32(&i.StackNetworkID)
+		w.legacyItem(&i.Stack)
+		return
+	}
 	x := &i.Stack
 	w.Varint32(&x.NetworkID)
 	if x.NetworkID == 0 {
@@ -350,6 +380,10 @@ func (w *Writer) ItemInstance(i *ItemInstance) {
 
 // Item writes an ItemStack x to the underlying buffer.
 func (w *Writer) Item(x *ItemStack) {
+	if w.itemEncoding == ItemEncodingLegacy {
+		w.legacyItem(x)
+		return
+	}
 	w.Varint32(&x.NetworkID)
 	if x.NetworkID == 0 {
 		// The item was air, so there's no more data to follow. Return immediately.
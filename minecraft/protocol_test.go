@@ -0,0 +1,64 @@
+package minecraft_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// translatingProtocol is a legacyProtocol that marks the messages of Text packets converted to the latest
+// protocol, so that tests can check which Protocol a Conn uses.
+type translatingProtocol struct {
+	legacyProtocol
+}
+
+// ConvertToLatest ...
+func (translatingProtocol) ConvertToLatest(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	if text, ok := pk.(*packet.Text); ok {
+		translated := *text
+		translated.Message += " (translated)"
+		return []packet.Packet{&translated}
+	}
+	return []packet.Packet{pk}
+}
+
+// TestAcceptedProtocolTranslation checks that a Conn accepted by a Listener uses the Protocol from
+// AcceptedProtocols matching the protocol of the client to translate the packets it reads.
+func TestAcceptedProtocolTranslation(t *testing.T) {
+	pro := translatingProtocol{legacyProtocol{minecraft.DefaultProtocol}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{Protocol: pro}, minecraft.ListenConfig{AcceptedProtocols: []minecraft.Protocol{pro}}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer client.Close()
+	defer server.Close()
+	if id := server.Protocol().ID(); id != protocol.CurrentProtocol-1 {
+		t.Fatalf("expected server to use protocol %v, got %v", protocol.CurrentProtocol-1, id)
+	}
+
+	if err := client.WritePacket(&packet.Text{TextType: packet.TextTypeChat, SourceName: "client", Message: "hello"}); err != nil {
+		t.Fatalf("write packet: %v", err)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	for {
+		pk, err := server.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if text, ok := pk.(*packet.Text); ok {
+			if text.Message != "hello (translated)" {
+				t.Errorf("expected message to be translated, got %q", text.Message)
+			}
+			return
+		}
+	}
+}
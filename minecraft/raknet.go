@@ -2,8 +2,10 @@ package minecraft
 
 import (
 	"context"
+	"encoding/binary"
 	"log/slog"
 	"net"
+	"sync/atomic"
 
 	"github.com/sandertv/go-raknet"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
@@ -12,20 +14,203 @@ import (
 // RakNet is an implementation of a RakNet v10 Network.
 type RakNet struct {
 	l *slog.Logger
+	// dial is the function used to open the UDP connections that RakNet connections are established over. If
+	// nil, the connections are dialed directly. It is set from Dialer.DialFunc.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// dualStack specifies if listening on an unspecified IP address should accept connections over both
+	// IPv4 and IPv6. It is set from ListenConfig.DualStack.
+	dualStack bool
+	// onPing is called for every unconnected ping received by a listener. If it returns false, the ping is
+	// dropped without responding to it. It is set from ListenConfig.OnUnconnectedPing.
+	onPing func(addr net.Addr) bool
+	// maxMTU is the maximum MTU negotiated when dialing, or 0 if not limited. It is set from Dialer.MaxMTU.
+	maxMTU int
+	// mtu, if non-nil, is set to the MTU negotiated when dialing.
+	mtu *atomic.Uint32
 }
 
 // DialContext ...
-func (RakNet) DialContext(ctx context.Context, a string) (net.Conn, error) {
-	return raknet.DialContext(ctx, a)
+func (r RakNet) DialContext(ctx context.Context, a string) (net.Conn, error) {
+	return r.dialer().DialContext(ctx, a)
 }
 
 // PingContext ...
-func (RakNet) PingContext(ctx context.Context, a string) ([]byte, error) {
-	return raknet.PingContext(ctx, a)
+func (r RakNet) PingContext(ctx context.Context, a string) ([]byte, error) {
+	return r.dialer().PingContext(ctx, a)
+}
+
+// dialer returns the raknet.Dialer used to dial and ping addresses.
+func (r RakNet) dialer() raknet.Dialer {
+	var d raknet.Dialer
+	if r.dial != nil {
+		d.UpstreamDialer = dialFunc(r.dial)
+	}
+	if r.maxMTU != 0 || r.mtu != nil {
+		dialer := mtuDialer{d: d.UpstreamDialer, mtu: r.mtu}
+		if r.maxMTU != 0 {
+			dialer.max = min(max(r.maxMTU, minMTU), maxMTU)
+		}
+		d.UpstreamDialer = dialer
+	}
+	return d
+}
+
+const (
+	// minMTU and maxMTU are the lowest and highest MTU used by RakNet connections.
+	minMTU, maxMTU = 400, 1492
+	// udpHeaderSize is the size of the IP and UDP headers of a datagram, which are included in an MTU.
+	udpHeaderSize = 28
+	// idOpenConnectionRequest1 is the ID of the RakNet message that a client sends, padded to the MTU it
+	// attempts, to discover the MTU of a connection.
+	idOpenConnectionRequest1 = 0x05
+	// idOpenConnectionReply2 is the ID of the RakNet message holding the MTU chosen by the server. The MTU is
+	// found in the second and third last bytes of the message.
+	idOpenConnectionReply2 = 0x08
+)
+
+// mtuDialer implements raknet.UpstreamDialer. It wraps the connections dialed in an mtuConn.
+type mtuDialer struct {
+	d   raknet.UpstreamDialer
+	max int
+	mtu *atomic.Uint32
+}
+
+// DialContext ...
+func (d mtuDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if d.d == nil {
+		conn, err = (&net.Dialer{}).DialContext(ctx, network, address)
+	} else {
+		conn, err = d.d.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mtuConn{Conn: conn, max: d.max, mtu: d.mtu}, nil
+}
+
+// mtuConn wraps a net.Conn dialed by RakNet. It limits the MTU attempted by the dialer to max by truncating
+// the padding of the open connection requests that exceed it, so that the server replies with an MTU of
+// at most max. The MTU chosen by the server is stored in mtu.
+type mtuConn struct {
+	net.Conn
+	max int
+	mtu *atomic.Uint32
+}
+
+// Write ...
+func (c mtuConn) Write(b []byte) (int, error) {
+	if c.max != 0 && len(b) > c.max-udpHeaderSize && b[0] == idOpenConnectionRequest1 {
+		// The request is only padding after its first 18 bytes, so it may be truncated. It must not be
+		// modified, as RakNet reuses it for later requests.
+		if _, err := c.Conn.Write(b[:c.max-udpHeaderSize]); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+// Read ...
+func (c mtuConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n >= 3 && b[0] == idOpenConnectionReply2 && c.mtu != nil {
+		mtu := binary.BigEndian.Uint16(b[n-3:])
+		c.mtu.Store(uint32(min(max(mtu, minMTU), maxMTU)))
+	}
+	return n, err
+}
+
+// dialFunc implements raknet.UpstreamDialer for a function.
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialContext ...
+func (f dialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
 }
 
 // Listen ...
-func (RakNet) Listen(address string) (NetworkListener, error) { return raknet.Listen(address) }
+func (r RakNet) Listen(address string) (NetworkListener, error) {
+	var conf raknet.ListenConfig
+	if r.dualStack {
+		conf.UpstreamPacketListener = dualStackListener{}
+	}
+	if r.onPing != nil {
+		conf.UpstreamPacketListener = pingFilterListener{l: conf.UpstreamPacketListener, f: r.onPing}
+	}
+	return conf.Listen(address)
+}
+
+// pingFilterListener implements raknet.UpstreamPacketListener. It wraps the net.PacketConn listened on, so
+// that unconnected pings are passed to a function before they reach the raknet.Listener.
+type pingFilterListener struct {
+	l raknet.UpstreamPacketListener
+	f func(addr net.Addr) bool
+}
+
+// ListenPacket ...
+func (l pingFilterListener) ListenPacket(network, address string) (net.PacketConn, error) {
+	var (
+		conn net.PacketConn
+		err  error
+	)
+	if l.l == nil {
+		conn, err = net.ListenPacket(network, address)
+	} else {
+		conn, err = l.l.ListenPacket(network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pingFilterConn{PacketConn: conn, f: l.f}, nil
+}
+
+// pingFilterConn is a net.PacketConn that drops unconnected pings for which the function f returns false.
+type pingFilterConn struct {
+	net.PacketConn
+	f func(addr net.Addr) bool
+}
+
+const (
+	// idUnconnectedPing and idUnconnectedPingOpenConnections are the IDs of the RakNet unconnected ping
+	// packets, which are answered with an unconnected pong holding the pong data of the listener.
+	idUnconnectedPing                = 0x01
+	idUnconnectedPingOpenConnections = 0x02
+)
+
+// ReadFrom reads the next packet from the net.PacketConn, skipping unconnected pings that are dropped.
+func (c pingFilterConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(b)
+		if err != nil || n == 0 || (b[0] != idUnconnectedPing && b[0] != idUnconnectedPingOpenConnections) {
+			return n, addr, err
+		}
+		if c.f(addr) {
+			return n, addr, nil
+		}
+	}
+}
+
+// dualStackListener implements raknet.UpstreamPacketListener. It listens on both IPv4 and IPv6 if the host of
+// the address is an unspecified IP address.
+type dualStackListener struct{}
+
+// ListenPacket ...
+func (dualStackListener) ListenPacket(network, address string) (net.PacketConn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		// Go listens on both IPv4 and IPv6 if the host is empty and the network is not restricted to one
+		// of the IP versions.
+		address = net.JoinHostPort("", port)
+	}
+	return net.ListenPacket(network, address)
+}
 
 // Compression ...
 func (RakNet) Compression(net.Conn) packet.Compression { return packet.FlateCompression }
@@ -0,0 +1,95 @@
+package minecraft_test
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandertv/go-raknet"
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// requireIPv6 skips the test if the system is unable to listen on the IPv6 loopback address.
+func requireIPv6(t *testing.T) {
+	conn, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	_ = conn.Close()
+}
+
+// handshake dials the address passed, which must be served by the Listener passed, and spawns the client,
+// completing the full handshake.
+func handshake(t *testing.T, l *minecraft.Listener, address string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	accepted := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			accepted <- err
+			return
+		}
+		defer c.Close()
+		accepted <- c.(*minecraft.Conn).StartGameContext(ctx, minecraft.GameData{})
+	}()
+
+	client, err := minecraft.Dialer{}.DialContext(ctx, "raknet", address)
+	if err != nil {
+		t.Fatalf("dial %v: %v", address, err)
+	}
+	defer client.Close()
+	if err := client.DoSpawnContext(ctx); err != nil {
+		t.Fatalf("spawn %v: %v", address, err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatalf("start game %v: %v", address, err)
+	}
+}
+
+// ping pings the address passed and checks that the pong holds the port passed as both its IPv4 and IPv6
+// port.
+func ping(t *testing.T, address, port string) {
+	t.Helper()
+	pong, err := raknet.PingTimeout(address, time.Second*5)
+	if err != nil {
+		t.Fatalf("ping %v: %v", address, err)
+	}
+	if !strings.Contains(string(pong), ";"+port+";"+port+";") {
+		t.Errorf("ping %v: expected port %v in pong, got %s", address, port, pong)
+	}
+}
+
+// TestListenIPv6 checks that a client is able to ping and complete the handshake with a Listener listening on
+// the IPv6 loopback address.
+func TestListenIPv6(t *testing.T) {
+	requireIPv6(t)
+	l, err := minecraft.ListenConfig{AuthenticationDisabled: true}.Listen("raknet", "[::1]:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	ping(t, l.Addr().String(), strconv.Itoa(l.Addr().(*net.UDPAddr).Port))
+	handshake(t, l, l.Addr().String())
+}
+
+// TestListenDualStack checks that a Listener listening on an unspecified address with DualStack enabled
+// responds to pings and completes the handshake with clients over both IPv4 and IPv6.
+func TestListenDualStack(t *testing.T) {
+	requireIPv6(t)
+	l, err := minecraft.ListenConfig{AuthenticationDisabled: true, DualStack: true}.Listen("raknet", "[::]:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.UDPAddr).Port)
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		ping(t, net.JoinHostPort(host, port), port)
+		handshake(t, l, net.JoinHostPort(host, port))
+	}
+}
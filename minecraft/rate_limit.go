@@ -0,0 +1,98 @@
+package minecraft
+
+import (
+	"net"
+	"time"
+)
+
+// rateLimiter is a token bucket that limits the rate at which an amount, such as a number of bytes, may be
+// consumed. The bucket holds at most burst tokens and is refilled at a rate of rate tokens per second.
+// A rateLimiter is not safe for concurrent use.
+type rateLimiter struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+// newRateLimiter returns a rateLimiter that allows rate tokens per second with a burst of burst tokens. The
+// bucket starts out full, so that a burst is allowed immediately.
+func newRateLimiter(rate, burst int) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes n tokens from the bucket and reports if enough tokens were available.
+func (l *rateLimiter) allow(n int) bool {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if float64(n) > l.tokens {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// decompressLimiter returns a rateLimiter for the maximum rate and burst of decompressed bytes passed, or
+// nil if rate is 0 or lower. If burst is 0 or lower, a burst of four seconds at the maximum rate is used.
+func decompressLimiter(rate, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate * 4
+	}
+	return newRateLimiter(rate, burst)
+}
+
+// connLimiter limits the rate at which new connections are accepted, both globally and per IP address.
+// A connLimiter is not safe for concurrent use.
+type connLimiter struct {
+	global    *rateLimiter
+	perIPRate int
+	perIP     map[string]*rateLimiter
+	lastPrune time.Time
+}
+
+// newConnLimiter returns a connLimiter that allows rate new connections per second in total and perIPRate new
+// connections per second from a single IP address. Either of the limits is disabled if 0 or lower. nil is
+// returned if both are.
+func newConnLimiter(rate, perIPRate int) *connLimiter {
+	if rate <= 0 && perIPRate <= 0 {
+		return nil
+	}
+	l := &connLimiter{perIPRate: perIPRate, perIP: make(map[string]*rateLimiter), lastPrune: time.Now()}
+	if rate > 0 {
+		l.global = newRateLimiter(rate, rate)
+	}
+	return l
+}
+
+// allow reports if a new connection from the address passed may be accepted.
+func (l *connLimiter) allow(addr net.Addr) bool {
+	if l.perIPRate > 0 {
+		now := time.Now()
+		if now.Sub(l.lastPrune) > time.Second {
+			// A bucket is full again after a second, so buckets that were not used for a second may be
+			// removed without changing the outcome of later calls.
+			for ip, bucket := range l.perIP {
+				if now.Sub(bucket.last) > time.Second {
+					delete(l.perIP, ip)
+				}
+			}
+			l.lastPrune = now
+		}
+		ip := addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		bucket, ok := l.perIP[ip]
+		if !ok {
+			bucket = newRateLimiter(l.perIPRate, l.perIPRate)
+			l.perIP[ip] = bucket
+		}
+		if !bucket.allow(1) {
+			return false
+		}
+	}
+	return l.global == nil || l.global.allow(1)
+}
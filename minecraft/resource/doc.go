@@ -2,4 +2,6 @@
 // It ensures the data in the resource pack is valid (for example, it checks if the manifest is present and
 // holds correct data) and extracts information which may be obtained by calling the exported methods of a
 // *resource.Pack.
+// Resource packs may be encrypted with a content key using Encrypt, so that they may only be used by clients
+// that obtain the key from the server, and decrypted again using Decrypt.
 package resource
@@ -0,0 +1,293 @@
+package resource
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+)
+
+// contentsMagic is the magic number found in the header of the contents.json of an encrypted resource pack.
+const contentsMagic = 0x9bcfb9fc
+
+// contentsHeaderSize is the size of the header of the contents.json of an encrypted resource pack, after
+// which the encrypted JSON follows.
+const contentsHeaderSize = 0x100
+
+// unencryptedFiles holds the names of the files in the root of a resource pack that are never encrypted, as
+// the client reads them before it has the content key.
+var unencryptedFiles = map[string]struct{}{
+	"manifest.json":     {},
+	"pack_icon.png":     {},
+	"bug_pack_icon.png": {},
+}
+
+// contents is the JSON structure of the contents.json of an encrypted resource pack. It holds the key of
+// every file of the pack that was encrypted.
+type contents struct {
+	Content []contentEntry `json:"content"`
+}
+
+// contentEntry is an entry in the contents.json of an encrypted resource pack. Key is empty for files that
+// are not encrypted.
+type contentEntry struct {
+	Path string `json:"path"`
+	Key  string `json:"key,omitempty"`
+}
+
+// Encrypt compiles the resource pack found at the path passed, like ReadPath, and encrypts it with the
+// content key passed, which must be 32 bytes long. The Pack returned holds the encrypted archive and has its
+// ContentKey set to the key, so that it may be added to a Listener and decrypted by clients.
+// Every file of the pack, except for the manifest.json and pack icons, is encrypted using AES-256 in CFB8
+// mode with a random key of its own, using the first 16 bytes of that key as IV. The keys of these files
+// are listed in a contents.json written next to the manifest.json, which is encrypted in the same way using
+// the content key, following a header holding the UUID of the pack. A contents.json already present in the
+// pack is replaced.
+func Encrypt(path string, key []byte) (*Pack, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encrypt resource pack: content key must be 32 bytes long, got %v", len(key))
+	}
+	pack, err := compile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := transformArchive(pack, func(root string, r *zip.Reader, w *zip.Writer) error {
+		var c contents
+		for _, f := range r.File {
+			name, ok := relativePath(root, f.Name)
+			if ok && name == "contents.json" {
+				continue
+			}
+			if !ok || f.FileInfo().IsDir() {
+				if err := copyFile(w, f, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, ok := unencryptedFiles[name]; ok {
+				c.Content = append(c.Content, contentEntry{Path: name})
+				if err := copyFile(w, f, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			fileKey := randomKey()
+			c.Content = append(c.Content, contentEntry{Path: name, Key: string(fileKey)})
+			if err := copyFile(w, f, func(data []byte) []byte { return encryptCFB8(fileKey, data) }); err != nil {
+				return err
+			}
+		}
+		b, _ := json.Marshal(c)
+		header := make([]byte, contentsHeaderSize, contentsHeaderSize+len(b))
+		binary.LittleEndian.PutUint32(header[4:], contentsMagic)
+		id := pack.UUID().String()
+		header[0x10] = byte(len(id))
+		copy(header[0x11:], id)
+
+		fw, err := w.Create(root + "contents.json")
+		if err != nil {
+			return fmt.Errorf("create contents.json: %w", err)
+		}
+		_, err = fw.Write(append(header, encryptCFB8(key, b)...))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encrypt resource pack: %w", err)
+	}
+	return newPack(pack.manifest, data, string(key)), nil
+}
+
+// Decrypt decrypts a resource pack encrypted with the content key passed, as produced by Encrypt. The Pack
+// returned holds the decrypted archive, without the contents.json of the encrypted pack, and is not
+// encrypted. An error is returned if the pack has no contents.json or if the key passed is not the key
+// that the pack was encrypted with.
+func Decrypt(pack *Pack, key []byte) (*Pack, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("decrypt resource pack: content key must be 32 bytes long, got %v", len(key))
+	}
+	data, err := transformArchive(pack, func(root string, r *zip.Reader, w *zip.Writer) error {
+		f, err := r.Open(root + "contents.json")
+		if err != nil {
+			return fmt.Errorf("open contents.json: %w", err)
+		}
+		b, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("read contents.json: %w", err)
+		}
+		if len(b) < contentsHeaderSize || binary.LittleEndian.Uint32(b[4:]) != contentsMagic {
+			return fmt.Errorf("contents.json is not encrypted")
+		}
+		var c contents
+		if err := json.Unmarshal(decryptCFB8(key, b[contentsHeaderSize:]), &c); err != nil {
+			return fmt.Errorf("decode contents.json: invalid content key: %w", err)
+		}
+		keys := make(map[string][]byte, len(c.Content))
+		for _, entry := range c.Content {
+			if entry.Key != "" {
+				keys[entry.Path] = []byte(entry.Key)
+			}
+		}
+		for _, f := range r.File {
+			name, ok := relativePath(root, f.Name)
+			if ok && name == "contents.json" {
+				continue
+			}
+			var transform func([]byte) []byte
+			if fileKey, found := keys[name]; ok && found {
+				transform = func(data []byte) []byte { return decryptCFB8(fileKey, data) }
+			}
+			if err := copyFile(w, f, transform); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decrypt resource pack: %w", err)
+	}
+	return newPack(pack.manifest, data, ""), nil
+}
+
+// transformArchive reads the archive of the Pack passed and calls f to write the files of a new archive,
+// which is returned. f is passed the directory holding the manifest.json of the pack, which is either empty
+// or ends with a slash.
+func transformArchive(pack *Pack, f func(root string, r *zip.Reader, w *zip.Writer) error) ([]byte, error) {
+	r, err := zip.NewReader(pack.content, int64(pack.Len()))
+	if err != nil {
+		return nil, fmt.Errorf("open zip reader: %w", err)
+	}
+	root, ok := "", false
+	for _, file := range r.File {
+		if path.Base(file.Name) == "manifest.json" && (!ok || len(file.Name) < len(root)+len("manifest.json")) {
+			root, ok = file.Name[:len(file.Name)-len("manifest.json")], true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("manifest.json not found in zip")
+	}
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	if err := f(root, r, w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("close zip writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// copyFile copies the zip.File passed to the zip.Writer. If transform is non-nil, the content of the file is
+// replaced with the data it returns.
+func copyFile(w *zip.Writer, f *zip.File, transform func(data []byte) []byte) error {
+	fw, err := w.Create(f.Name)
+	if err != nil {
+		return fmt.Errorf("create zip file %v: %w", f.Name, err)
+	}
+	if f.FileInfo().IsDir() {
+		return nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("open zip file %v: %w", f.Name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read zip file %v: %w", f.Name, err)
+	}
+	if transform != nil {
+		data = transform(data)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return fmt.Errorf("write zip file %v: %w", f.Name, err)
+	}
+	return nil
+}
+
+// relativePath returns the path of the file passed relative to the root of the pack, and false if the file is
+// not in the root.
+func relativePath(root, name string) (string, bool) {
+	if len(name) < len(root) || name[:len(root)] != root {
+		return "", false
+	}
+	return name[len(root):], true
+}
+
+// newPack creates a Pack holding the archive data and manifest passed, encrypted with the content key passed.
+func newPack(manifest *Manifest, data []byte, contentKey string) *Pack {
+	return &Pack{manifest: manifest, checksum: sha256.Sum256(data), content: bytes.NewReader(data), contentKey: contentKey}
+}
+
+// randomKey returns a random 32 byte key consisting of alphanumeric characters, as used for the files of
+// encrypted resource packs.
+func randomKey() []byte {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	for i, b := range key {
+		key[i] = chars[int(b)%len(chars)]
+	}
+	return key
+}
+
+// encryptCFB8 encrypts data using AES-256 in CFB8 mode with the key passed and the first 16 bytes of the key
+// as IV.
+func encryptCFB8(key, data []byte) []byte {
+	return cfb8XOR(key, data, true)
+}
+
+// decryptCFB8 decrypts data encrypted using encryptCFB8 with the same key.
+func decryptCFB8(key, data []byte) []byte {
+	return cfb8XOR(key, data, false)
+}
+
+// cfb8XOR encrypts or decrypts data using AES-256 in CFB8 mode with the key passed and the first 16 bytes of
+// the key as IV. The data passed is not modified.
+func cfb8XOR(key, data []byte, encrypt bool) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		// The key is always 32 bytes long.
+		panic(err)
+	}
+	out := make([]byte, len(data))
+	newCFB8(block, key[:aes.BlockSize], !encrypt).XORKeyStream(out, data)
+	return out
+}
+
+// cfb8 implements cipher.Stream for a block cipher in CFB8 mode, which the standard library does not
+// implement. Unlike CFB, CFB8 feeds back a single byte of ciphertext at a time.
+type cfb8 struct {
+	block   cipher.Block
+	sr, out []byte
+	decrypt bool
+}
+
+// newCFB8 returns a cfb8 stream for the block and IV passed.
+func newCFB8(block cipher.Block, iv []byte, decrypt bool) *cfb8 {
+	sr := make([]byte, block.BlockSize())
+	copy(sr, iv)
+	return &cfb8{block: block, sr: sr, out: make([]byte, block.BlockSize()), decrypt: decrypt}
+}
+
+// XORKeyStream ...
+func (x *cfb8) XORKeyStream(dst, src []byte) {
+	for i, b := range src {
+		x.block.Encrypt(x.out, x.sr)
+		c := b ^ x.out[0]
+		copy(x.sr, x.sr[1:])
+		if x.decrypt {
+			x.sr[len(x.sr)-1] = b
+		} else {
+			x.sr[len(x.sr)-1] = c
+		}
+		dst[i] = c
+	}
+}
@@ -0,0 +1,188 @@
+package resource
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testContentKey is the content key used to encrypt packs in tests.
+var testContentKey = []byte("s3cr3tK3yF0rTh3R3s0urc3P4ckT3st!")
+
+// writeTestPack writes the files passed to a new directory, together with a manifest.json, and returns the
+// directory and the files written, including the manifest.json.
+func writeTestPack(t *testing.T, files map[string][]byte) (string, map[string][]byte) {
+	t.Helper()
+	dir := t.TempDir()
+	files["manifest.json"] = []byte(`{"format_version": 2, "header": {"name": "test", "description": "", "uuid": "` + uuid.New().String() + `", "version": [1, 0, 0], "min_engine_version": [1, 20, 0]}, "modules": [{"type": "resources", "uuid": "` + uuid.New().String() + `", "version": [1, 0, 0]}]}`)
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, files
+}
+
+// readArchive returns the content of the files in the archive of the Pack passed by their name, leaving out
+// directories.
+func readArchive(t *testing.T, pack *Pack) map[string][]byte {
+	t.Helper()
+	r, err := zip.NewReader(pack.content, int64(pack.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %v: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("read %v: %v", f.Name, err)
+		}
+		files[f.Name] = data
+	}
+	return files
+}
+
+func TestEncrypt(t *testing.T) {
+	dir, files := writeTestPack(t, map[string][]byte{
+		"pack_icon.png":           []byte("icon"),
+		"textures/blocks/a.png":   bytes.Repeat([]byte("texture"), 100),
+		"texts/en_US.lang":        []byte("item.a.name=A"),
+		"contents.json":           []byte(`{"content": []}`),
+		"subpacks/low/ignore.txt": []byte("subpack"),
+	})
+	delete(files, "contents.json")
+
+	pack, err := Encrypt(dir, testContentKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !pack.Encrypted() || pack.ContentKey() != string(testContentKey) {
+		t.Errorf("expected pack encrypted with content key %s, got %q", testContentKey, pack.ContentKey())
+	}
+	encrypted := readArchive(t, pack)
+
+	// The contents.json starts with a header holding a magic number and the UUID of the pack, followed by
+	// the list of files encrypted using the content key.
+	b := encrypted["contents.json"]
+	if len(b) < contentsHeaderSize {
+		t.Fatalf("expected contents.json of at least %v bytes, got %v", contentsHeaderSize, len(b))
+	}
+	if magic := binary.LittleEndian.Uint32(b[4:]); magic != contentsMagic {
+		t.Errorf("expected magic %x, got %x", contentsMagic, magic)
+	}
+	if id := string(b[0x11 : 0x11+int(b[0x10])]); id != pack.UUID().String() {
+		t.Errorf("expected UUID %v in header, got %v", pack.UUID(), id)
+	}
+	var c contents
+	if err := json.Unmarshal(decryptCFB8(testContentKey, b[contentsHeaderSize:]), &c); err != nil {
+		t.Fatalf("decode contents.json: %v", err)
+	}
+	if len(c.Content) != len(files) {
+		t.Errorf("expected %v entries in contents.json, got %v", len(files), len(c.Content))
+	}
+	for _, entry := range c.Content {
+		data, ok := files[entry.Path]
+		if !ok {
+			t.Errorf("unexpected entry %v in contents.json", entry.Path)
+			continue
+		}
+		if _, unencrypted := unencryptedFiles[entry.Path]; unencrypted {
+			if entry.Key != "" || !bytes.Equal(encrypted[entry.Path], data) {
+				t.Errorf("expected %v to be left unencrypted", entry.Path)
+			}
+			continue
+		}
+		if len(entry.Key) != 32 {
+			t.Errorf("expected key of 32 bytes for %v, got %q", entry.Path, entry.Key)
+			continue
+		}
+		if bytes.Equal(encrypted[entry.Path], data) {
+			t.Errorf("expected %v to be encrypted", entry.Path)
+		}
+		if decrypted := decryptCFB8([]byte(entry.Key), encrypted[entry.Path]); !bytes.Equal(decrypted, data) {
+			t.Errorf("expected %v to be decrypted using its key", entry.Path)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir, files := writeTestPack(t, map[string][]byte{
+		"pack_icon.png":         []byte("icon"),
+		"textures/blocks/a.png": bytes.Repeat([]byte("texture"), 100),
+		"texts/en_US.lang":      []byte("item.a.name=A"),
+	})
+	pack, err := Encrypt(dir, testContentKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	decrypted, err := Decrypt(pack, testContentKey)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted.Encrypted() {
+		t.Error("expected decrypted pack not to be encrypted")
+	}
+	got := readArchive(t, decrypted)
+	if len(got) != len(files) {
+		t.Errorf("expected %v files after decrypting, got %v", len(files), len(got))
+	}
+	for name, data := range files {
+		if !bytes.Equal(got[name], data) {
+			t.Errorf("expected %v to be restored after decrypting, got %q", name, got[name])
+		}
+	}
+
+	wrongKey := bytes.Repeat([]byte{'k'}, 32)
+	if _, err := Decrypt(pack, wrongKey); err == nil {
+		t.Error("expected error decrypting with the wrong key")
+	}
+	if _, err := Decrypt(decrypted, testContentKey); err == nil {
+		t.Error("expected error decrypting a pack that is not encrypted")
+	}
+	if _, err := Encrypt(dir, testContentKey[:16]); err == nil {
+		t.Error("expected error encrypting with a key of 16 bytes")
+	}
+}
+
+// TestCFB8 checks the CFB8 implementation against the CFB8-AES256 test vectors of NIST SP 800-38A, F.3.17
+// and F.3.18.
+func TestCFB8(t *testing.T) {
+	key, _ := hex.DecodeString("603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4")
+	iv, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	plaintext, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d")
+	ciphertext, _ := hex.DecodeString("dc1f1a8520a64db55fcc8ac554844e889700")
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(plaintext))
+	newCFB8(block, iv, false).XORKeyStream(out, plaintext)
+	if !bytes.Equal(out, ciphertext) {
+		t.Errorf("encrypt: expected %x, got %x", ciphertext, out)
+	}
+	newCFB8(block, iv, true).XORKeyStream(out, ciphertext)
+	if !bytes.Equal(out, plaintext) {
+		t.Errorf("decrypt: expected %x, got %x", plaintext, out)
+	}
+}
@@ -3,6 +3,7 @@ package minecraft
 import (
 	"bytes"
 	"fmt"
+	"io"
 
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 	"github.com/sandertv/gophertunnel/minecraft/resource"
@@ -23,7 +24,11 @@ type resourcePackQueue struct {
 
 // downloadingPack is a resource pack that is being downloaded by a client connection.
 type downloadingPack struct {
-	buf           *bytes.Buffer
+	buf *bytes.Buffer
+	// w is the io.WriteCloser obtained from the Dialer's ResourcePackWriter. If non-nil, the data of the
+	// pack is written to w instead of buf.
+	w             io.WriteCloser
+	version       string
 	chunkSize     uint32
 	size          uint64
 	expectedIndex uint32
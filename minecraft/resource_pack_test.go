@@ -0,0 +1,112 @@
+package minecraft_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/resource"
+)
+
+// testPack creates a resource pack with a manifest and a single file in a temporary directory and reads it.
+func testPack(t *testing.T, name string) *resource.Pack {
+	t.Helper()
+	dir := t.TempDir()
+	manifest := fmt.Sprintf(`{"format_version": 2, "header": {"name": %q, "description": "", "uuid": %q, "version": [1, 0, 0], "min_engine_version": [1, 20, 0]}, "modules": [{"type": "resources", "uuid": %q, "version": [1, 0, 0]}]}`, name, uuid.New(), uuid.New())
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "textures"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "textures", "data.bin"), bytes.Repeat([]byte(name), 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pack, err := resource.ReadPath(dir)
+	if err != nil {
+		t.Fatalf("read pack: %v", err)
+	}
+	return pack
+}
+
+// trackedWriter is an io.WriteCloser that records the data written to it and whether it was closed.
+type trackedWriter struct {
+	bytes.Buffer
+	closed chan struct{}
+	once   sync.Once
+}
+
+// Close ...
+func (w *trackedWriter) Close() error {
+	w.once.Do(func() { close(w.closed) })
+	return nil
+}
+
+func TestResourcePackWriter(t *testing.T) {
+	pack := testPack(t, "writer")
+	w := &trackedWriter{closed: make(chan struct{})}
+	d := minecraft.Dialer{ResourcePackWriter: func(id uuid.UUID, version string, size uint64) (io.WriteCloser, error) {
+		return w, nil
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, d, minecraft.ListenConfig{ResourcePacks: []*resource.Pack{pack}}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	select {
+	case <-w.closed:
+	default:
+		t.Fatal("writer was not closed after the download")
+	}
+	if w.Len() != pack.Len() {
+		t.Errorf("expected %v bytes written, got %v", pack.Len(), w.Len())
+	}
+}
+
+func TestResourcePackWriterFailure(t *testing.T) {
+	packs := []*resource.Pack{testPack(t, "first"), testPack(t, "second")}
+
+	var mu sync.Mutex
+	var opened []*trackedWriter
+	d := minecraft.Dialer{ResourcePackWriter: func(id uuid.UUID, version string, size uint64) (io.WriteCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(opened) == 1 {
+			return nil, errors.New("no space left")
+		}
+		w := &trackedWriter{closed: make(chan struct{})}
+		opened = append(opened, w)
+		return w, nil
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, _, err := minecraft.Pipe(ctx, d, minecraft.ListenConfig{ResourcePacks: packs}, minecraft.GameData{}); err == nil {
+		t.Fatal("expected pipe to fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(opened) != 1 {
+		t.Fatalf("expected 1 writer to be opened, got %v", len(opened))
+	}
+	select {
+	case <-opened[0].closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer opened before the failure was not closed")
+	}
+}
@@ -0,0 +1,34 @@
+package minecraft
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SecurityInfo holds information on the encryption of a Conn, such as the parameters of the key exchange, for
+// example to log them for auditing. It never holds the key itself. It is obtained using Conn.SecurityInfo.
+type SecurityInfo struct {
+	// Encrypted is true if encryption is enabled for the Conn. The other fields are only set if Encrypted is
+	// true.
+	Encrypted bool
+	// Curve is the name of the elliptic curve of the keys used for the ECDH key exchange, such as 'P-384'.
+	Curve string
+	// Salt is the salt that the server generated for the connection, which is combined with the shared secret
+	// of the key exchange to produce the key. The server generates a random salt for every connection, so it
+	// differs between connections. The salt is sent to the client unencrypted, so it is not secret.
+	Salt []byte
+	// Cipher is the name of the cipher used to encrypt the connection, such as 'AES-256-CTR'. If the
+	// packet.Encryption of the Protocol of the Conn does not implement fmt.Stringer, Cipher holds its type.
+	Cipher string
+	// KeySize is the size in bytes of the key used to encrypt the connection.
+	KeySize int
+}
+
+// securityInfo returns a SecurityInfo for the encryption parameters passed.
+func securityInfo(curve string, salt []byte, enc any, keySize int) SecurityInfo {
+	cipher := fmt.Sprintf("%T", enc)
+	if s, ok := enc.(fmt.Stringer); ok {
+		cipher = s.String()
+	}
+	return SecurityInfo{Encrypted: true, Curve: curve, Salt: slices.Clone(salt), Cipher: cipher, KeySize: keySize}
+}
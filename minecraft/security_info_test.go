@@ -0,0 +1,51 @@
+package minecraft_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// TestSecurityInfo checks that both ends of a connection report the same encryption parameters, and that two
+// connections use a different salt, even if the client uses the same private key for both.
+func TestSecurityInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	var salts [][]byte
+	for range 2 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{PrivateKey: key}, minecraft.ListenConfig{}, minecraft.GameData{})
+		cancel()
+		if err != nil {
+			t.Fatalf("pipe: %v", err)
+		}
+		clientInfo, serverInfo := client.SecurityInfo(), server.SecurityInfo()
+		_ = client.Close()
+		_ = server.Close()
+
+		if !clientInfo.Encrypted || !serverInfo.Encrypted {
+			t.Fatalf("expected both ends to be encrypted, got %+v and %+v", clientInfo, serverInfo)
+		}
+		if clientInfo.Curve != "P-384" || clientInfo.Cipher == "" || clientInfo.KeySize != 32 {
+			t.Errorf("unexpected encryption parameters %+v", clientInfo)
+		}
+		if clientInfo.Curve != serverInfo.Curve || clientInfo.Cipher != serverInfo.Cipher || clientInfo.KeySize != serverInfo.KeySize {
+			t.Errorf("expected both ends to report the same parameters, got %+v and %+v", clientInfo, serverInfo)
+		}
+		if len(clientInfo.Salt) == 0 || !bytes.Equal(clientInfo.Salt, serverInfo.Salt) {
+			t.Errorf("expected both ends to report the same salt, got %x and %x", clientInfo.Salt, serverInfo.Salt)
+		}
+		salts = append(salts, clientInfo.Salt)
+	}
+	if bytes.Equal(salts[0], salts[1]) {
+		t.Errorf("expected connections to use different salts, got %x twice", salts[0])
+	}
+}
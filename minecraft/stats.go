@@ -0,0 +1,89 @@
+package minecraft
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ConnStats holds statistics on the packets and bytes read from and written to a Conn. It is obtained using
+// Conn.Stats.
+type ConnStats struct {
+	// PacketsRead and PacketsWritten are the amount of packets read from and written to the connection.
+	// Packets in batches forwarded using ReadBatch and WriteBatch are not counted.
+	PacketsRead, PacketsWritten uint64
+	// BytesRead and BytesWritten are the amount of bytes of batches read from and written to the connection
+	// as they were sent over the network, that is, after compression and encryption.
+	BytesRead, BytesWritten uint64
+	// BytesDecompressed is the amount of bytes of the packets read, after decompressing the batches they were
+	// sent in. It includes the headers of the packets.
+	BytesDecompressed uint64
+	// BytesUncompressed is the amount of bytes of the packets written, before compressing the batches they
+	// were sent in. It includes the headers of the packets.
+	BytesUncompressed uint64
+}
+
+// ReadCompressionRatio returns the ratio between the size of the packets read and the size of the batches
+// they were read in. A ratio of 4 means the packets read were compressed to a quarter of their size. 0 is
+// returned if nothing was read yet.
+func (stats ConnStats) ReadCompressionRatio() float64 {
+	if stats.BytesRead == 0 {
+		return 0
+	}
+	return float64(stats.BytesDecompressed) / float64(stats.BytesRead)
+}
+
+// WriteCompressionRatio returns the ratio between the size of the packets written and the size of the
+// batches they were written in. A ratio of 4 means the packets written were compressed to a quarter of their
+// size. 0 is returned if nothing was written yet.
+func (stats ConnStats) WriteCompressionRatio() float64 {
+	if stats.BytesWritten == 0 {
+		return 0
+	}
+	return float64(stats.BytesUncompressed) / float64(stats.BytesWritten)
+}
+
+// connStats holds the counters of a Conn from which a ConnStats is created. The counters are updated
+// atomically, so that they may be read while packets are read and written.
+type connStats struct {
+	packetsRead, packetsWritten atomic.Uint64
+	bytesRead, bytesWritten     atomic.Uint64
+	bytesDecompressed           atomic.Uint64
+	bytesUncompressed           atomic.Uint64
+}
+
+// snapshot returns a ConnStats holding the current values of the counters.
+func (s *connStats) snapshot() ConnStats {
+	return ConnStats{
+		PacketsRead:       s.packetsRead.Load(),
+		PacketsWritten:    s.packetsWritten.Load(),
+		BytesRead:         s.bytesRead.Load(),
+		BytesWritten:      s.bytesWritten.Load(),
+		BytesDecompressed: s.bytesDecompressed.Load(),
+		BytesUncompressed: s.bytesUncompressed.Load(),
+	}
+}
+
+// countPackets adds the packets passed and their total length to the counters passed. The total length is
+// returned.
+func countPackets(packets [][]byte, count, bytes *atomic.Uint64) int {
+	var n int
+	for _, pk := range packets {
+		n += len(pk)
+	}
+	count.Add(uint64(len(packets)))
+	bytes.Add(uint64(n))
+	return n
+}
+
+// statsWriter wraps around an io.Writer to count the bytes written to it.
+type statsWriter struct {
+	w io.Writer
+	n *atomic.Uint64
+}
+
+// Write ...
+func (w statsWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n.Add(uint64(n))
+	return n, err
+}
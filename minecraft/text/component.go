@@ -0,0 +1,173 @@
+package text
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Component is a JSON text component, as sent in a packet.Text with one of the JSON text types and used by
+// commands such as /tellraw. The client resolves the component when it is displayed, so that translation
+// keys are translated into the language of the client.
+// A component holds a translation key if Translate is set, a target selector if Selector is set, a score if
+// Score is set, a list of components if RawText is set, and plain text otherwise.
+type Component struct {
+	// Text is the plain text of the component. It may hold formatting codes.
+	Text string
+	// Translate is a translation key, such as 'commands.give.success', that the client translates into its
+	// language when displaying the component.
+	Translate string
+	// With holds the arguments substituted in the translation of Translate, in the order of the %s or %1
+	// placeholders found in it. These components may be any component, including translations.
+	With []Component
+	// Selector is a target selector, such as '@p', which the client replaces with the names of the entities
+	// selected.
+	Selector string
+	// Score is the score of an entity, which the client replaces with the score value.
+	Score *Score
+	// RawText is a list of components, which are displayed one after another.
+	RawText []Component
+}
+
+// Score is the score of an entity in a scoreboard objective, as displayed by a Component.
+type Score struct {
+	// Name is the name of the entity or a target selector, such as '*' for the player that the component is
+	// displayed to.
+	Name string `json:"name"`
+	// Objective is the name of the scoreboard objective that the score is in.
+	Objective string `json:"objective"`
+}
+
+// RawText returns a Component holding the components passed, which are displayed one after another. It is
+// the component that is typically sent in a packet.Text.
+func RawText(components ...Component) Component {
+	return Component{RawText: components}
+}
+
+// Plain returns a Component holding the plain text passed.
+func Plain(text string) Component {
+	return Component{Text: text}
+}
+
+// Plainf returns a Component holding the text produced by formatting the format string passed with the
+// arguments passed, using fmt.Sprintf.
+func Plainf(format string, a ...any) Component {
+	return Component{Text: fmt.Sprintf(format, a...)}
+}
+
+// Translation returns a Component holding the translation key passed, with the components passed
+// substituted as arguments of the translation.
+func Translation(key string, with ...Component) Component {
+	return Component{Translate: key, With: with}
+}
+
+// String returns the JSON encoding of the Component, as it should be set to the Message of a packet.Text.
+// Unlike json.Marshal, String does not escape HTML characters such as '<' and '>'.
+func (c Component) String() string {
+	b, err := c.marshal()
+	if err != nil {
+		// The fields of a Component cannot hold values that fail to be encoded.
+		panic(err)
+	}
+	return string(b)
+}
+
+// ParseComponent parses a Component from the JSON passed, such as the Message of a packet.Text with one of
+// the JSON text types.
+func ParseComponent(s string) (Component, error) {
+	var c Component
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return Component{}, fmt.Errorf("parse text component: %w", err)
+	}
+	return c, nil
+}
+
+// componentData is the JSON representation of a Component.
+type componentData struct {
+	Text      *string         `json:"text,omitempty"`
+	Translate string          `json:"translate,omitempty"`
+	With      json.RawMessage `json:"with,omitempty"`
+	Selector  string          `json:"selector,omitempty"`
+	Score     *Score          `json:"score,omitempty"`
+	RawText   []Component     `json:"rawtext,omitempty"`
+}
+
+// MarshalJSON ...
+func (c Component) MarshalJSON() ([]byte, error) {
+	return c.marshal()
+}
+
+// marshal encodes the Component to JSON without escaping HTML characters.
+func (c Component) marshal() ([]byte, error) {
+	data := componentData{Translate: c.Translate, Selector: c.Selector, Score: c.Score, RawText: c.RawText}
+	if c.Text != "" || (c.Translate == "" && c.Selector == "" && c.Score == nil && c.RawText == nil) {
+		data.Text = &c.Text
+	}
+	if len(c.With) != 0 {
+		var with any = RawText(c.With...)
+		if plain, ok := plainTexts(c.With); ok {
+			// The client accepts plain text arguments as a list of strings, which is the shortest form.
+			with = plain
+		}
+		b, err := encode(with)
+		if err != nil {
+			return nil, err
+		}
+		data.With = b
+	}
+	return encode(data)
+}
+
+// UnmarshalJSON ...
+func (c *Component) UnmarshalJSON(b []byte) error {
+	var data componentData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	*c = Component{Translate: data.Translate, Selector: data.Selector, Score: data.Score, RawText: data.RawText}
+	if data.Text != nil {
+		c.Text = *data.Text
+	}
+	if len(data.With) == 0 {
+		return nil
+	}
+	// The arguments of a translation are either a list of strings or a component holding a list of
+	// components.
+	var plain []string
+	if err := json.Unmarshal(data.With, &plain); err == nil {
+		for _, s := range plain {
+			c.With = append(c.With, Plain(s))
+		}
+		return nil
+	}
+	var with Component
+	if err := json.Unmarshal(data.With, &with); err != nil {
+		return fmt.Errorf("decode with: %w", err)
+	}
+	c.With = with.RawText
+	return nil
+}
+
+// plainTexts returns the texts of the components passed if all of them hold only plain text.
+func plainTexts(components []Component) ([]string, bool) {
+	texts := make([]string, len(components))
+	for i, c := range components {
+		if c.Translate != "" || c.With != nil || c.Selector != "" || c.Score != nil || c.RawText != nil {
+			return nil, false
+		}
+		texts[i] = c.Text
+	}
+	return texts, true
+}
+
+// encode encodes the value passed to JSON without escaping HTML characters and without a trailing newline.
+func encode(v any) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimSuffix(buf.String(), "\n")), nil
+}
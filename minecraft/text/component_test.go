@@ -0,0 +1,84 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Component
+		json string
+	}{
+		{name: "plain", c: Plain("hello"), json: `{"text":"hello"}`},
+		{name: "empty", c: Component{}, json: `{"text":""}`},
+		{name: "html", c: Plain("<b>&</b>"), json: `{"text":"<b>&</b>"}`},
+		{name: "formatted", c: Plainf("%d%%", 50), json: `{"text":"50%"}`},
+		{name: "selector", c: Component{Selector: "@p"}, json: `{"selector":"@p"}`},
+		{name: "score", c: Component{Score: &Score{Name: "*", Objective: "kills"}}, json: `{"score":{"name":"*","objective":"kills"}}`},
+		{name: "translation", c: Translation("item.apple.name"), json: `{"translate":"item.apple.name"}`},
+		{
+			name: "translation with plain arguments",
+			c:    Translation("commands.give.success", Plain("Stone"), Plain("1"), Plain("Steve")),
+			json: `{"translate":"commands.give.success","with":["Stone","1","Steve"]}`,
+		},
+		{
+			// Placeholders are substituted by the client, so they are encoded as they are.
+			name: "positional placeholders",
+			c:    Translation("%2$s, %1$s and %s", Plain("a"), Plain("b")),
+			json: `{"translate":"%2$s, %1$s and %s","with":["a","b"]}`,
+		},
+		{
+			name: "plain argument holding a placeholder",
+			c:    Translation("%s", Plain("%1$s")),
+			json: `{"translate":"%s","with":["%1$s"]}`,
+		},
+		{
+			name: "translation argument",
+			c:    Translation("chat.type.text", Plain("Steve"), Translation("item.apple.name")),
+			json: `{"translate":"chat.type.text","with":{"rawtext":[{"text":"Steve"},{"translate":"item.apple.name"}]}}`,
+		},
+		{
+			name: "nested translation arguments",
+			c:    Translation("%s", Translation("%1$s: %2$s", Component{Selector: "@s"}, Translation("%s", Plain("x")))),
+			json: `{"translate":"%s","with":{"rawtext":[{"translate":"%1$s: %2$s","with":{"rawtext":[{"selector":"@s"},{"translate":"%s","with":["x"]}]}}]}}`,
+		},
+		{
+			name: "nested raw text",
+			c: RawText(
+				Plain("§a"),
+				RawText(Component{Selector: "@p"}, Plain(": ")),
+				Component{Score: &Score{Name: "*", Objective: "kills"}},
+			),
+			json: `{"rawtext":[{"text":"§a"},{"rawtext":[{"selector":"@p"},{"text":": "}]},{"score":{"name":"*","objective":"kills"}}]}`,
+		},
+		{
+			name: "raw text holding translations",
+			c:    RawText(Translation("%s joined", Plain("Steve")), RawText(Translation("%1$s left", RawText(Plain("Alex"))))),
+			json: `{"rawtext":[{"translate":"%s joined","with":["Steve"]},{"rawtext":[{"translate":"%1$s left","with":{"rawtext":[{"rawtext":[{"text":"Alex"}]}]}}]}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if s := test.c.String(); s != test.json {
+				t.Errorf("expected %v, got %v", test.json, s)
+			}
+			c, err := ParseComponent(test.json)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !reflect.DeepEqual(c, test.c) {
+				t.Errorf("expected %+v after parsing, got %+v", test.c, c)
+			}
+		})
+	}
+}
+
+func TestParseComponentInvalid(t *testing.T) {
+	for _, s := range []string{``, `{`, `{"with":1,"translate":"%s"}`, `{"rawtext":{}}`} {
+		if c, err := ParseComponent(s); err == nil {
+			t.Errorf("%v: expected error, got %+v", s, c)
+		}
+	}
+}
@@ -1,4 +1,4 @@
 // Package text has utility methods used for formatting text to display in Minecraft, and to convert these
 // colour codes into codes suitable for the command line. It also contains constants for each of the
-// Minecraft colours and formatting codes.
+// Minecraft colours and formatting codes, and the Component type used to build JSON text components.
 package text
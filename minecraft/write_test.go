@@ -0,0 +1,94 @@
+package minecraft_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestWriteBatchTargetError checks that writing past the batch target of a Conn returns a *FlushError and
+// closes the Conn if flushing fails, rather than blocking forever.
+func TestWriteBatchTargetError(t *testing.T) {
+	text := []byte{packet.IDText, 0}
+	tests := map[string]func(c *minecraft.Conn) error{
+		"Write": func(c *minecraft.Conn) error {
+			_, err := c.Write(text)
+			return err
+		},
+		"WriteRawPacket": func(c *minecraft.Conn) error {
+			return c.WriteRawPacket(packet.IDText, []byte{0})
+		},
+		"ForwardPacket": func(c *minecraft.Conn) error {
+			return c.ForwardPacket(&packet.Text{}, text)
+		},
+		"WritePacket": func(c *minecraft.Conn) error {
+			return c.WritePacket(&packet.Text{})
+		},
+	}
+	for name, write := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{}, minecraft.ListenConfig{}, minecraft.GameData{})
+		cancel()
+		if err != nil {
+			t.Fatalf("pipe: %v", err)
+		}
+		client.SetBatchTarget(1)
+		_ = client.SetWriteDeadline(time.Now().Add(-time.Second))
+
+		done := make(chan error, 1)
+		go func() { done <- write(client) }()
+		select {
+		case err := <-done:
+			var flushErr *minecraft.FlushError
+			if !errors.As(err, &flushErr) {
+				t.Errorf("%v: expected *FlushError, got %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: write did not return after flushing failed", name)
+		}
+		select {
+		case <-client.Context().Done():
+		case <-time.After(5 * time.Second):
+			t.Errorf("%v: expected Conn to be closed after flushing failed", name)
+		}
+		_ = client.Close()
+		_ = server.Close()
+	}
+}
+
+// TestWriteBatchError checks that WriteBatch returns a *FlushError and closes the Conn if writing the batch
+// fails.
+func TestWriteBatchError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, server, err := minecraft.Pipe(ctx, minecraft.Dialer{}, minecraft.ListenConfig{}, minecraft.GameData{})
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer server.Close()
+	defer client.Close()
+	_ = client.SetWriteDeadline(time.Now().Add(-time.Second))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WriteBatch([]byte{0xff}, packet.NewOnTheFlyCompression(packet.FlateCompression))
+	}()
+	select {
+	case err := <-done:
+		var flushErr *minecraft.FlushError
+		if !errors.As(err, &flushErr) {
+			t.Errorf("expected *FlushError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteBatch did not return after writing failed")
+	}
+	select {
+	case <-client.Context().Done():
+	case <-time.After(5 * time.Second):
+		t.Error("expected Conn to be closed after writing failed")
+	}
+}